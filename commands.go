@@ -17,10 +17,12 @@
 package main
 
 import (
+	"io/ioutil"
 	"os"
 	"os/user"
 
 	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/auth"
 	"github.com/minio/minio/pkg/controller"
 	"github.com/minio/minio/pkg/server"
 	"github.com/minio/minio/pkg/server/api"
@@ -65,6 +67,36 @@ EXAMPLES:
   2. Get memstats from controller
       $ minio {{.Name}} mem http://localhost:9001/rpc
 
+  3. Create a user allowed to read bucket photos and write bucket uploads, prints its keys
+      $ minio {{.Name}} user create http://localhost:9001/rpc alice readonly:photos readwrite:uploads
+
+  4. Manage users, policies are readwrite, readonly or writeonly, optionally scoped as <policy>:<bucket>,
+     or stored policies
+      $ minio {{.Name}} user list http://localhost:9001/rpc
+      $ minio {{.Name}} user [disable|enable|delete|rotate] http://localhost:9001/rpc alice
+      $ minio {{.Name}} user [attach|detach] http://localhost:9001/rpc alice writeonly
+
+  5. Store a policy in the IAM JSON grammar and manage stored policies
+      $ minio {{.Name}} policy put http://localhost:9001/rpc photos-editor photos-editor.json
+      $ minio {{.Name}} policy list http://localhost:9001/rpc
+      $ minio {{.Name}} policy [get|delete] http://localhost:9001/rpc photos-editor
+
+  6. Manage groups, their members inherit their policies
+      $ minio {{.Name}} group create http://localhost:9001/rpc editors photos-editor
+      $ minio {{.Name}} group list http://localhost:9001/rpc
+      $ minio {{.Name}} group [add|remove] http://localhost:9001/rpc editors alice bob
+      $ minio {{.Name}} group [attach|detach] http://localhost:9001/rpc editors readonly
+      $ minio {{.Name}} group delete http://localhost:9001/rpc editors
+
+  7. Trace API calls failing with server errors on bucket photos, with their headers
+      $ minio {{.Name}} trace http://localhost:9001/trace bucket=photos status=5xx headers
+
+  8. Users, groups, policies and traces are managed by admins, requests are signed with the keys given by
+     --access-key and --secret-key or MINIO_ACCESS_KEY and MINIO_SECRET_KEY. The first admin is created
+     on the server itself, in its users config, prints its keys
+      $ minio {{.Name}} admin root
+      $ MINIO_ACCESS_KEY=<key> MINIO_SECRET_KEY=<secret> minio {{.Name}} user list http://localhost:9001/rpc
+
 `,
 }
 
@@ -75,12 +107,32 @@ func getServerConfig(c *cli.Context) api.Config {
 		Fatalln("Both certificate and key are required to enable https.")
 	}
 	tls := (certFile != "" && keyFile != "")
+	rateLimit := c.GlobalInt("ratelimit")
+	// operations are admitted below the connection limit, otherwise nothing would ever be queued
+	maxOperations := c.GlobalInt("max-operations")
+	if maxOperations == 0 && rateLimit > 0 {
+		maxOperations = rateLimit / 2
+		if maxOperations == 0 {
+			maxOperations = 1
+		}
+	}
 	return api.Config{
 		Address:   c.GlobalString("address"),
 		TLS:       tls,
 		CertFile:  certFile,
 		KeyFile:   keyFile,
-		RateLimit: c.GlobalInt("ratelimit"),
+		RateLimit: rateLimit,
+
+		MaxOperations: maxOperations,
+		QueueTimeout:  c.GlobalDuration("queue-timeout"),
+
+		AccessLog:        c.GlobalString("access-log"),
+		AccessLogFormat:  c.GlobalString("access-log-format"),
+		AccessLogMaxSize: int64(c.GlobalInt("access-log-max-size")) * 1024 * 1024,
+		AccessLogMaxAge:  c.GlobalDuration("access-log-max-age"),
+		AccessLogBackups: c.GlobalInt("access-log-backups"),
+
+		AuditLog: c.GlobalString("audit-log"),
 	}
 }
 
@@ -96,6 +148,131 @@ func runServer(c *cli.Context) {
 	}
 }
 
+// runUserController - minio controller user <command> <url> [<name> [<policy>...]]
+func runUserController(c *cli.Context) {
+	args := c.Args().Tail()
+	command, url := args.First(), args.Tail().First()
+	if command == "list" {
+		users, err := controller.ListUsers(url)
+		if err != nil {
+			Fatalln(err)
+		}
+		Println(string(users))
+		return
+	}
+	if len(args) < 3 {
+		cli.ShowCommandHelpAndExit(c, "controller", 1) // last argument is exit code
+	}
+	name, policies := args.Tail().Tail().First(), args.Tail().Tail().Tail()
+	var err error
+	var reply []byte
+	switch command {
+	case "create":
+		reply, err = controller.CreateUser(url, name, policies)
+	case "enable":
+		err = controller.EnableUser(url, name)
+	case "disable":
+		err = controller.DisableUser(url, name)
+	case "delete":
+		err = controller.DeleteUser(url, name)
+	case "rotate":
+		reply, err = controller.RotateUserKeys(url, name)
+	case "attach":
+		err = controller.AttachUserPolicies(url, name, policies)
+	case "detach":
+		err = controller.DetachUserPolicies(url, name, policies)
+	default:
+		cli.ShowCommandHelpAndExit(c, "controller", 1) // last argument is exit code
+	}
+	if err != nil {
+		Fatalln(err)
+	}
+	if reply != nil {
+		Println(string(reply))
+	}
+}
+
+// runGroupController - minio controller group <command> <url> [<name> [<user or policy>...]]
+func runGroupController(c *cli.Context) {
+	args := c.Args().Tail()
+	command, url := args.First(), args.Tail().First()
+	if command == "list" {
+		groups, err := controller.ListGroups(url)
+		if err != nil {
+			Fatalln(err)
+		}
+		Println(string(groups))
+		return
+	}
+	if len(args) < 3 {
+		cli.ShowCommandHelpAndExit(c, "controller", 1) // last argument is exit code
+	}
+	name, names := args.Tail().Tail().First(), args.Tail().Tail().Tail()
+	var err error
+	switch command {
+	case "create":
+		err = controller.CreateGroup(url, name, names)
+	case "delete":
+		err = controller.DeleteGroup(url, name)
+	case "add":
+		err = controller.AddGroupMembers(url, name, names)
+	case "remove":
+		err = controller.RemoveGroupMembers(url, name, names)
+	case "attach":
+		err = controller.AttachGroupPolicies(url, name, names)
+	case "detach":
+		err = controller.DetachGroupPolicies(url, name, names)
+	default:
+		cli.ShowCommandHelpAndExit(c, "controller", 1) // last argument is exit code
+	}
+	if err != nil {
+		Fatalln(err)
+	}
+}
+
+// runPolicyController - minio controller policy <command> <url> [<name> [<file>]]
+func runPolicyController(c *cli.Context) {
+	args := c.Args().Tail()
+	command, url := args.First(), args.Tail().First()
+	if command == "list" {
+		policies, err := controller.ListPolicies(url)
+		if err != nil {
+			Fatalln(err)
+		}
+		Println(string(policies))
+		return
+	}
+	if len(args) < 3 {
+		cli.ShowCommandHelpAndExit(c, "controller", 1) // last argument is exit code
+	}
+	name := args.Tail().Tail().First()
+	switch command {
+	case "put":
+		if len(args) < 4 {
+			cli.ShowCommandHelpAndExit(c, "controller", 1) // last argument is exit code
+		}
+		document, err := ioutil.ReadFile(args.Tail().Tail().Tail().First())
+		if err != nil {
+			Fatalln(err)
+		}
+		if err := controller.PutPolicy(url, name, document); err != nil {
+			Fatalln(err)
+		}
+	case "get":
+		policy, err := controller.GetPolicy(url, name)
+		if err != nil {
+			Fatalln(err)
+		}
+		Println(string(policy))
+	case "delete":
+		if err := controller.DeletePolicy(url, name); err != nil {
+			Fatalln(err)
+		}
+	default:
+		cli.ShowCommandHelpAndExit(c, "controller", 1) // last argument is exit code
+	}
+}
+
 func runController(c *cli.Context) {
 	_, err := user.Current()
 	if err != nil {
@@ -104,7 +281,14 @@ func runController(c *cli.Context) {
 	if len(c.Args()) < 2 || c.Args().First() == "help" {
 		cli.ShowCommandHelpAndExit(c, "controller", 1) // last argument is exit code
 	}
+	controller.SetCredentials(c.GlobalString("access-key"), c.GlobalString("secret-key"))
 	switch c.Args().First() {
+	case "admin":
+		admin, err := auth.CreateUser(c.Args().Tail().First(), []string{auth.AdminPolicy})
+		if err != nil {
+			Fatalln(err)
+		}
+		Printf("Access key: %s\nSecret key: %s\n", admin.AccessKeyID, admin.SecretAccessKey)
 	case "disks":
 		disks, err := controller.GetDisks(c.Args().Tail().First())
 		if err != nil {
@@ -129,6 +313,16 @@ func runController(c *cli.Context) {
 			Fatalln(err)
 		}
 		Println(string(keys))
+	case "user":
+		runUserController(c)
+	case "group":
+		runGroupController(c)
+	case "policy":
+		runPolicyController(c)
+	case "trace":
+		if err := controller.Trace(c.Args().Tail().First(), c.Args().Tail().Tail(), os.Stdout); err != nil {
+			Fatalln(err)
+		}
 	case "donut":
 		if len(c.Args()) <= 2 || c.Args().First() == "help" {
 			cli.ShowCommandHelpAndExit(c, "controller", 1) // last argument is exit code
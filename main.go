@@ -48,6 +48,51 @@ var flags = []cli.Flag{
 		Value: 16,
 		Usage: "Limit for total concurrent requests: [DEFAULT: 16]",
 	},
+	cli.IntFlag{
+		Name:  "max-operations",
+		Usage: "Limit for total concurrent operations admitted, rest are queued: [DEFAULT: half of ratelimit]",
+	},
+	cli.DurationFlag{
+		Name:  "queue-timeout",
+		Value: 30 * time.Second,
+		Usage: "Time an operation may wait in queue before it is rejected with SlowDown: [DEFAULT: 30s]",
+	},
+	cli.StringFlag{
+		Name:  "access-log",
+		Value: "access.log",
+		Usage: "File to write the access log to, \"-\" for standard output, empty to disable: [DEFAULT: access.log]",
+	},
+	cli.StringFlag{
+		Name:  "access-log-format",
+		Value: "json",
+		Usage: "Access log format, one of json, combined or s3: [DEFAULT: json]",
+	},
+	cli.IntFlag{
+		Name:  "access-log-max-size",
+		Usage: "Rotate the access log once larger than this many megabytes: [DEFAULT: never]",
+	},
+	cli.DurationFlag{
+		Name:  "access-log-max-age",
+		Usage: "Rotate the access log once older than this: [DEFAULT: never]",
+	},
+	cli.IntFlag{
+		Name:  "access-log-backups",
+		Usage: "Number of rotated access logs to keep: [DEFAULT: all]",
+	},
+	cli.StringFlag{
+		Name:  "audit-log",
+		Usage: "File to write the audit log of mutating requests and RPC calls to: [DEFAULT: disabled]",
+	},
+	cli.StringFlag{
+		Name:   "access-key",
+		Usage:  "Access key controller requests are signed with, managing users, groups and policies and tracing need an admin",
+		EnvVar: "MINIO_ACCESS_KEY",
+	},
+	cli.StringFlag{
+		Name:   "secret-key",
+		Usage:  "Secret key controller requests are signed with",
+		EnvVar: "MINIO_SECRET_KEY",
+	},
 	cli.StringFlag{
 		Name:  "cert",
 		Usage: "Provide your domain certificate",
@@ -47,3 +47,13 @@ func GenerateSecretAccessKey() ([]byte, error) {
 	}
 	return []byte(base64.StdEncoding.EncodeToString(rb))[:MinioSecretID], nil
 }
+
+// GenerateSessionToken - generate random base64 value of session tokens
+func GenerateSessionToken() ([]byte, error) {
+	rb := make([]byte, MinioSessionToken)
+	_, err := rand.Read(rb)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(rb)), nil
+}
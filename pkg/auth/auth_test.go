@@ -17,7 +17,12 @@
 package auth_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/minio/check"
 	"github.com/minio/minio/pkg/auth"
@@ -42,3 +47,78 @@ func (s *MySuite) TestAuth(c *C) {
 	c.Log(string(secretID))
 	c.Log(string(accessID))
 }
+
+func (s *MySuite) TestPolicies(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "auth-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	auth.CustomConfigPath = filepath.Join(root, "users.json")
+	defer func() { auth.CustomConfigPath = "" }()
+
+	for _, document := range []string{"", `{"Statement": []}`, `{"Statement": [{"Effect": "Allow", "Action": "ec2:*", "Resource": "*"}]}`,
+		`{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "arn:aws:ec2:::*"}]}`} {
+		_, err := auth.ParsePolicyDocument([]byte(document))
+		c.Assert(err, Not(IsNil))
+	}
+	c.Assert(auth.PutPolicy("photos", []byte(`{"Statement": [{"Effect": "Allow", "Action": "s3:get?bject", "Resource": "arn:aws:s3:::photos/*.jpg"}]}`)), IsNil)
+	c.Assert(auth.PutPolicy("readwrite:photos", []byte(`{"Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*"}]}`)), Not(IsNil))
+	_, err = auth.CreateUser("reader", []string{"unknown"})
+	c.Assert(err, Not(IsNil))
+	user, err := auth.CreateUser("reader", []string{"photos", "writeonly:uploads"})
+	c.Assert(err, IsNil)
+
+	config, err := auth.LoadConfig()
+	c.Assert(err, IsNil)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:GetObject", "arn:aws:s3:::photos/2015/cat.jpg"), Equals, true)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:GetObject", "arn:aws:s3:::photos/2015/cat.png"), Equals, false)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:PutObject", "arn:aws:s3:::photos/2015/cat.jpg"), Equals, false)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:PutObject", "arn:aws:s3:::uploads/cat.jpg"), Equals, true)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:ListAllMyBuckets", auth.ServiceResource), Equals, false)
+
+	// changes invalidate the cached evaluation
+	c.Assert(auth.CreateGroup("listers", []string{"readonly"}), IsNil)
+	c.Assert(auth.AddGroupMembers("listers", []string{"reader"}), IsNil)
+	config, err = auth.LoadConfig()
+	c.Assert(err, IsNil)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:ListAllMyBuckets", auth.ServiceResource), Equals, true)
+	c.Assert(auth.DisableUser("reader"), IsNil)
+	config, err = auth.LoadConfig()
+	c.Assert(err, IsNil)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:ListAllMyBuckets", auth.ServiceResource), Equals, false)
+
+	// users predating policies are allowed everything
+	config.Users["LEGACY"] = &auth.User{Name: "legacy", AccessKeyID: "LEGACY"}
+	c.Assert(config.IsAllowed("LEGACY", "s3:DeleteBucket", "arn:aws:s3:::photos"), Equals, true)
+	c.Assert(auth.DeleteUser("reader"), IsNil)
+	groups, err := auth.ListGroups()
+	c.Assert(err, IsNil)
+	c.Assert(groups[0].Members, DeepEquals, []string{})
+}
+
+func (s *MySuite) TestWildcardResources(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "auth-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	auth.CustomConfigPath = filepath.Join(root, "users.json")
+	defer func() { auth.CustomConfigPath = "" }()
+
+	// many stars against a long key which almost matches them, every star could be retried at every position
+	stars := "arn:aws:s3:::photos/" + strings.Repeat("a*", 50) + "b"
+	c.Assert(auth.PutPolicy("stars", []byte(`{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "`+stars+`"}]}`)), IsNil)
+	c.Assert(auth.PutPolicy("mixed", []byte(`{"Statement": [{"Effect": "Allow", "Action": "s3:PutObject", "Resource": "arn:aws:s3:::photos/*/cat?*.jpg"}]}`)), IsNil)
+	user, err := auth.CreateUser("reader", []string{"stars", "mixed"})
+	c.Assert(err, IsNil)
+	config, err := auth.LoadConfig()
+	c.Assert(err, IsNil)
+
+	start := time.Now()
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:GetObject", "arn:aws:s3:::photos/"+strings.Repeat("a", 10000)), Equals, false)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:GetObject", "arn:aws:s3:::photos/"+strings.Repeat("a", 10000)+"b"), Equals, true)
+	c.Assert(time.Since(start) < time.Second, Equals, true)
+
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:PutObject", "arn:aws:s3:::photos/2015/cats.jpg"), Equals, true)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:PutObject", "arn:aws:s3:::photos/2015/*/cat*.jpg"), Equals, true)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:PutObject", "arn:aws:s3:::photos/2015/cat.jpg"), Equals, false)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:PutObject", "arn:aws:s3:::photos/cats.jpg"), Equals, false)
+	c.Assert(config.IsAllowed(user.AccessKeyID, "s3:PutObject", "arn:aws:s3:::photos/2015/cats.jpg.png"), Equals, false)
+}
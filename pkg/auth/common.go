@@ -18,10 +18,11 @@ package auth
 
 import "regexp"
 
-// AccessID and SecretID length in bytes
+// AccessID and SecretID length in bytes, session tokens are encoded from this many random bytes
 const (
-	MinioAccessID = 20
-	MinioSecretID = 40
+	MinioAccessID     = 20
+	MinioSecretID     = 40
+	MinioSessionToken = 96
 )
 
 /// helpers
@@ -17,8 +17,10 @@
 package auth
 
 import (
+	"os"
 	"os/user"
 	"path/filepath"
+	"time"
 
 	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/quick"
@@ -29,12 +31,49 @@ type User struct {
 	Name            string
 	AccessKeyID     string
 	SecretAccessKey string
+	// Disabled users are refused, their keys are kept until they are enabled again
+	Disabled bool `json:",omitempty"`
+	// Policies attached to the user, nil for users predating policies, they are allowed everything
+	Policies []string
+	// Session of temporary credentials, nil for users, see AssumeRole
+	Session *Session `json:",omitempty"`
+}
+
+// Session temporary credentials are valid with their token until they expire, they are never allowed
+// more than the user who assumed them
+type Session struct {
+	Token      string
+	Expiration time.Time
+	// Parent name of the user who assumed the session
+	Parent string
+	// Policy further limits the session, nil if it is allowed all its parent is
+	Policy *PolicyDocument `json:",omitempty"`
+}
+
+// IsExpired - whether the session is over
+func (s *Session) IsExpired() bool {
+	return !time.Now().UTC().Before(s.Expiration)
+}
+
+// Group of users, its members inherit its policies
+type Group struct {
+	Name string
+	// Members by user name
+	Members  []string
+	Policies []string
 }
 
 // Config auth keys
 type Config struct {
 	Version string
 	Users   map[string]*User
+	// Groups by name
+	Groups map[string]*Group
+	// Policies stored by name, see PutPolicy
+	Policies map[string]*PolicyDocument
+
+	// modTime of the config file when it was loaded, evaluated policies are cached until it changes
+	modTime time.Time
 }
 
 // getAuthConfigPath get donut config file path
@@ -69,6 +108,7 @@ func SaveConfig(a *Config) error {
 	if err := qc.Save(authConfigPath); err != nil {
 		return iodine.New(err, nil)
 	}
+	invalidatePolicyCache()
 	return nil
 }
 
@@ -87,6 +127,11 @@ func LoadConfig() (*Config, error) {
 	a := &Config{}
 	a.Version = "0.0.1"
 	a.Users = make(map[string]*User)
+	// stat ahead of loading, a change made in between invalidates the cache on the next load
+	st, err := os.Stat(authConfigPath)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
 	qc, err := quick.New(a)
 	if err != nil {
 		return nil, iodine.New(err, nil)
@@ -94,5 +139,13 @@ func LoadConfig() (*Config, error) {
 	if err := qc.Load(authConfigPath); err != nil {
 		return nil, iodine.New(err, nil)
 	}
-	return qc.Data().(*Config), nil
+	a = qc.Data().(*Config)
+	if a.Groups == nil {
+		a.Groups = make(map[string]*Group)
+	}
+	if a.Policies == nil {
+		a.Policies = make(map[string]*PolicyDocument)
+	}
+	a.modTime = st.ModTime()
+	return a, nil
 }
@@ -0,0 +1,127 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// updateGroup - apply update to the group of a name and save the config
+func updateGroup(name string, update func(config *Config, group *Group) error) error {
+	return updateConfig(func(config *Config) error {
+		group, ok := config.Groups[name]
+		if !ok {
+			return iodine.New(fmt.Errorf("Group not found: %s", name), nil)
+		}
+		return update(config, group)
+	})
+}
+
+// CreateGroup - create a group without members with policies attached
+func CreateGroup(name string, policies []string) error {
+	if name == "" {
+		return iodine.New(fmt.Errorf("Missing group name"), nil)
+	}
+	return updateConfig(func(config *Config) error {
+		if _, ok := config.Groups[name]; ok {
+			return iodine.New(fmt.Errorf("Group already exists: %s", name), nil)
+		}
+		attached, err := attachPolicies(config, []string{}, policies)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		config.Groups[name] = &Group{Name: name, Members: []string{}, Policies: attached}
+		return nil
+	})
+}
+
+// DeleteGroup - remove a group, its members lose its policies
+func DeleteGroup(name string) error {
+	return updateGroup(name, func(config *Config, group *Group) error {
+		delete(config.Groups, name)
+		return nil
+	})
+}
+
+// ListGroups - all groups sorted by name
+func ListGroups() ([]*Group, error) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	config, err := loadOrNewConfig()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var names []string
+	for name := range config.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var groups []*Group
+	for _, name := range names {
+		groups = append(groups, config.Groups[name])
+	}
+	return groups, nil
+}
+
+// AddGroupMembers - add users to a group, adding one twice is not an error
+func AddGroupMembers(name string, users []string) error {
+	return updateGroup(name, func(config *Config, group *Group) error {
+		for _, user := range users {
+			if _, err := findUser(config, user); err != nil {
+				return iodine.New(err, nil)
+			}
+			if !containsName(group.Members, user) {
+				group.Members = append(group.Members, user)
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveGroupMembers - remove users from a group
+func RemoveGroupMembers(name string, users []string) error {
+	return updateGroup(name, func(config *Config, group *Group) error {
+		for _, user := range users {
+			if !containsName(group.Members, user) {
+				return iodine.New(fmt.Errorf("User %s is not a member of %s", user, name), nil)
+			}
+		}
+		group.Members = removeNames(group.Members, users)
+		return nil
+	})
+}
+
+// AttachGroupPolicies - attach built in or stored policies to a group
+func AttachGroupPolicies(name string, policies []string) error {
+	return updateGroup(name, func(config *Config, group *Group) error {
+		var err error
+		group.Policies, err = attachPolicies(config, group.Policies, policies)
+		return err
+	})
+}
+
+// DetachGroupPolicies - detach policies from a group
+func DetachGroupPolicies(name string, policies []string) error {
+	return updateGroup(name, func(config *Config, group *Group) error {
+		var err error
+		group.Policies, err = detachPolicies(group.Policies, policies)
+		return err
+	})
+}
@@ -0,0 +1,232 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// Built in policies, each of them can be scoped to a single bucket as <policy>:<bucket>. Policies
+// stored with PutPolicy are named freely otherwise. AdminPolicy allows managing users, groups and
+// policies and tracing API calls, it has no effect scoped to a bucket
+const (
+	ReadWritePolicy = "readwrite"
+	ReadOnlyPolicy  = "readonly"
+	WriteOnlyPolicy = "writeonly"
+	AdminPolicy     = "admin"
+)
+
+// Statement effects
+const (
+	Allow = "Allow"
+	Deny  = "Deny"
+)
+
+// resources are named by ARNs of this prefix followed by <bucket> or <bucket>/<object>
+const resourceARNPrefix = "arn:aws:s3:::"
+
+// ServiceResource - resource of requests about the service rather than a bucket, like listing buckets
+const ServiceResource = resourceARNPrefix + "*"
+
+// builtinActions - actions granted by the built in policies
+var builtinActions = map[string][]string{
+	ReadWritePolicy: {"s3:*"},
+	ReadOnlyPolicy:  {"s3:Get*", "s3:List*"},
+	WriteOnlyPolicy: {"s3:Put*", "s3:Create*", "s3:Delete*", "s3:AbortMultipartUpload"},
+	AdminPolicy:     {"admin:*"},
+}
+
+// StringList - a single string or a list of them, IAM policies take either
+type StringList []string
+
+// UnmarshalJSON - decode a string or a list of strings
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = StringList{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = StringList(list)
+	return nil
+}
+
+// Statement allows or denies actions like s3:GetObject on resources like arn:aws:s3:::photos/*,
+// both may use the wildcards * and ?
+type Statement struct {
+	Sid      string `json:",omitempty"`
+	Effect   string
+	Action   StringList
+	Resource StringList
+}
+
+// PolicyDocument - policy in the IAM JSON grammar
+type PolicyDocument struct {
+	Version   string
+	Statement []Statement
+}
+
+// ParsePolicyDocument - decode and validate a policy in the IAM JSON grammar
+func ParsePolicyDocument(data []byte) (*PolicyDocument, error) {
+	policy := &PolicyDocument{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return policy, nil
+}
+
+// validate - every statement has an effect, actions on s3 and s3 resources
+func (p *PolicyDocument) validate() error {
+	if len(p.Statement) == 0 {
+		return iodine.New(fmt.Errorf("Policy has no statements"), nil)
+	}
+	for i, statement := range p.Statement {
+		if statement.Effect != Allow && statement.Effect != Deny {
+			return iodine.New(fmt.Errorf("Invalid effect in statement %d: %s", i, statement.Effect), nil)
+		}
+		if len(statement.Action) == 0 || len(statement.Resource) == 0 {
+			return iodine.New(fmt.Errorf("Missing action or resource in statement %d", i), nil)
+		}
+		for _, action := range statement.Action {
+			if action != "*" && !strings.HasPrefix(strings.ToLower(action), "s3:") {
+				return iodine.New(fmt.Errorf("Invalid action in statement %d: %s", i, action), nil)
+			}
+		}
+		for _, resource := range statement.Resource {
+			if resource != "*" && !strings.HasPrefix(resource, resourceARNPrefix) {
+				return iodine.New(fmt.Errorf("Invalid resource in statement %d: %s", i, resource), nil)
+			}
+		}
+	}
+	return nil
+}
+
+// builtinPolicy - document of a built in policy like readonly or readonly:photos
+func builtinPolicy(name string) (*PolicyDocument, bool) {
+	access, bucket := name, ""
+	if i := strings.Index(name, ":"); i >= 0 {
+		access, bucket = name[:i], name[i+1:]
+		if bucket == "" {
+			return nil, false
+		}
+	}
+	actions, ok := builtinActions[access]
+	if !ok {
+		return nil, false
+	}
+	resources := StringList{ServiceResource}
+	if bucket != "" {
+		resources = StringList{resourceARNPrefix + bucket, resourceARNPrefix + bucket + "/*"}
+	}
+	return &PolicyDocument{
+		Version:   "2012-10-17",
+		Statement: []Statement{{Effect: Allow, Action: actions, Resource: resources}},
+	}, true
+}
+
+// isBuiltinPolicy - whether a name is taken by the built in policies
+func isBuiltinPolicy(name string) bool {
+	access := strings.SplitN(name, ":", 2)[0]
+	_, ok := builtinActions[access]
+	return ok
+}
+
+// ResourceARN - ARN of a bucket or an object, the service resource if bucket is empty
+func ResourceARN(bucket, object string) string {
+	switch {
+	case bucket == "":
+		return ServiceResource
+	case object == "":
+		return resourceARNPrefix + bucket
+	default:
+		return resourceARNPrefix + bucket + "/" + object
+	}
+}
+
+// wildcardMatch - whether value matches pattern, * matches any run of characters and ? a single one.
+// A mismatch retries the last star with one more character, earlier stars never need to be retried,
+// which bounds the match at len(pattern)*len(value) steps
+func wildcardMatch(pattern, value string) bool {
+	p, v := 0, 0
+	// star is the index of the last star seen, starValue where the value resumes once it is retried
+	star, starValue := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, starValue = p, v
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case star >= 0:
+			starValue++
+			p, v = star+1, starValue
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matches - whether a statement covers action on resource, actions are matched regardless of case
+func (s Statement) matches(action, resource string) bool {
+	var actionMatched bool
+	for _, pattern := range s.Action {
+		if wildcardMatch(strings.ToLower(pattern), strings.ToLower(action)) {
+			actionMatched = true
+			break
+		}
+	}
+	if !actionMatched {
+		return false
+	}
+	for _, pattern := range s.Resource {
+		if wildcardMatch(pattern, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowed - whether statements allow action on resource, any matching Deny overrides all Allows and
+// nothing is allowed without an Allow
+func isAllowed(statements []Statement, action, resource string) bool {
+	var allowed bool
+	for _, statement := range statements {
+		if !statement.matches(action, resource) {
+			continue
+		}
+		if statement.Effect == Deny {
+			return false
+		}
+		allowed = true
+	}
+	return allowed
+}
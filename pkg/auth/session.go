@@ -0,0 +1,77 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// Limits of how long a session lasts
+const (
+	MinSessionDuration     = 15 * time.Minute
+	MaxSessionDuration     = 12 * time.Hour
+	DefaultSessionDuration = time.Hour
+)
+
+// AssumeRole - mint temporary credentials for the user of an access key, they last duration and are
+// further limited by policy unless it is nil. Sessions cannot assume roles themselves, expired sessions
+// are removed along the way
+func AssumeRole(accessKeyID string, policy *PolicyDocument, duration time.Duration) (*User, error) {
+	if duration < MinSessionDuration || duration > MaxSessionDuration {
+		return nil, iodine.New(fmt.Errorf("Session duration out of range: %s", duration), nil)
+	}
+	if policy != nil {
+		if err := policy.validate(); err != nil {
+			return nil, iodine.New(err, nil)
+		}
+	}
+	session := &User{Policies: []string{}}
+	err := updateConfig(func(config *Config) error {
+		parent, ok := config.Users[accessKeyID]
+		if !ok || parent.Disabled || parent.Session != nil {
+			return iodine.New(fmt.Errorf("Access key cannot assume a role: %s", accessKeyID), nil)
+		}
+		for key, user := range config.Users {
+			if user.Session != nil && user.Session.IsExpired() {
+				delete(config.Users, key)
+			}
+		}
+		token, err := GenerateSessionToken()
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		if err := generateKeys(session); err != nil {
+			return iodine.New(err, nil)
+		}
+		session.Name = parent.Name
+		session.Session = &Session{
+			Token:      string(token),
+			Expiration: time.Now().UTC().Add(duration),
+			Parent:     parent.Name,
+			Policy:     policy,
+		}
+		config.Users[session.AccessKeyID] = session
+		return nil
+	})
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return session, nil
+}
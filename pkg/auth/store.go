@@ -0,0 +1,201 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// policyCache holds the statements a user is evaluated against, by access key. It is emptied whenever
+// the config is saved or found changed on disk
+var policyCache = struct {
+	lock       *sync.Mutex
+	modTime    time.Time
+	statements map[string][]Statement
+}{
+	lock:       new(sync.Mutex),
+	statements: make(map[string][]Statement),
+}
+
+func invalidatePolicyCache() {
+	policyCache.lock.Lock()
+	defer policyCache.lock.Unlock()
+	policyCache.statements = make(map[string][]Statement)
+}
+
+// getPolicy - built in or stored policy of a name
+func (c *Config) getPolicy(name string) (*PolicyDocument, error) {
+	if policy, ok := builtinPolicy(name); ok {
+		return policy, nil
+	}
+	if policy, ok := c.Policies[name]; ok {
+		return policy, nil
+	}
+	return nil, iodine.New(fmt.Errorf("Policy not found: %s", name), nil)
+}
+
+// userStatements - statements of the policies of a user and of all its groups, users predating
+// policies and without groups get readwrite
+func (c *Config) userStatements(user *User) []Statement {
+	names := append([]string{}, user.Policies...)
+	var grouped bool
+	for _, group := range c.Groups {
+		if containsName(group.Members, user.Name) {
+			grouped = true
+			names = append(names, group.Policies...)
+		}
+	}
+	if user.Policies == nil && !grouped {
+		names = []string{ReadWritePolicy}
+	}
+	var statements []Statement
+	for _, name := range names {
+		// policies are never removed while attached, an unknown one was edited out of the config
+		policy, err := c.getPolicy(name)
+		if err != nil {
+			continue
+		}
+		statements = append(statements, policy.Statement...)
+	}
+	return statements
+}
+
+// cachedStatements - statements of a user, evaluated once per access key and config
+func (c *Config) cachedStatements(user *User) []Statement {
+	// configs never loaded from disk cannot be told apart, they are not cached
+	if c.modTime.IsZero() {
+		return c.userStatements(user)
+	}
+	policyCache.lock.Lock()
+	defer policyCache.lock.Unlock()
+	if !policyCache.modTime.Equal(c.modTime) {
+		policyCache.modTime = c.modTime
+		policyCache.statements = make(map[string][]Statement)
+	}
+	statements, ok := policyCache.statements[user.AccessKeyID]
+	if !ok {
+		statements = c.userStatements(user)
+		policyCache.statements[user.AccessKeyID] = statements
+	}
+	return statements
+}
+
+// IsEnabled - whether the credentials of a user may be used, sessions only as long as their parent
+// exists and is enabled
+func (c *Config) IsEnabled(user *User) bool {
+	if user.Disabled {
+		return false
+	}
+	if user.Session == nil {
+		return true
+	}
+	parent, err := findUser(c, user.Session.Parent)
+	return err == nil && !parent.Disabled
+}
+
+// IsAllowed - whether the user of an access key may do action on resource, its own policies and those
+// of its groups are evaluated together. Sessions are allowed what both their parent and their policy
+// allow. Disabled and unknown users and expired sessions are allowed nothing
+func (c *Config) IsAllowed(accessKeyID, action, resource string) bool {
+	user, ok := c.Users[accessKeyID]
+	if !ok || user.Disabled {
+		return false
+	}
+	if user.Session == nil {
+		return isAllowed(c.cachedStatements(user), action, resource)
+	}
+	if user.Session.IsExpired() {
+		return false
+	}
+	parent, err := findUser(c, user.Session.Parent)
+	if err != nil || parent.Disabled || !isAllowed(c.cachedStatements(parent), action, resource) {
+		return false
+	}
+	return user.Session.Policy == nil || isAllowed(user.Session.Policy.Statement, action, resource)
+}
+
+// PutPolicy - store a policy in the IAM JSON grammar, a stored policy of the same name is replaced
+func PutPolicy(name string, document []byte) error {
+	if name == "" || isBuiltinPolicy(name) {
+		return iodine.New(fmt.Errorf("Invalid policy name: %s", name), nil)
+	}
+	policy, err := ParsePolicyDocument(document)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	return updateConfig(func(config *Config) error {
+		config.Policies[name] = policy
+		return nil
+	})
+}
+
+// GetPolicy - built in or stored policy of a name
+func GetPolicy(name string) (*PolicyDocument, error) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	config, err := loadOrNewConfig()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	policy, err := config.getPolicy(name)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return policy, nil
+}
+
+// ListPolicies - names of all stored policies, sorted
+func ListPolicies() ([]string, error) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	config, err := loadOrNewConfig()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	names := []string{}
+	for name := range config.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeletePolicy - remove a stored policy, it must not be attached to any user or group
+func DeletePolicy(name string) error {
+	return updateConfig(func(config *Config) error {
+		if _, ok := config.Policies[name]; !ok {
+			return iodine.New(fmt.Errorf("Policy not found: %s", name), nil)
+		}
+		for _, user := range config.Users {
+			if containsName(user.Policies, name) {
+				return iodine.New(fmt.Errorf("Policy %s is attached to user %s", name, user.Name), nil)
+			}
+		}
+		for _, group := range config.Groups {
+			if containsName(group.Policies, name) {
+				return iodine.New(fmt.Errorf("Policy %s is attached to group %s", name, group.Name), nil)
+			}
+		}
+		delete(config.Policies, name)
+		return nil
+	})
+}
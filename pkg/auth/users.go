@@ -0,0 +1,270 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// configLock serializes changes to users, groups and policies, each of them loads, changes and saves
+// the config
+var configLock = new(sync.Mutex)
+
+// loadOrNewConfig - load the config, an empty one if it was never saved
+func loadOrNewConfig() (*Config, error) {
+	config, err := LoadConfig()
+	if err == nil {
+		return config, nil
+	}
+	if !os.IsNotExist(iodine.ToError(err)) {
+		return nil, iodine.New(err, nil)
+	}
+	return &Config{
+		Version:  "0.0.1",
+		Users:    make(map[string]*User),
+		Groups:   make(map[string]*Group),
+		Policies: make(map[string]*PolicyDocument),
+	}, nil
+}
+
+// findUser - user of a name, users are stored by their access key along with their sessions
+func findUser(config *Config, name string) (*User, error) {
+	for _, user := range config.Users {
+		if user.Session == nil && user.Name == name {
+			return user, nil
+		}
+	}
+	return nil, iodine.New(fmt.Errorf("User not found: %s", name), nil)
+}
+
+// updateConfig - apply update to the config and save it, nothing is saved if update fails
+func updateConfig(update func(config *Config) error) error {
+	configLock.Lock()
+	defer configLock.Unlock()
+	config, err := loadOrNewConfig()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := update(config); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := SaveConfig(config); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// updateUser - apply update to the user of a name and save the config
+func updateUser(name string, update func(config *Config, user *User) error) (*User, error) {
+	var updated *User
+	err := updateConfig(func(config *Config) error {
+		user, err := findUser(config, name)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		updated = user
+		return update(config, user)
+	})
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return updated, nil
+}
+
+// generateKeys - new access key and secret for user
+func generateKeys(user *User) error {
+	accessKeyID, err := GenerateAccessKeyID()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	secretAccessKey, err := GenerateSecretAccessKey()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	user.AccessKeyID = string(accessKeyID)
+	user.SecretAccessKey = string(secretAccessKey)
+	return nil
+}
+
+// CreateUser - create a user with new keys and policies attached, a user without policies is only
+// allowed what its groups are
+func CreateUser(name string, policies []string) (*User, error) {
+	if name == "" {
+		return nil, iodine.New(fmt.Errorf("Missing user name"), nil)
+	}
+	user := &User{Name: name, Policies: []string{}}
+	err := updateConfig(func(config *Config) error {
+		if _, err := findUser(config, name); err == nil {
+			return iodine.New(fmt.Errorf("User already exists: %s", name), nil)
+		}
+		var err error
+		if user.Policies, err = attachPolicies(config, user.Policies, policies); err != nil {
+			return iodine.New(err, nil)
+		}
+		if err := generateKeys(user); err != nil {
+			return iodine.New(err, nil)
+		}
+		config.Users[user.AccessKeyID] = user
+		return nil
+	})
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return user, nil
+}
+
+// ListUsers - all users sorted by name
+func ListUsers() ([]*User, error) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	config, err := loadOrNewConfig()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var users []*User
+	for _, user := range config.Users {
+		if user.Session == nil {
+			users = append(users, user)
+		}
+	}
+	sort.Sort(byName(users))
+	return users, nil
+}
+
+type byName []*User
+
+func (u byName) Len() int           { return len(u) }
+func (u byName) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
+func (u byName) Less(i, j int) bool { return u[i].Name < u[j].Name }
+
+// EnableUser - accept requests of a disabled user again
+func EnableUser(name string) error {
+	_, err := updateUser(name, func(config *Config, user *User) error {
+		user.Disabled = false
+		return nil
+	})
+	return err
+}
+
+// DisableUser - refuse all requests of a user, its keys are kept
+func DisableUser(name string) error {
+	_, err := updateUser(name, func(config *Config, user *User) error {
+		user.Disabled = true
+		return nil
+	})
+	return err
+}
+
+// DeleteUser - remove a user, its keys and its sessions, it is removed from its groups
+func DeleteUser(name string) error {
+	_, err := updateUser(name, func(config *Config, user *User) error {
+		for accessKeyID, session := range config.Users {
+			if session.Session != nil && session.Session.Parent == name {
+				delete(config.Users, accessKeyID)
+			}
+		}
+		delete(config.Users, user.AccessKeyID)
+		for _, group := range config.Groups {
+			group.Members = removeNames(group.Members, []string{name})
+		}
+		return nil
+	})
+	return err
+}
+
+// RotateUserKeys - replace the keys of a user, its old keys stop working right away
+func RotateUserKeys(name string) (*User, error) {
+	return updateUser(name, func(config *Config, user *User) error {
+		delete(config.Users, user.AccessKeyID)
+		if err := generateKeys(user); err != nil {
+			return iodine.New(err, nil)
+		}
+		config.Users[user.AccessKeyID] = user
+		return nil
+	})
+}
+
+// AttachUserPolicies - attach built in or stored policies to a user, attaching one twice is not an error
+func AttachUserPolicies(name string, policies []string) error {
+	_, err := updateUser(name, func(config *Config, user *User) error {
+		var err error
+		user.Policies, err = attachPolicies(config, user.Policies, policies)
+		return err
+	})
+	return err
+}
+
+// DetachUserPolicies - detach policies from a user, a user without policies is only allowed what its
+// groups are
+func DetachUserPolicies(name string, policies []string) error {
+	_, err := updateUser(name, func(config *Config, user *User) error {
+		var err error
+		user.Policies, err = detachPolicies(user.Policies, policies)
+		return err
+	})
+	return err
+}
+
+// attachPolicies - attached with policies added, every one of them must exist
+func attachPolicies(config *Config, attached, policies []string) ([]string, error) {
+	for _, policy := range policies {
+		if _, err := config.getPolicy(policy); err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		if !containsName(attached, policy) {
+			attached = append(attached, policy)
+		}
+	}
+	return attached, nil
+}
+
+// detachPolicies - attached with policies removed, every one of them must be attached. The result is
+// never nil, nil policies are kept for users predating them
+func detachPolicies(attached, policies []string) ([]string, error) {
+	for _, policy := range policies {
+		if !containsName(attached, policy) {
+			return nil, iodine.New(fmt.Errorf("Policy is not attached: %s", policy), nil)
+		}
+	}
+	return removeNames(attached, policies), nil
+}
+
+// containsName - whether names contains name
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// removeNames - names without any of removed, never nil
+func removeNames(names, removed []string) []string {
+	kept := []string{}
+	for _, name := range names {
+		if !containsName(removed, name) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
@@ -21,7 +21,6 @@ import (
 	"net/http"
 
 	jsonrpc "github.com/gorilla/rpc/v2/json"
-	"github.com/minio/minio/pkg/auth"
 	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/server/rpc"
 )
@@ -98,7 +97,8 @@ func GetSysInfo(url string) ([]byte, error) {
 	return json.MarshalIndent(reply, "", "\t")
 }
 
-// GetAuthKeys get access key id and secret access key
+// GetAuthKeys get a newly generated access key id and secret access key, they belong to no user, see
+// CreateUser
 func GetAuthKeys(url string) ([]byte, error) {
 	op := RPCOps{
 		Method:  "Auth.Get",
@@ -117,17 +117,6 @@ func GetAuthKeys(url string) ([]byte, error) {
 	if err := jsonrpc.DecodeClientResponse(resp.Body, &reply); err != nil {
 		return nil, iodine.New(err, nil)
 	}
-	authConfig := &auth.Config{}
-	authConfig.Version = "0.0.1"
-	authConfig.Users = make(map[string]*auth.User)
-	user := &auth.User{}
-	user.Name = "testuser"
-	user.AccessKeyID = reply.AccessKeyID
-	user.SecretAccessKey = reply.SecretAccessKey
-	authConfig.Users[reply.AccessKeyID] = user
-	if err := auth.SaveConfig(authConfig); err != nil {
-		return nil, iodine.New(err, nil)
-	}
 	return json.MarshalIndent(reply, "", "\t")
 }
 
@@ -0,0 +1,69 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/server/rpc"
+)
+
+// CreateGroup create a group with policies attached on the server at given url
+func CreateGroup(url, name string, policies []string) error {
+	var reply rpc.Reply
+	return callRPC(url, "Group.Create", rpc.GroupArgs{Name: name, Policies: policies}, &reply)
+}
+
+// ListGroups list groups of the server at given url
+func ListGroups(url string) ([]byte, error) {
+	var reply rpc.ListGroupsReply
+	if err := callRPC(url, "Group.List", rpc.Args{Request: ""}, &reply); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return json.MarshalIndent(reply, "", "\t")
+}
+
+// DeleteGroup delete a group of the server at given url
+func DeleteGroup(url, name string) error {
+	var reply rpc.Reply
+	return callRPC(url, "Group.Delete", rpc.GroupArgs{Name: name}, &reply)
+}
+
+// AddGroupMembers add users to a group of the server at given url
+func AddGroupMembers(url, name string, users []string) error {
+	var reply rpc.Reply
+	return callRPC(url, "Group.AddMembers", rpc.GroupArgs{Name: name, Members: users}, &reply)
+}
+
+// RemoveGroupMembers remove users from a group of the server at given url
+func RemoveGroupMembers(url, name string, users []string) error {
+	var reply rpc.Reply
+	return callRPC(url, "Group.RemoveMembers", rpc.GroupArgs{Name: name, Members: users}, &reply)
+}
+
+// AttachGroupPolicies attach built in or stored policies to a group of the server at given url
+func AttachGroupPolicies(url, name string, policies []string) error {
+	var reply rpc.Reply
+	return callRPC(url, "Group.AttachPolicies", rpc.GroupArgs{Name: name, Policies: policies}, &reply)
+}
+
+// DetachGroupPolicies detach policies from a group of the server at given url
+func DetachGroupPolicies(url, name string, policies []string) error {
+	var reply rpc.Reply
+	return callRPC(url, "Group.DetachPolicies", rpc.GroupArgs{Name: name, Policies: policies}, &reply)
+}
@@ -0,0 +1,54 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/server/rpc"
+)
+
+// PutPolicy store a policy in the IAM JSON grammar on the server at given url
+func PutPolicy(url, name string, document []byte) error {
+	var reply rpc.Reply
+	return callRPC(url, "Policy.Put", rpc.PolicyArgs{Name: name, Document: string(document)}, &reply)
+}
+
+// GetPolicy get a built in or stored policy of the server at given url
+func GetPolicy(url, name string) ([]byte, error) {
+	var reply rpc.PolicyReply
+	if err := callRPC(url, "Policy.Get", rpc.PolicyArgs{Name: name}, &reply); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return json.MarshalIndent(reply, "", "\t")
+}
+
+// ListPolicies list stored policies of the server at given url
+func ListPolicies(url string) ([]byte, error) {
+	var reply rpc.ListPoliciesReply
+	if err := callRPC(url, "Policy.List", rpc.Args{Request: ""}, &reply); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return json.MarshalIndent(reply, "", "\t")
+}
+
+// DeletePolicy delete a stored policy of the server at given url
+func DeletePolicy(url, name string) error {
+	var reply rpc.Reply
+	return callRPC(url, "Policy.Delete", rpc.PolicyArgs{Name: name}, &reply)
+}
@@ -18,12 +18,43 @@ package controller
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/rpc/v2/json"
+	"github.com/minio/minio/pkg/donut"
 	"github.com/minio/minio/pkg/iodine"
 )
 
+// credentials requests are signed with, see SetCredentials
+var credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+}
+
+// SetCredentials - sign requests from here on with the keys of a user, managing users, groups and
+// policies and tracing need a user allowed the admin policy. Empty keys send requests unsigned
+func SetCredentials(accessKeyID, secretAccessKey string) {
+	credentials.accessKeyID = accessKeyID
+	credentials.secretAccessKey = secretAccessKey
+}
+
+// signRequest - sign a request over its body like S3 requests are, unless no credentials are set
+func signRequest(req *http.Request, body []byte) {
+	if credentials.accessKeyID == "" {
+		return
+	}
+	signature := &donut.Signature{
+		AccessKeyID:     credentials.accessKeyID,
+		SecretAccessKey: credentials.secretAccessKey,
+		Request:         req,
+	}
+	sum := sha256.Sum256(body)
+	signature.Sign(hex.EncodeToString(sum[:]), time.Now().UTC())
+}
+
 // RPCOps RPC operation
 type RPCOps struct {
 	Method  string
@@ -49,6 +80,7 @@ func NewRequest(url string, op RPCOps, transport http.RoundTripper) (*RPCRequest
 	rpcReq := &RPCRequest{}
 	rpcReq.req = req
 	rpcReq.req.Header.Set("Content-Type", "application/json")
+	signRequest(rpcReq.req, params)
 	if transport == nil {
 		transport = http.DefaultTransport
 	}
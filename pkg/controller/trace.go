@@ -0,0 +1,118 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/server/api"
+)
+
+// Trace print API calls served by the server at given url as they happen, until the server goes away.
+// filters are of the form bucket=name, status=404 or status=5xx and accesskey=key, headers prints
+// request and response headers as well. The server only streams to admins, see SetCredentials
+func Trace(traceURL string, filters []string, w io.Writer) error {
+	u, err := url.Parse(traceURL)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	query := u.Query()
+	for _, filter := range filters {
+		if filter == "headers" {
+			query.Set("headers", "true")
+			continue
+		}
+		keyValue := strings.SplitN(filter, "=", 2)
+		if len(keyValue) != 2 {
+			return iodine.New(fmt.Errorf("Invalid trace filter: %s", filter), nil)
+		}
+		switch keyValue[0] {
+		case "bucket", "status", "accesskey":
+			query.Set(keyValue[0], keyValue[1])
+		default:
+			return iodine.New(fmt.Errorf("Unknown trace filter: %s", keyValue[0]), nil)
+		}
+	}
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	// traces are only streamed to admins
+	signRequest(req, nil)
+	resp, err := http.DefaultClient.Do(req)
+	defer closeResp(resp)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return iodine.New(fmt.Errorf("Trace failed: %s", resp.Status), nil)
+	}
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var entry api.TraceEntry
+		if err := decoder.Decode(&entry); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return iodine.New(err, nil)
+		}
+		if _, err := io.WriteString(w, formatTraceEntry(entry)); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+}
+
+// formatTraceEntry - a traced call as a line, followed by its headers if they were traced
+func formatTraceEntry(entry api.TraceEntry) string {
+	var s string
+	if entry.Dropped > 0 {
+		s += fmt.Sprintf("... %d calls dropped\n", entry.Dropped)
+	}
+	s += fmt.Sprintf("%s %s %s %s %d %s in:%d out:%d", entry.Time.Format("15:04:05.000"), entry.RequestID,
+		entry.Method, entry.URI, entry.Status, entry.Duration, entry.BytesReceived, entry.BytesSent)
+	if entry.AccessKey != "" {
+		s += " key:" + entry.AccessKey
+	}
+	s += "\n"
+	s += formatTraceHeaders("> ", entry.RequestHeaders)
+	s += formatTraceHeaders("< ", entry.ResponseHeaders)
+	return s
+}
+
+// formatTraceHeaders - headers one per line in a stable order
+func formatTraceHeaders(prefix string, headers http.Header) string {
+	var keys []string
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var s string
+	for _, key := range keys {
+		for _, value := range headers[key] {
+			s += prefix + key + ": " + value + "\n"
+		}
+	}
+	return s
+}
@@ -0,0 +1,104 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	jsonrpc "github.com/gorilla/rpc/v2/json"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/server/rpc"
+)
+
+// callRPC - call a method of the server at given url
+func callRPC(url, method string, args interface{}, reply interface{}) error {
+	op := RPCOps{
+		Method:  method,
+		Request: args,
+	}
+	req, err := NewRequest(url, op, http.DefaultTransport)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	resp, err := req.Do()
+	defer closeResp(resp)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := jsonrpc.DecodeClientResponse(resp.Body, reply); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// CreateUser create a user with policies attached on the server at given url, replies with its keys
+func CreateUser(url, name string, policies []string) ([]byte, error) {
+	var reply rpc.UserReply
+	if err := callRPC(url, "User.Create", rpc.UserArgs{Name: name, Policies: policies}, &reply); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return json.MarshalIndent(reply, "", "\t")
+}
+
+// ListUsers list users of the server at given url
+func ListUsers(url string) ([]byte, error) {
+	var reply rpc.ListUsersReply
+	if err := callRPC(url, "User.List", rpc.Args{Request: ""}, &reply); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return json.MarshalIndent(reply, "", "\t")
+}
+
+// EnableUser enable a user of the server at given url
+func EnableUser(url, name string) error {
+	var reply rpc.Reply
+	return callRPC(url, "User.Enable", rpc.UserArgs{Name: name}, &reply)
+}
+
+// DisableUser disable a user of the server at given url
+func DisableUser(url, name string) error {
+	var reply rpc.Reply
+	return callRPC(url, "User.Disable", rpc.UserArgs{Name: name}, &reply)
+}
+
+// DeleteUser delete a user of the server at given url
+func DeleteUser(url, name string) error {
+	var reply rpc.Reply
+	return callRPC(url, "User.Delete", rpc.UserArgs{Name: name}, &reply)
+}
+
+// RotateUserKeys replace the keys of a user of the server at given url, replies with its new keys
+func RotateUserKeys(url, name string) ([]byte, error) {
+	var reply rpc.UserReply
+	if err := callRPC(url, "User.RotateKeys", rpc.UserArgs{Name: name}, &reply); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return json.MarshalIndent(reply, "", "\t")
+}
+
+// AttachUserPolicies attach built in or stored policies to a user of the server at given url
+func AttachUserPolicies(url, name string, policies []string) error {
+	var reply rpc.Reply
+	return callRPC(url, "User.AttachPolicies", rpc.UserArgs{Name: name, Policies: policies}, &reply)
+}
+
+// DetachUserPolicies detach policies from a user of the server at given url
+func DetachUserPolicies(url, name string, policies []string) error {
+	var reply rpc.Reply
+	return callRPC(url, "User.DetachPolicies", rpc.UserArgs{Name: name, Policies: policies}, &reply)
+}
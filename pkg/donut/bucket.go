@@ -21,10 +21,12 @@ import (
 	"fmt"
 	"hash"
 	"io"
+	"log"
+	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"crypto/md5"
@@ -33,12 +35,16 @@ import (
 
 	"github.com/minio/minio/pkg/crypto/sha256"
 	"github.com/minio/minio/pkg/crypto/sha512"
-	"github.com/minio/minio/pkg/donut/split"
+	"github.com/minio/minio/pkg/donut/index"
 	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/bufpool"
 )
 
 const (
 	blockSize = 10 * 1024 * 1024
+	// parts of multipart uploads are kept in this directory of the bucket slices, object names are
+	// normalized to never nest, so parts cannot collide with objects
+	multipartDir = "$multiparts"
 )
 
 // internal struct carrying bucket specific information
@@ -48,7 +54,6 @@ type bucket struct {
 	time      time.Time
 	donutName string
 	nodes     map[string]node
-	lock      *sync.Mutex
 }
 
 // newBucket - instantiate a new bucket
@@ -70,7 +75,6 @@ func newBucket(bucketName, aclType, donutName string, nodes map[string]node) (bu
 	b.time = t
 	b.donutName = donutName
 	b.nodes = nodes
-	b.lock = new(sync.Mutex)
 
 	metadata := BucketMetadata{}
 	metadata.Version = bucketMetadataVersion
@@ -78,7 +82,6 @@ func newBucket(bucketName, aclType, donutName string, nodes map[string]node) (bu
 	metadata.ACL = BucketACL(aclType)
 	metadata.Created = t
 	metadata.Metadata = make(map[string]string)
-	metadata.BucketObjects = make(map[string]interface{})
 
 	return b, metadata, nil
 }
@@ -86,107 +89,34 @@ func newBucket(bucketName, aclType, donutName string, nodes map[string]node) (bu
 func (b bucket) getBucketName() string {
 	return b.name
 }
-func (b bucket) getBucketMetadataReaders() ([]io.ReadCloser, error) {
-	var readers []io.ReadCloser
-	for _, node := range b.nodes {
-		disks, err := node.ListDisks()
-		if err != nil {
-			return nil, iodine.New(err, nil)
-		}
-		readers = make([]io.ReadCloser, len(disks))
-		for order, disk := range disks {
-			bucketMetaDataReader, err := disk.OpenFile(filepath.Join(b.donutName, bucketMetadataConfig))
-			if err != nil {
-				return nil, iodine.New(err, nil)
-			}
-			readers[order] = bucketMetaDataReader
-		}
-	}
-	return readers, nil
-}
-
-func (b bucket) getBucketMetadata() (*AllBuckets, error) {
-	metadata := new(AllBuckets)
-	readers, err := b.getBucketMetadataReaders()
-	if err != nil {
-		return nil, iodine.New(err, nil)
-	}
-	for _, reader := range readers {
-		defer reader.Close()
-	}
-	for _, reader := range readers {
-		jenc := json.NewDecoder(reader)
-		if err := jenc.Decode(metadata); err != nil {
-			return nil, iodine.New(err, nil)
-		}
-		return metadata, nil
-	}
-	return nil, iodine.New(InvalidArgument{}, nil)
-}
 
 // GetObjectMetadata - get metadata for an object
 func (b bucket) GetObjectMetadata(objectName string) (ObjectMetadata, error) {
-	b.lock.Lock()
-	defer b.lock.Unlock()
 	return b.readObjectMetadata(objectName)
 }
 
-// ListObjects - list all objects
+// ListObjects - list objects from the bucket index, objects and common prefixes both count towards maxkeys
 func (b bucket) ListObjects(prefix, marker, delimiter string, maxkeys int) (ListObjectsResults, error) {
-	b.lock.Lock()
-	defer b.lock.Unlock()
 	if maxkeys <= 0 {
 		maxkeys = 1000
 	}
-	var isTruncated bool
-	var objects []string
-	bucketMetadata, err := b.getBucketMetadata()
+	objectIndexes, err := b.getObjectIndexes()
 	if err != nil {
 		return ListObjectsResults{}, iodine.New(err, nil)
 	}
-	for objectName := range bucketMetadata.Buckets[b.getBucketName()].BucketObjects {
-		if strings.HasPrefix(objectName, strings.TrimSpace(prefix)) {
-			if objectName > marker {
-				objects = append(objects, objectName)
-			}
-		}
-	}
-	if strings.TrimSpace(prefix) != "" {
-		objects = TrimPrefix(objects, prefix)
-	}
-	var prefixes []string
-	var filteredObjects []string
-	filteredObjects = objects
-	if strings.TrimSpace(delimiter) != "" {
-		filteredObjects = HasNoDelimiter(objects, delimiter)
-		prefixes = HasDelimiter(objects, delimiter)
-		prefixes = SplitDelimiter(prefixes, delimiter)
-		prefixes = SortU(prefixes)
-	}
+	// every disk carries a copy of the index, fall back to the next one if it cannot be read
+	var listObjects ListObjectsResults
 	var results []string
-	var commonPrefixes []string
-
-	for _, commonPrefix := range prefixes {
-		commonPrefixes = append(commonPrefixes, prefix+commonPrefix)
-	}
-	filteredObjects = RemoveDuplicates(filteredObjects)
-	sort.Strings(filteredObjects)
-	for _, objectName := range filteredObjects {
-		if len(results) >= maxkeys {
-			isTruncated = true
+	for _, objectIndex := range objectIndexes {
+		if listObjects, results, err = listObjectIndex(objectIndex, prefix, marker, delimiter, maxkeys); err == nil {
 			break
 		}
-		results = append(results, prefix+objectName)
 	}
-	results = RemoveDuplicates(results)
-	commonPrefixes = RemoveDuplicates(commonPrefixes)
-	sort.Strings(commonPrefixes)
+	if err != nil {
+		return ListObjectsResults{}, iodine.New(err, nil)
+	}
 
-	listObjects := ListObjectsResults{}
 	listObjects.Objects = make(map[string]ObjectMetadata)
-	listObjects.CommonPrefixes = commonPrefixes
-	listObjects.IsTruncated = isTruncated
-
 	for _, objectName := range results {
 		objMetadata, err := b.readObjectMetadata(normalizeObjectName(objectName))
 		if err != nil {
@@ -197,19 +127,62 @@ func (b bucket) ListObjects(prefix, marker, delimiter string, maxkeys int) (List
 	return listObjects, nil
 }
 
+// listObjectIndex - list object names and common prefixes from an index. Once a common prefix is found
+// the walk seeks past all the objects under it instead of visiting them
+func listObjectIndex(objectIndex index.Index, prefix, marker, delimiter string, maxkeys int) (ListObjectsResults, []string, error) {
+	var isTruncated bool
+	var nextMarker string
+	var results []string
+	commonPrefixes := []string{}
+	for seek := true; seek; {
+		seek = false
+		walkFn := func(objectName string) bool {
+			if delimiter != "" {
+				if i := strings.Index(objectName[len(prefix):], delimiter); i >= 0 {
+					commonPrefix := objectName[:len(prefix)+i+len(delimiter)]
+					// a common prefix given as marker was listed already
+					if commonPrefix != marker {
+						if len(results)+len(commonPrefixes) >= maxkeys {
+							isTruncated = true
+							return false
+						}
+						commonPrefixes = append(commonPrefixes, commonPrefix)
+						nextMarker = commonPrefix
+					}
+					// keys are valid utf-8 and never carry a 0xff byte, this sorts after every key under the prefix
+					marker = commonPrefix + "\xff"
+					seek = true
+					return false
+				}
+			}
+			if len(results)+len(commonPrefixes) >= maxkeys {
+				isTruncated = true
+				return false
+			}
+			results = append(results, objectName)
+			nextMarker = objectName
+			return true
+		}
+		if err := objectIndex.Walk(prefix, marker, walkFn); err != nil {
+			return ListObjectsResults{}, nil, iodine.New(err, nil)
+		}
+	}
+
+	listObjects := ListObjectsResults{}
+	listObjects.CommonPrefixes = commonPrefixes
+	listObjects.IsTruncated = isTruncated
+	if isTruncated {
+		listObjects.NextMarker = nextMarker
+	}
+	return listObjects, results, nil
+}
+
 // ReadObject - open an object to read
 func (b bucket) ReadObject(objectName string) (reader io.ReadCloser, size int64, err error) {
-	b.lock.Lock()
-	defer b.lock.Unlock()
 	reader, writer := io.Pipe()
-	// get list of objects
-	bucketMetadata, err := b.getBucketMetadata()
-	if err != nil {
-		return nil, 0, iodine.New(err, nil)
-	}
 	// check if object exists
-	if _, ok := bucketMetadata.Buckets[b.getBucketName()].BucketObjects[objectName]; !ok {
-		return nil, 0, iodine.New(ObjectNotFound{Object: objectName}, nil)
+	if err := b.isObjectIndexed(objectName); err != nil {
+		return nil, 0, iodine.New(err, nil)
 	}
 	objMetadata, err := b.readObjectMetadata(normalizeObjectName(objectName))
 	if err != nil {
@@ -220,10 +193,27 @@ func (b bucket) ReadObject(objectName string) (reader io.ReadCloser, size int64,
 	return reader, objMetadata.Size, nil
 }
 
+// ReadObjectRange - read a range of an object, only erasure blocks covering the range are decoded
+func (b bucket) ReadObjectRange(objectName string, start, length int64) (reader io.ReadCloser, err error) {
+	reader, writer := io.Pipe()
+	// check if object exists
+	if err := b.isObjectIndexed(objectName); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	objMetadata, err := b.readObjectMetadata(normalizeObjectName(objectName))
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	if start < 0 || length < 0 || start+length > objMetadata.Size {
+		return nil, iodine.New(InvalidRange{Start: start, Length: length}, nil)
+	}
+	// read and reply back to GetPartialObject() request in a go-routine
+	go b.readObjectDataRange(normalizeObjectName(objectName), writer, objMetadata, start, length)
+	return reader, nil
+}
+
 // WriteObject - write a new object into bucket
 func (b bucket) WriteObject(objectName string, objectData io.Reader, expectedMD5Sum string, metadata map[string]string, signature *Signature) (ObjectMetadata, error) {
-	b.lock.Lock()
-	defer b.lock.Unlock()
 	if objectName == "" || objectData == nil {
 		return ObjectMetadata{}, iodine.New(InvalidArgument{}, nil)
 	}
@@ -237,28 +227,82 @@ func (b bucket) WriteObject(objectName string, objectData io.Reader, expectedMD5
 	objMetadata := ObjectMetadata{}
 	objMetadata.Version = objectMetadataVersion
 	objMetadata.Created = time.Now().UTC()
+	if err := b.writeObjectSlices(writers, objectData, &objMetadata, sumMD5, sum256, sum512); err != nil {
+		CleanupWritersOnError(writers)
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	objMetadata.Bucket = b.getBucketName()
+	objMetadata.Object = objectName
+	if contentLength, ok := metadata["contentLength"]; ok && contentLength != strconv.FormatInt(objMetadata.Size, 10) {
+		CleanupWritersOnError(writers)
+		return ObjectMetadata{}, iodine.New(IncompleteBody{Bucket: b.getBucketName(), Object: objectName}, nil)
+	}
+	dataMD5sum := sumMD5.Sum(nil)
+	dataSHA512sum := sum512.Sum(nil)
+	if signature != nil {
+		ok, err := signature.DoesSignatureMatch(hex.EncodeToString(sum256.Sum(nil)))
+		if err != nil {
+			// error occurred while doing signature calculation, we return and also cleanup any temporary writers.
+			CleanupWritersOnError(writers)
+			return ObjectMetadata{}, iodine.New(err, nil)
+		}
+		if !ok {
+			// purge all writers, when control flow reaches here
+			//
+			// Signature mismatch occurred all temp files to be removed and all data purged.
+			CleanupWritersOnError(writers)
+			return ObjectMetadata{}, iodine.New(SignatureDoesNotMatch{}, nil)
+		}
+	}
+	objMetadata.MD5Sum = hex.EncodeToString(dataMD5sum)
+	objMetadata.SHA512Sum = hex.EncodeToString(dataSHA512sum)
+
+	// Verify if the written object is equal to what is expected, only if it is requested as such
+	if strings.TrimSpace(expectedMD5Sum) != "" {
+		if err := b.isMD5SumEqual(strings.TrimSpace(expectedMD5Sum), objMetadata.MD5Sum); err != nil {
+			return ObjectMetadata{}, iodine.New(err, nil)
+		}
+	}
+	objMetadata.Metadata = metadata
+	// write object specific metadata
+	if err := b.writeObjectMetadata(normalizeObjectName(objectName), objMetadata); err != nil {
+		// purge all writers, when control flow reaches here
+		CleanupWritersOnError(writers)
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	// close all writers, when control flow reaches here
+	for _, writer := range writers {
+		writer.Close()
+	}
+	// make the object visible in the bucket index
+	if err := b.indexObject(objectName); err != nil {
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	return objMetadata, nil
+}
+
+// writeObjectSlices - write object data onto its slices, erasure coded unless there is a single slice,
+// and record its size and erasure layout in the object metadata
+func (b bucket) writeObjectSlices(writers []io.WriteCloser, objectData io.Reader, objMetadata *ObjectMetadata, sumMD5, sum256, sum512 hash.Hash) error {
 	// if total writers are only '1' do not compute erasure
 	switch len(writers) == 1 {
 	case true:
 		mw := io.MultiWriter(writers[0], sumMD5, sum256, sum512)
 		totalLength, err := io.Copy(mw, objectData)
 		if err != nil {
-			CleanupWritersOnError(writers)
-			return ObjectMetadata{}, iodine.New(err, nil)
+			return iodine.New(err, nil)
 		}
 		objMetadata.Size = totalLength
 	case false:
 		// calculate data and parity dictated by total number of writers
 		k, m, err := b.getDataAndParity(len(writers))
 		if err != nil {
-			CleanupWritersOnError(writers)
-			return ObjectMetadata{}, iodine.New(err, nil)
+			return iodine.New(err, nil)
 		}
 		// write encoded data with k, m and writers
 		chunkCount, totalLength, err := b.writeObjectData(k, m, writers, objectData, sumMD5, sum256, sum512)
 		if err != nil {
-			CleanupWritersOnError(writers)
-			return ObjectMetadata{}, iodine.New(err, nil)
+			return iodine.New(err, nil)
 		}
 		/// donutMetadata section
 		objMetadata.BlockSize = blockSize
@@ -268,46 +312,96 @@ func (b bucket) WriteObject(objectName string, objectData io.Reader, expectedMD5
 		objMetadata.ErasureTechnique = "Cauchy"
 		objMetadata.Size = int64(totalLength)
 	}
-	objMetadata.Bucket = b.getBucketName()
-	objMetadata.Object = objectName
-	dataMD5sum := sumMD5.Sum(nil)
-	dataSHA512sum := sum512.Sum(nil)
+	return nil
+}
+
+// getPartName - name of a part of a multipart upload, parts are kept apart under multipartDir
+func getPartName(uploadID string, partID int) string {
+	return filepath.Join(multipartDir, uploadID, strconv.Itoa(partID))
+}
+
+// WriteObjectPart - write a part of a multipart upload of objectName onto the disks, parts are not indexed
+func (b bucket) WriteObjectPart(objectName, uploadID string, partID int, partData io.Reader, size int64, expectedMD5Sum string, signature *Signature) (ObjectMetadata, error) {
+	partName := getPartName(uploadID, partID)
+	writers, err := b.getObjectWriters(partName, "data")
+	if err != nil {
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	sumMD5 := md5.New()
+	sum256 := sha256.New()
+	sum512 := sha512.New()
+	partMetadata := ObjectMetadata{}
+	partMetadata.Version = objectMetadataVersion
+	partMetadata.Created = time.Now().UTC()
+	if err := b.writeObjectSlices(writers, partData, &partMetadata, sumMD5, sum256, sum512); err != nil {
+		CleanupWritersOnError(writers)
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	if partMetadata.Size != size {
+		CleanupWritersOnError(writers)
+		return ObjectMetadata{}, iodine.New(IncompleteBody{Bucket: b.getBucketName(), Object: objectName}, nil)
+	}
+	partMetadata.MD5Sum = hex.EncodeToString(sumMD5.Sum(nil))
+	partMetadata.SHA512Sum = hex.EncodeToString(sum512.Sum(nil))
+	// Verify if the written part is equal to what is expected, only if it is requested as such
+	if strings.TrimSpace(expectedMD5Sum) != "" {
+		if err := b.isMD5SumEqual(strings.TrimSpace(expectedMD5Sum), partMetadata.MD5Sum); err != nil {
+			CleanupWritersOnError(writers)
+			return ObjectMetadata{}, iodine.New(err, nil)
+		}
+	}
 	if signature != nil {
 		ok, err := signature.DoesSignatureMatch(hex.EncodeToString(sum256.Sum(nil)))
 		if err != nil {
-			// error occurred while doing signature calculation, we return and also cleanup any temporary writers.
 			CleanupWritersOnError(writers)
 			return ObjectMetadata{}, iodine.New(err, nil)
 		}
 		if !ok {
-			// purge all writers, when control flow reaches here
-			//
-			// Signature mismatch occurred all temp files to be removed and all data purged.
 			CleanupWritersOnError(writers)
 			return ObjectMetadata{}, iodine.New(SignatureDoesNotMatch{}, nil)
 		}
 	}
-	objMetadata.MD5Sum = hex.EncodeToString(dataMD5sum)
-	objMetadata.SHA512Sum = hex.EncodeToString(dataSHA512sum)
-
-	// Verify if the written object is equal to what is expected, only if it is requested as such
-	if strings.TrimSpace(expectedMD5Sum) != "" {
-		if err := b.isMD5SumEqual(strings.TrimSpace(expectedMD5Sum), objMetadata.MD5Sum); err != nil {
-			return ObjectMetadata{}, iodine.New(err, nil)
-		}
-	}
-	objMetadata.Metadata = metadata
-	// write object specific metadata
-	if err := b.writeObjectMetadata(normalizeObjectName(objectName), objMetadata); err != nil {
-		// purge all writers, when control flow reaches here
+	partMetadata.Bucket = b.getBucketName()
+	partMetadata.Object = objectName
+	if err := b.writeObjectMetadata(partName, partMetadata); err != nil {
 		CleanupWritersOnError(writers)
 		return ObjectMetadata{}, iodine.New(err, nil)
 	}
-	// close all writers, when control flow reaches here
 	for _, writer := range writers {
 		writer.Close()
 	}
-	return objMetadata, nil
+	return partMetadata, nil
+}
+
+// ReadObjectPart - open a part of a multipart upload to read, its checksum is verified at the end
+func (b bucket) ReadObjectPart(uploadID string, partID int) (io.ReadCloser, error) {
+	partName := getPartName(uploadID, partID)
+	partMetadata, err := b.readObjectMetadata(partName)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	reader, writer := io.Pipe()
+	go b.readObjectData(partName, writer, partMetadata)
+	return reader, nil
+}
+
+// DeleteObjectParts - remove all parts of a multipart upload from the disks
+func (b bucket) DeleteObjectParts(uploadID string) error {
+	nodeSlice := 0
+	for _, node := range b.nodes {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		for order, disk := range disks {
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, order)
+			if err := disk.RemoveAll(filepath.Join(b.donutName, bucketSlice, multipartDir, uploadID)); err != nil {
+				return iodine.New(err, nil)
+			}
+		}
+		nodeSlice = nodeSlice + 1
+	}
+	return nil
 }
 
 // isMD5SumEqual - returns error if md5sum mismatches, other its `nil`
@@ -388,6 +482,98 @@ func normalizeObjectName(objectName string) string {
 	return strings.Replace(objectName, "/", "-", -1)
 }
 
+// getObjectIndexes - object index of the bucket, replicated on every disk in disk order
+func (b bucket) getObjectIndexes() ([]index.Index, error) {
+	var nodeNames []string
+	for nodeName := range b.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	var objectIndexes []index.Index
+	for _, nodeName := range nodeNames {
+		disks, err := b.nodes[nodeName].ListDisks()
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		for order := 0; order < len(disks); order++ {
+			disk, ok := disks[order]
+			if !ok {
+				return nil, iodine.New(InvalidDisksArgument{}, nil)
+			}
+			// bucket names carry no '$', the index cannot collide with bucket slices or metadata
+			objectIndexes = append(objectIndexes, index.New(filepath.Join(disk.GetPath(), b.donutName), b.name+"$objects"))
+		}
+	}
+	if len(objectIndexes) == 0 {
+		return nil, iodine.New(InvalidDisksArgument{}, nil)
+	}
+	return objectIndexes, nil
+}
+
+// indexObject - add an object to the bucket index on every disk. Should a disk fail the indexes updated
+// so far are rolled back, the disks keep agreeing on the objects of the bucket
+func (b bucket) indexObject(objectName string) error {
+	objectIndexes, err := b.getObjectIndexes()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for j, objectIndex := range objectIndexes {
+		if err := objectIndex.Add(objectName); err != nil {
+			b.rollbackObjectIndexes(objectIndexes[:j], objectIndexes[j:], objectName)
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// rollbackObjectIndexes - remove an object from the updated indexes unless one of the untouched ones shows
+// it was indexed already, as is the case for overwrites. If none of them can be read the object stays
+// indexed, its data is stored
+func (b bucket) rollbackObjectIndexes(updated, untouched []index.Index, objectName string) {
+	var readable bool
+	for _, objectIndex := range untouched {
+		indexed, err := objectIndex.Has(objectName)
+		if err != nil {
+			continue
+		}
+		if indexed {
+			return
+		}
+		readable = true
+	}
+	if !readable {
+		log.Printf("Unable to roll back index of %s/%s, no untouched index could be read", b.name, objectName)
+		return
+	}
+	for _, objectIndex := range updated {
+		if err := objectIndex.Remove(objectName); err != nil {
+			log.Printf("Failed to roll back index of %s/%s: %s", b.name, objectName, iodine.ToError(err))
+		}
+	}
+}
+
+// isObjectIndexed - returns ObjectNotFound if an object is not part of the bucket index
+func (b bucket) isObjectIndexed(objectName string) error {
+	objectIndexes, err := b.getObjectIndexes()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	// every disk carries a copy of the index, fall back to the next one if it cannot be read
+	var ok bool
+	for _, objectIndex := range objectIndexes {
+		if ok, err = objectIndex.Has(objectName); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if !ok {
+		return iodine.New(ObjectNotFound{Object: objectName}, nil)
+	}
+	return nil
+}
+
 // getDataAndParity - calculate k, m (data and parity) values from number of disks
 func (b bucket) getDataAndParity(totalWriters int) (k uint8, m uint8, err error) {
 	if totalWriters <= 1 {
@@ -410,37 +596,68 @@ func (b bucket) writeObjectData(k, m uint8, writers []io.WriteCloser, objectData
 	if err != nil {
 		return 0, 0, iodine.New(err, nil)
 	}
+	encodedBlockLen, err := encoder.GetEncodedBlockLen(blockSize)
+	if err != nil {
+		return 0, 0, iodine.New(err, nil)
+	}
+	// only a single block of the object is held in memory at a time, the
+	// buffer has room for its parity so that it is erasure coded in place
+	buffer := bufpool.Get(encodedBlockLen * int(k+m))
+	defer bufpool.Put(buffer)
+	blockBuffer := buffer[:blockSize]
+
 	chunkCount := 0
 	totalLength := 0
-	for chunk := range split.Stream(objectData, 10*1024*1024) {
-		if chunk.Err != nil {
+	for {
+		length, err := io.ReadFull(objectData, blockBuffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 			return 0, 0, iodine.New(err, nil)
 		}
-		totalLength = totalLength + len(chunk.Data)
-		encodedBlocks, err := encoder.Encode(chunk.Data)
+		if length == 0 {
+			break
+		}
+		chunk := buffer[:length]
+		totalLength = totalLength + length
+		encodedBlocks, err := encoder.Encode(chunk)
 		if err != nil {
 			return 0, 0, iodine.New(err, nil)
 		}
 
-		sumMD5.Write(chunk.Data)
-		sum256.Write(chunk.Data)
-		sum512.Write(chunk.Data)
-		for blockIndex, block := range encodedBlocks {
-			errCh := make(chan error, 1)
-			go func(writer io.Writer, reader io.Reader) {
-				defer close(errCh)
-				_, err := io.Copy(writers[blockIndex], bytes.NewReader(block))
-				errCh <- err
-			}(writers[blockIndex], bytes.NewReader(block))
-			if err := <-errCh; err != nil {
-				return 0, 0, iodine.New(err, nil)
-			}
+		sumMD5.Write(chunk)
+		sum256.Write(chunk)
+		sum512.Write(chunk)
+		if err := writeEncodedBlocks(writers, encodedBlocks); err != nil {
+			return 0, 0, iodine.New(err, nil)
 		}
 		chunkCount = chunkCount + 1
+		if length < len(blockBuffer) {
+			break
+		}
 	}
 	return chunkCount, totalLength, nil
 }
 
+// writeEncodedBlocks - write encoded blocks to their slices, all slices are written concurrently
+func writeEncodedBlocks(writers []io.WriteCloser, encodedBlocks [][]byte) error {
+	errCh := make(chan error, len(encodedBlocks))
+	for blockIndex, block := range encodedBlocks {
+		go func(writer io.Writer, block []byte) {
+			_, err := writer.Write(block)
+			errCh <- err
+		}(writers[blockIndex], block)
+	}
+	var err error
+	for range encodedBlocks {
+		if blockErr := <-errCh; blockErr != nil && err == nil {
+			err = blockErr
+		}
+	}
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
 // readObjectData -
 func (b bucket) readObjectData(objectName string, writer *io.PipeWriter, objMetadata ObjectMetadata) {
 	readers, err := b.getObjectReaders(objectName, "data")
@@ -471,12 +688,13 @@ func (b bucket) readObjectData(objectName string, writer *io.PipeWriter, objMeta
 		}
 		totalLeft := objMetadata.Size
 		for i := 0; i < objMetadata.ChunkCount; i++ {
-			decodedData, err := b.decodeEncodedData(totalLeft, int64(objMetadata.BlockSize), readers, encoder, writer)
+			decodedData, buffer, err := b.decodeEncodedData(totalLeft, int64(objMetadata.BlockSize), readers, encoder, writer)
 			if err != nil {
 				writer.CloseWithError(iodine.New(err, nil))
 				return
 			}
-			_, err = io.Copy(mwriter, bytes.NewReader(decodedData))
+			_, err = mwriter.Write(decodedData)
+			bufpool.Put(buffer)
 			if err != nil {
 				writer.CloseWithError(iodine.New(err, nil))
 				return
@@ -499,8 +717,86 @@ func (b bucket) readObjectData(objectName string, writer *io.PipeWriter, objMeta
 	return
 }
 
-// decodeEncodedData -
-func (b bucket) decodeEncodedData(totalLeft, blockSize int64, readers []io.ReadCloser, encoder encoder, writer *io.PipeWriter) ([]byte, error) {
+// readObjectDataRange - seek each slice to the first erasure block of the range and decode until its last block
+func (b bucket) readObjectDataRange(objectName string, writer *io.PipeWriter, objMetadata ObjectMetadata, start, length int64) {
+	readers, err := b.getObjectReaders(objectName, "data")
+	if err != nil {
+		writer.CloseWithError(iodine.New(err, nil))
+		return
+	}
+	for _, reader := range readers {
+		defer reader.Close()
+	}
+	if length == 0 {
+		writer.Close()
+		return
+	}
+	switch len(readers) == 1 {
+	case false:
+		if objMetadata.ErasureTechnique == "" {
+			writer.CloseWithError(iodine.New(MissingErasureTechnique{}, nil))
+			return
+		}
+		encoder, err := newEncoder(objMetadata.DataDisks, objMetadata.ParityDisks, objMetadata.ErasureTechnique)
+		if err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+		blockSize := int64(objMetadata.BlockSize)
+		firstBlock := start / blockSize
+		lastBlock := (start + length - 1) / blockSize
+		// every block but the last one is of full block size, so are their encoded lengths
+		encodedBlockLen, err := encoder.GetEncodedBlockLen(objMetadata.BlockSize)
+		if err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+		for _, reader := range readers {
+			if _, err := reader.(io.Seeker).Seek(firstBlock*int64(encodedBlockLen), os.SEEK_SET); err != nil {
+				writer.CloseWithError(iodine.New(err, nil))
+				return
+			}
+		}
+		totalLeft := objMetadata.Size - firstBlock*blockSize
+		offset := start - firstBlock*blockSize
+		remaining := length
+		for block := firstBlock; block <= lastBlock; block++ {
+			decodedData, buffer, err := b.decodeEncodedData(totalLeft, blockSize, readers, encoder, writer)
+			if err != nil {
+				writer.CloseWithError(iodine.New(err, nil))
+				return
+			}
+			rangeData := decodedData[offset:]
+			if int64(len(rangeData)) > remaining {
+				rangeData = rangeData[:remaining]
+			}
+			_, err = writer.Write(rangeData)
+			bufpool.Put(buffer)
+			if err != nil {
+				writer.CloseWithError(iodine.New(err, nil))
+				return
+			}
+			remaining = remaining - int64(len(rangeData))
+			totalLeft = totalLeft - blockSize
+			offset = 0
+		}
+	case true:
+		if _, err := readers[0].(io.Seeker).Seek(start, os.SEEK_SET); err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+		if _, err := io.CopyN(writer, readers[0], length); err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+	}
+	writer.Close()
+}
+
+// decodeEncodedData - read one encoded block from every slice and decode it. The decoded data may share
+// the returned buffer, which comes from the buffer pool and must be handed back with bufpool.Put by the
+// caller once the data is written, not before
+func (b bucket) decodeEncodedData(totalLeft, blockSize int64, readers []io.ReadCloser, encoder encoder, writer *io.PipeWriter) ([]byte, []byte, error) {
 	var curBlockSize int64
 	if blockSize < totalLeft {
 		curBlockSize = blockSize
@@ -509,22 +805,25 @@ func (b bucket) decodeEncodedData(totalLeft, blockSize int64, readers []io.ReadC
 	}
 	curChunkSize, err := encoder.GetEncodedBlockLen(int(curBlockSize))
 	if err != nil {
-		return nil, iodine.New(err, nil)
+		return nil, nil, iodine.New(err, nil)
 	}
+	// all slices are read back to back into a single buffer, so that decoding
+	// an intact block needs no copying
+	buffer := bufpool.Get(curChunkSize * len(readers))
 	encodedBytes := make([][]byte, len(readers))
 	for i, reader := range readers {
-		var bytesBuffer bytes.Buffer
-		_, err := io.CopyN(&bytesBuffer, reader, int64(curChunkSize))
-		if err != nil {
-			return nil, iodine.New(err, nil)
+		encodedBytes[i] = buffer[i*curChunkSize : (i+1)*curChunkSize]
+		if _, err := io.ReadFull(reader, encodedBytes[i]); err != nil {
+			bufpool.Put(buffer)
+			return nil, nil, iodine.New(err, nil)
 		}
-		encodedBytes[i] = bytesBuffer.Bytes()
 	}
 	decodedData, err := encoder.Decode(encodedBytes, int(curBlockSize))
 	if err != nil {
-		return nil, iodine.New(err, nil)
+		bufpool.Put(buffer)
+		return nil, nil, iodine.New(err, nil)
 	}
-	return decodedData, nil
+	return decodedData, buffer, nil
 }
 
 // getObjectReaders -
@@ -33,10 +33,13 @@ type Cache struct {
 	sync.Mutex
 
 	// items hold the cached objects
-	items *list.List
+	items map[interface{}]*element
 
-	// reverseItems holds the time that related item's updated at
-	reverseItems map[interface{}]*list.Element
+	// expiry holds the keys in the order they expire in
+	expiry *list.List
+
+	// policy picks the items to evict once maxSize is reached
+	policy Policy
 
 	// maxSize is a total size for overall cache
 	maxSize uint64
@@ -44,11 +47,24 @@ type Cache struct {
 	// currentSize is a current size in memory
 	currentSize uint64
 
-	// OnEvicted - callback function for eviction
+	// expiration is the time to live of an item, zero keeps items until evicted
+	expiration time.Duration
+
+	// OnEvicted - callback function for eviction, called once the cache lock is released
 	OnEvicted func(a ...interface{})
 
-	// totalEvicted counter to keep track of total expirations
+	// evicted holds the keys removed while the lock is held, they are reported on unlock
+	evicted []interface{}
+
+	// totalEvicted counter to keep track of total evictions
 	totalEvicted int
+
+	// totalExpired counter to keep track of total expirations
+	totalExpired int
+
+	// hits and misses count lookups of the cache
+	hits   uint64
+	misses uint64
 }
 
 // Stats current cache statistics
@@ -56,100 +72,153 @@ type Stats struct {
 	Bytes   uint64
 	Items   int
 	Evicted int
+	Expired int
+	Hits    uint64
+	Misses  uint64
 }
 
 type element struct {
-	key   interface{}
-	value []byte
+	value   []byte
+	expires time.Time
+	expiry  *list.Element
 }
 
 // NewCache creates an inmemory cache
 //
 // maxSize is used for expiring objects before we run out of memory
 // expiration is used for expiration of a key from cache
-func NewCache(maxSize uint64) *Cache {
+// policy decides which keys are evicted first, defaults to LRU if nil
+func NewCache(maxSize uint64, expiration time.Duration, policy Policy) *Cache {
+	if policy == nil {
+		policy = NewLRU()
+	}
 	return &Cache{
-		items:        list.New(),
-		reverseItems: make(map[interface{}]*list.Element),
-		maxSize:      maxSize,
+		items:      make(map[interface{}]*element),
+		expiry:     list.New(),
+		policy:     policy,
+		maxSize:    maxSize,
+		expiration: expiration,
 	}
 }
 
-// SetMaxSize set a new max size
+// SetMaxSize set a new max size, evicting items until they fit
 func (r *Cache) SetMaxSize(maxSize uint64) {
 	r.Lock()
-	defer r.Unlock()
+	defer r.unlock()
 	r.maxSize = maxSize
+	r.doEvict(0)
 	return
 }
 
 // Stats get current cache statistics
 func (r *Cache) Stats() Stats {
+	r.Lock()
+	defer r.Unlock()
 	return Stats{
 		Bytes:   r.currentSize,
-		Items:   r.items.Len(),
+		Items:   len(r.items),
 		Evicted: r.totalEvicted,
+		Expired: r.totalExpired,
+		Hits:    r.hits,
+		Misses:  r.misses,
 	}
 }
 
 // Get returns a value of a given key if it exists
 func (r *Cache) Get(key interface{}) ([]byte, bool) {
 	r.Lock()
-	defer r.Unlock()
-	ele, hit := r.reverseItems[key]
+	defer r.unlock()
+	r.doExpire()
+	ele, hit := r.items[key]
 	if !hit {
+		r.misses++
+		return nil, false
+	}
+	r.hits++
+	r.policy.Access(key)
+	return ele.value, true
+}
+
+// Contains reports whether a key exists, unlike Get it is not counted as a hit or a miss
+// and leaves the eviction order untouched
+func (r *Cache) Contains(key interface{}) bool {
+	r.Lock()
+	defer r.unlock()
+	r.doExpire()
+	_, ok := r.items[key]
+	return ok
+}
+
+// Peek returns a value of a given key like Get, but it is not counted as a hit or a miss and leaves
+// the eviction order untouched
+func (r *Cache) Peek(key interface{}) ([]byte, bool) {
+	r.Lock()
+	defer r.unlock()
+	r.doExpire()
+	ele, ok := r.items[key]
+	if !ok {
 		return nil, false
 	}
-	r.items.MoveToFront(ele)
-	return ele.Value.(*element).value, true
+	return ele.value, true
 }
 
 // Len returns length of the value of a given key, returns zero if key doesn't exist
 func (r *Cache) Len(key interface{}) int {
 	r.Lock()
-	defer r.Unlock()
-	_, ok := r.reverseItems[key]
+	defer r.unlock()
+	r.doExpire()
+	ele, ok := r.items[key]
 	if !ok {
 		return 0
 	}
-	return len(r.reverseItems[key].Value.(*element).value)
+	return len(ele.value)
 }
 
 // Append will append new data to an existing key,
 // if key doesn't exist it behaves like Set()
 func (r *Cache) Append(key interface{}, value []byte) bool {
 	r.Lock()
-	defer r.Unlock()
+	defer r.unlock()
+	r.doExpire()
 	valueLen := uint64(len(value))
+	ele, hit := r.items[key]
 	if r.maxSize > 0 {
-		// check if the size of the object is not bigger than the
+		// check if the size of the whole object is not bigger than the
 		// capacity of the cache
-		if valueLen > r.maxSize {
+		var currentLen uint64
+		if hit {
+			currentLen = uint64(len(ele.value))
+		}
+		if currentLen+valueLen > r.maxSize {
 			return false
 		}
-		// remove random key if only we reach the maxSize threshold
-		for (r.currentSize + valueLen) > r.maxSize {
-			r.doDeleteOldest()
-			break
+		// the key appended to must not be evicted to make room for itself
+		if hit {
+			r.policy.Remove(key)
+		}
+		r.doEvict(valueLen)
+		if hit {
+			r.policy.Add(key)
 		}
 	}
-	ele, hit := r.reverseItems[key]
 	if !hit {
-		ele := r.items.PushFront(&element{key, value})
-		r.currentSize += valueLen
-		r.reverseItems[key] = ele
+		r.doAdd(key, value)
 		return true
 	}
-	r.items.MoveToFront(ele)
+	r.policy.Access(key)
 	r.currentSize += valueLen
-	ele.Value.(*element).value = append(ele.Value.(*element).value, value...)
+	ele.value = append(ele.value, value...)
 	return true
 }
 
 // Set will persist a value to the cache
 func (r *Cache) Set(key interface{}, value []byte) bool {
 	r.Lock()
-	defer r.Unlock()
+	defer r.unlock()
+	r.doExpire()
+	if _, hit := r.items[key]; hit {
+		return false
+	}
 	valueLen := uint64(len(value))
 	if r.maxSize > 0 {
 		// check if the size of the object is not bigger than the
@@ -157,48 +226,114 @@ func (r *Cache) Set(key interface{}, value []byte) bool {
 		if valueLen > r.maxSize {
 			return false
 		}
-		// remove random key if only we reach the maxSize threshold
-		for (r.currentSize + valueLen) > r.maxSize {
-			r.doDeleteOldest()
-		}
+		r.doEvict(valueLen)
 	}
-	if _, hit := r.reverseItems[key]; hit {
+	r.doAdd(key, value)
+	return true
+}
+
+// SetAll will persist all values or none of them, room for all of them is made before the first is
+// added so that they never evict each other. Fails if a key exists or the values exceed the capacity
+func (r *Cache) SetAll(keys []interface{}, values [][]byte) bool {
+	if len(keys) != len(values) {
 		return false
 	}
-	ele := r.items.PushFront(&element{key, value})
-	r.currentSize += valueLen
-	r.reverseItems[key] = ele
+	r.Lock()
+	defer r.unlock()
+	r.doExpire()
+	var totalLen uint64
+	for i, key := range keys {
+		if _, hit := r.items[key]; hit {
+			return false
+		}
+		totalLen += uint64(len(values[i]))
+	}
+	if r.maxSize > 0 {
+		if totalLen > r.maxSize {
+			return false
+		}
+		r.doEvict(totalLen)
+	}
+	for i, key := range keys {
+		r.doAdd(key, values[i])
+	}
 	return true
 }
 
 // Delete deletes a given key if exists
 func (r *Cache) Delete(key interface{}) {
 	r.Lock()
-	defer r.Unlock()
-	ele, ok := r.reverseItems[key]
-	if !ok {
+	defer r.unlock()
+	if _, ok := r.items[key]; !ok {
 		return
 	}
-	if ele != nil {
-		r.currentSize -= uint64(len(r.reverseItems[key].Value.(*element).value))
-		r.items.Remove(ele)
-		delete(r.reverseItems, key)
-		r.totalEvicted++
-		if r.OnEvicted != nil {
-			r.OnEvicted(key)
-		}
+	r.policy.Remove(key)
+	r.doDelete(key)
+	r.totalEvicted++
+	r.evicted = append(r.evicted, key)
+}
+
+// unlock - release the cache lock and report the evicted keys, callbacks may use the cache again
+func (r *Cache) unlock() {
+	evicted := r.evicted
+	r.evicted = nil
+	r.Unlock()
+	if r.OnEvicted == nil {
+		return
+	}
+	for _, key := range evicted {
+		r.OnEvicted(key)
+	}
+}
+
+func (r *Cache) doAdd(key interface{}, value []byte) {
+	ele := &element{value: value}
+	if r.expiration != noExpiration {
+		ele.expires = time.Now().Add(r.expiration)
 	}
+	ele.expiry = r.expiry.PushBack(key)
+	r.items[key] = ele
+	r.currentSize += uint64(len(value))
+	r.policy.Add(key)
+}
+
+func (r *Cache) doDelete(key interface{}) {
+	ele := r.items[key]
+	r.currentSize -= uint64(len(ele.value))
+	r.expiry.Remove(ele.expiry)
+	delete(r.items, key)
 }
 
-func (r *Cache) doDeleteOldest() {
-	ele := r.items.Back()
-	if ele != nil {
-		r.currentSize -= uint64(len(r.reverseItems[ele.Value.(*element).key].Value.(*element).value))
-		delete(r.reverseItems, ele.Value.(*element).key)
-		r.items.Remove(ele)
+// doEvict - evict items chosen by the policy until valueLen more bytes fit
+func (r *Cache) doEvict(valueLen uint64) {
+	if r.maxSize == 0 {
+		return
+	}
+	for r.currentSize+valueLen > r.maxSize {
+		key, ok := r.policy.Evict()
+		if !ok {
+			return
+		}
+		r.doDelete(key)
 		r.totalEvicted++
-		if r.OnEvicted != nil {
-			r.OnEvicted(ele.Value.(*element).key)
+		r.evicted = append(r.evicted, key)
+	}
+}
+
+// doExpire - delete items which outlived the expiration, they are ordered by the time they expire
+func (r *Cache) doExpire() {
+	if r.expiration == noExpiration {
+		return
+	}
+	now := time.Now()
+	for front := r.expiry.Front(); front != nil; front = r.expiry.Front() {
+		key := front.Value
+		if r.items[key].expires.After(now) {
+			return
 		}
+		r.policy.Remove(key)
+		r.doDelete(key)
+		r.totalExpired++
+		r.evicted = append(r.evicted, key)
 	}
 }
@@ -18,6 +18,7 @@ package data
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/minio/check"
 )
@@ -29,7 +30,7 @@ type MySuite struct{}
 var _ = Suite(&MySuite{})
 
 func (s *MySuite) TestCache(c *C) {
-	cache := NewCache(1000)
+	cache := NewCache(1000, 0, nil)
 	data := []byte("Hello, world!")
 	ok := cache.Set("filename", data)
 
@@ -43,3 +44,165 @@ func (s *MySuite) TestCache(c *C) {
 	_, ok = cache.Get("filename")
 	c.Assert(ok, Equals, false)
 }
+
+func (s *MySuite) TestCacheEvictsToFit(c *C) {
+	cache := NewCache(10, 0, nil)
+	var evicted []interface{}
+	cache.OnEvicted = func(a ...interface{}) {
+		// callbacks run without the cache lock held
+		evicted = append(evicted, a[0])
+		cache.Stats()
+	}
+	c.Assert(cache.Set("a", []byte("1234")), Equals, true)
+	c.Assert(cache.Set("b", []byte("1234")), Equals, true)
+	c.Assert(cache.Set("c", []byte("12345678")), Equals, true)
+	c.Assert(evicted, DeepEquals, []interface{}{"a", "b"})
+
+	// appending keeps the key, other keys make room for it
+	c.Assert(cache.Set("d", []byte("1")), Equals, true)
+	c.Assert(cache.Append("d", []byte("2345")), Equals, true)
+	value, ok := cache.Get("d")
+	c.Assert(ok, Equals, true)
+	c.Assert(value, DeepEquals, []byte("12345"))
+	c.Assert(cache.Stats().Bytes, Equals, uint64(5))
+	c.Assert(cache.Append("d", []byte("123456")), Equals, false)
+}
+
+func (s *MySuite) TestCacheSetAll(c *C) {
+	cache := NewCache(10, 0, nil)
+	c.Assert(cache.Set("a", []byte("1234")), Equals, true)
+	c.Assert(cache.Set("b", []byte("1234")), Equals, true)
+
+	// values added together never evict each other
+	keys := []interface{}{"c", "d", "e"}
+	values := [][]byte{[]byte("123"), []byte("123"), []byte("123")}
+	c.Assert(cache.SetAll(keys, values), Equals, true)
+	for i, key := range keys {
+		value, ok := cache.Get(key)
+		c.Assert(ok, Equals, true)
+		c.Assert(value, DeepEquals, values[i])
+	}
+	_, ok := cache.Get("a")
+	c.Assert(ok, Equals, false)
+	c.Assert(cache.Stats().Bytes, Equals, uint64(9))
+
+	// nothing is added if the values do not fit or a key exists
+	c.Assert(cache.SetAll([]interface{}{"f", "g"}, [][]byte{[]byte("123456"), []byte("123456")}), Equals, false)
+	c.Assert(cache.SetAll([]interface{}{"f", "c"}, [][]byte{[]byte("1"), []byte("1")}), Equals, false)
+	_, ok = cache.Get("f")
+	c.Assert(ok, Equals, false)
+	c.Assert(cache.Stats().Items, Equals, 3)
+}
+
+func (s *MySuite) TestCacheStats(c *C) {
+	cache := NewCache(1000, 0, nil)
+	cache.Set("filename", []byte("Hello, world!"))
+	cache.Get("filename")
+	cache.Get("filename")
+	cache.Get("missing")
+	stats := cache.Stats()
+	c.Assert(stats.Hits, Equals, uint64(2))
+	c.Assert(stats.Misses, Equals, uint64(1))
+	c.Assert(stats.Items, Equals, 1)
+	c.Assert(stats.Bytes, Equals, uint64(13))
+}
+
+func (s *MySuite) TestCacheContains(c *C) {
+	var evicted []interface{}
+	cache := NewCache(2, 0, nil)
+	cache.OnEvicted = func(a ...interface{}) {
+		evicted = append(evicted, a[0])
+	}
+	cache.Set("first", []byte("1"))
+	cache.Set("second", []byte("2"))
+	c.Assert(cache.Contains("first"), Equals, true)
+	c.Assert(cache.Contains("missing"), Equals, false)
+	c.Assert(cache.Stats().Hits, Equals, uint64(0))
+	c.Assert(cache.Stats().Misses, Equals, uint64(0))
+	// the lookup did not make "first" recently used, it is still evicted first
+	cache.Set("third", []byte("3"))
+	c.Assert(evicted, DeepEquals, []interface{}{"first"})
+}
+
+func (s *MySuite) TestCacheExpiration(c *C) {
+	cache := NewCache(1000, 50*time.Millisecond, nil)
+	var expired []interface{}
+	cache.OnEvicted = func(a ...interface{}) {
+		expired = append(expired, a[0])
+	}
+	cache.Set("first", []byte("1"))
+	time.Sleep(30 * time.Millisecond)
+	cache.Set("second", []byte("2"))
+	time.Sleep(30 * time.Millisecond)
+
+	_, ok := cache.Get("first")
+	c.Assert(ok, Equals, false)
+	_, ok = cache.Get("second")
+	c.Assert(ok, Equals, true)
+	c.Assert(expired, DeepEquals, []interface{}{"first"})
+	c.Assert(cache.Stats().Expired, Equals, 1)
+	c.Assert(cache.Stats().Bytes, Equals, uint64(1))
+}
+
+func (s *MySuite) TestLFUEvictionOrder(c *C) {
+	policy := NewLFU()
+	policy.Add("a")
+	policy.Add("b")
+	policy.Add("c")
+	policy.Access("a")
+	policy.Access("a")
+	policy.Access("c")
+
+	// least frequently used first, ties go to the least recently used
+	for _, expected := range []string{"b", "c", "a"} {
+		key, ok := policy.Evict()
+		c.Assert(ok, Equals, true)
+		c.Assert(key, Equals, expected)
+	}
+	_, ok := policy.Evict()
+	c.Assert(ok, Equals, false)
+}
+
+func (s *MySuite) TestARCEvictionOrder(c *C) {
+	policy := NewARC()
+	policy.Add("hot")
+	policy.Access("hot")
+	// a scan of keys seen once evicts among themselves before the frequently used key
+	for _, key := range []string{"s1", "s2", "s3"} {
+		policy.Add(key)
+	}
+	for _, expected := range []string{"s1", "s2", "s3", "hot"} {
+		key, ok := policy.Evict()
+		c.Assert(ok, Equals, true)
+		c.Assert(key, Equals, expected)
+	}
+}
+
+func (s *MySuite) TestARCGhostAdaptation(c *C) {
+	policy := NewARC().(*arc)
+	policy.Add("a")
+	policy.Add("b")
+	policy.Add("c")
+	policy.Access("c")
+
+	key, _ := policy.Evict()
+	c.Assert(key, Equals, "a")
+	c.Assert(policy.b1.Len(), Equals, 1)
+
+	// a miss on a key evicted from t1 grows the target of t1 and promotes the key to t2
+	c.Assert(policy.target, Equals, 0)
+	policy.Add("a")
+	c.Assert(policy.target, Equals, 1)
+	c.Assert(policy.lists["a"], Equals, policy.t2)
+	c.Assert(policy.b1.Len(), Equals, 0)
+
+	// t1 now holds its target, the least recently used key of t2 goes first
+	key, _ = policy.Evict()
+	c.Assert(key, Equals, "c")
+	c.Assert(policy.b2.Len(), Equals, 1)
+
+	// a miss on a key evicted from t2 shrinks the target of t1 again
+	policy.Add("c")
+	c.Assert(policy.target, Equals, 0)
+	c.Assert(policy.lists["c"], Equals, policy.t2)
+}
@@ -0,0 +1,282 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package data
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// Policy decides which entry is evicted once the cache runs out of space,
+// its methods are called with the cache lock held
+type Policy interface {
+	// Add - a new key entered the cache
+	Add(key interface{})
+	// Access - a cached key was read or written
+	Access(key interface{})
+	// Remove - a key was deleted from the cache
+	Remove(key interface{})
+	// Evict - pick the key to be evicted next and forget it, false if there is none
+	Evict() (interface{}, bool)
+}
+
+// lru evicts the least recently used key
+type lru struct {
+	items *list.List
+	keys  map[interface{}]*list.Element
+}
+
+// NewLRU - least recently used eviction policy
+func NewLRU() Policy {
+	return &lru{
+		items: list.New(),
+		keys:  make(map[interface{}]*list.Element),
+	}
+}
+
+func (p *lru) Add(key interface{}) {
+	if ele, ok := p.keys[key]; ok {
+		p.items.MoveToFront(ele)
+		return
+	}
+	p.keys[key] = p.items.PushFront(key)
+}
+
+func (p *lru) Access(key interface{}) {
+	if ele, ok := p.keys[key]; ok {
+		p.items.MoveToFront(ele)
+	}
+}
+
+func (p *lru) Remove(key interface{}) {
+	if ele, ok := p.keys[key]; ok {
+		p.items.Remove(ele)
+		delete(p.keys, key)
+	}
+}
+
+func (p *lru) Evict() (interface{}, bool) {
+	ele := p.items.Back()
+	if ele == nil {
+		return nil, false
+	}
+	p.items.Remove(ele)
+	delete(p.keys, ele.Value)
+	return ele.Value, true
+}
+
+// lfuEntry - key with its access count, seq breaks ties in favour of the least recently used key
+type lfuEntry struct {
+	key   interface{}
+	count uint64
+	seq   uint64
+	index int
+}
+
+// lfuHeap - min heap of entries ordered by access count
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].count == h[j].count {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].count < h[j].count
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *lfuHeap) Push(x interface{}) {
+	entry := x.(*lfuEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// lfu evicts the least frequently used key, among equals the least recently used one
+type lfu struct {
+	entries lfuHeap
+	keys    map[interface{}]*lfuEntry
+	seq     uint64
+}
+
+// NewLFU - least frequently used eviction policy
+func NewLFU() Policy {
+	return &lfu{
+		keys: make(map[interface{}]*lfuEntry),
+	}
+}
+
+func (p *lfu) Add(key interface{}) {
+	if _, ok := p.keys[key]; ok {
+		p.Access(key)
+		return
+	}
+	p.seq++
+	entry := &lfuEntry{key: key, count: 1, seq: p.seq}
+	p.keys[key] = entry
+	heap.Push(&p.entries, entry)
+}
+
+func (p *lfu) Access(key interface{}) {
+	if entry, ok := p.keys[key]; ok {
+		p.seq++
+		entry.count++
+		entry.seq = p.seq
+		heap.Fix(&p.entries, entry.index)
+	}
+}
+
+func (p *lfu) Remove(key interface{}) {
+	if entry, ok := p.keys[key]; ok {
+		heap.Remove(&p.entries, entry.index)
+		delete(p.keys, key)
+	}
+}
+
+func (p *lfu) Evict() (interface{}, bool) {
+	if len(p.entries) == 0 {
+		return nil, false
+	}
+	entry := heap.Pop(&p.entries).(*lfuEntry)
+	delete(p.keys, entry.key)
+	return entry.key, true
+}
+
+// arc is an adaptive replacement cache policy, keys seen once (t1) and keys seen again (t2) are kept
+// apart, so a scan only ever flushes t1. Recently evicted keys are remembered in the ghost lists b1
+// and b2, a miss on them shifts the target size of t1 towards recency or frequency
+type arc struct {
+	t1, t2, b1, b2 *list.List
+	keys           map[interface{}]*list.Element
+	lists          map[interface{}]*list.List
+	// target number of keys in t1
+	target int
+	// most keys ever resident, bounds the ghost lists
+	capacity int
+}
+
+// NewARC - adaptive replacement eviction policy
+func NewARC() Policy {
+	return &arc{
+		t1:    list.New(),
+		t2:    list.New(),
+		b1:    list.New(),
+		b2:    list.New(),
+		keys:  make(map[interface{}]*list.Element),
+		lists: make(map[interface{}]*list.List),
+	}
+}
+
+func (p *arc) move(key interface{}, to *list.List) {
+	if ele, ok := p.keys[key]; ok {
+		p.lists[key].Remove(ele)
+	}
+	p.keys[key] = to.PushFront(key)
+	p.lists[key] = to
+}
+
+func (p *arc) forget(key interface{}) {
+	if ele, ok := p.keys[key]; ok {
+		p.lists[key].Remove(ele)
+		delete(p.keys, key)
+		delete(p.lists, key)
+	}
+}
+
+func (p *arc) Add(key interface{}) {
+	resident := p.t1.Len() + p.t2.Len()
+	switch p.lists[key] {
+	case p.b1:
+		// evicted too early from t1, favour recency
+		delta := 1
+		if p.b1.Len() < p.b2.Len() {
+			delta = p.b2.Len() / p.b1.Len()
+		}
+		p.target = p.target + delta
+		if p.target > resident+1 {
+			p.target = resident + 1
+		}
+		p.move(key, p.t2)
+	case p.b2:
+		// evicted too early from t2, favour frequency
+		delta := 1
+		if p.b2.Len() < p.b1.Len() {
+			delta = p.b1.Len() / p.b2.Len()
+		}
+		p.target = p.target - delta
+		if p.target < 0 {
+			p.target = 0
+		}
+		p.move(key, p.t2)
+	case p.t1, p.t2:
+		p.Access(key)
+	default:
+		p.move(key, p.t1)
+	}
+	if resident := p.t1.Len() + p.t2.Len(); resident > p.capacity {
+		p.capacity = resident
+	}
+	p.trimGhosts()
+}
+
+// trimGhosts - ghost lists never remember more keys than the cache held at most
+func (p *arc) trimGhosts() {
+	for p.b1.Len()+p.b2.Len() > p.capacity {
+		ghosts := p.b1
+		if p.b2.Len() > p.b1.Len() {
+			ghosts = p.b2
+		}
+		p.forget(ghosts.Back().Value)
+	}
+}
+
+func (p *arc) Access(key interface{}) {
+	switch p.lists[key] {
+	case p.t1, p.t2:
+		p.move(key, p.t2)
+	}
+}
+
+func (p *arc) Remove(key interface{}) {
+	switch p.lists[key] {
+	case p.t1, p.t2:
+		p.forget(key)
+	}
+}
+
+func (p *arc) Evict() (interface{}, bool) {
+	from, ghosts := p.t2, p.b2
+	if p.t1.Len() > 0 && (p.t1.Len() > p.target || p.t2.Len() == 0) {
+		from, ghosts = p.t1, p.b1
+	}
+	ele := from.Back()
+	if ele == nil {
+		return nil, false
+	}
+	key := ele.Value
+	p.move(key, ghosts)
+	p.trimGhosts()
+	return key, true
+}
@@ -0,0 +1,269 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package file implements an on disk cache for data, meant for a fast local drive
+// sitting beneath the in memory data cache
+package file
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio/pkg/donut/cache/data"
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// temporary files are written with this prefix and renamed into place once complete
+const tempPrefix = ".tmp-"
+
+// Cache holds the required variables to compose an on disk cache system, every value
+// is kept in its own file behind a sha256 checksum of its contents
+type Cache struct {
+	// Mutex guards the index of cached files, file contents are read and written without it
+	sync.Mutex
+
+	// dir holds the cached files
+	dir string
+
+	// items hold the size of the value of each cached file
+	items map[string]uint64
+
+	// policy picks the files to evict once maxSize is reached
+	policy data.Policy
+
+	// maxSize is a total size of all values, zero is unlimited
+	maxSize uint64
+
+	// currentSize is a current size of all values
+	currentSize uint64
+
+	// counters to keep track of the cache use
+	totalEvicted int
+	corrupted    int
+	hits         uint64
+	misses       uint64
+}
+
+// Stats current cache statistics
+type Stats struct {
+	Bytes     uint64
+	Items     int
+	Evicted   int
+	Corrupted int
+	Hits      uint64
+	Misses    uint64
+}
+
+// NewCache creates an on disk cache in dir
+//
+// files already in dir are picked up again, the least recently modified are evicted first
+// maxSize is the total size of cached values, zero is unlimited
+// policy decides which keys are evicted first, defaults to LRU if nil
+func NewCache(dir string, maxSize uint64, policy data.Policy) (*Cache, error) {
+	if policy == nil {
+		policy = data.NewLRU()
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	r := &Cache{
+		dir:     dir,
+		items:   make(map[string]uint64),
+		policy:  policy,
+		maxSize: maxSize,
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	sort.Sort(byModTime(files))
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		// partial writes and files too short to hold a checksum are left overs of a crash
+		if strings.HasPrefix(file.Name(), tempPrefix) || file.Size() < sha256.Size {
+			os.Remove(filepath.Join(dir, file.Name()))
+			continue
+		}
+		r.items[file.Name()] = uint64(file.Size() - sha256.Size)
+		r.currentSize += uint64(file.Size() - sha256.Size)
+		r.policy.Add(file.Name())
+	}
+	r.doEvict(0)
+	return r, nil
+}
+
+// byModTime sorts files, least recently modified first
+type byModTime []os.FileInfo
+
+func (f byModTime) Len() int           { return len(f) }
+func (f byModTime) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f byModTime) Less(i, j int) bool { return f[i].ModTime().Before(f[j].ModTime()) }
+
+// fileName - name of the file caching key, keys may contain any characters
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Stats get current cache statistics
+func (r *Cache) Stats() Stats {
+	r.Lock()
+	defer r.Unlock()
+	return Stats{
+		Bytes:     r.currentSize,
+		Items:     len(r.items),
+		Evicted:   r.totalEvicted,
+		Corrupted: r.corrupted,
+		Hits:      r.hits,
+		Misses:    r.misses,
+	}
+}
+
+// Contains reports whether a key is cached, without reading or verifying its value
+func (r *Cache) Contains(key string) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, hit := r.items[fileName(key)]
+	return hit
+}
+
+// Get returns a value of a given key if it exists, a value not matching its checksum is removed and missed
+func (r *Cache) Get(key string) ([]byte, bool) {
+	name := fileName(key)
+	r.Lock()
+	if _, hit := r.items[name]; !hit {
+		r.misses++
+		r.Unlock()
+		return nil, false
+	}
+	r.policy.Access(name)
+	r.Unlock()
+
+	content, err := ioutil.ReadFile(filepath.Join(r.dir, name))
+	if err != nil {
+		// evicted while it was read
+		r.Lock()
+		r.misses++
+		r.Unlock()
+		return nil, false
+	}
+	if len(content) >= sha256.Size {
+		sum := sha256.Sum256(content[sha256.Size:])
+		if bytes.Equal(sum[:], content[:sha256.Size]) {
+			r.Lock()
+			r.hits++
+			r.Unlock()
+			return content[sha256.Size:], true
+		}
+	}
+	// damaged on disk, it is never served
+	r.Lock()
+	defer r.Unlock()
+	r.misses++
+	r.corrupted++
+	if _, hit := r.items[name]; hit {
+		r.policy.Remove(name)
+		r.doDelete(name)
+	}
+	return nil, false
+}
+
+// Set will persist a value to the cache, returns false if the key exists or the value does not fit
+func (r *Cache) Set(key string, value []byte) (bool, error) {
+	name := fileName(key)
+	valueLen := uint64(len(value))
+	r.Lock()
+	_, hit := r.items[name]
+	r.Unlock()
+	if hit || (r.maxSize > 0 && valueLen > r.maxSize) {
+		return false, nil
+	}
+
+	// the file is written before taking the lock, only complete files are renamed into place
+	file, err := ioutil.TempFile(r.dir, tempPrefix)
+	if err != nil {
+		return false, iodine.New(err, nil)
+	}
+	sum := sha256.Sum256(value)
+	if _, err := file.Write(append(sum[:], value...)); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return false, iodine.New(err, nil)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return false, iodine.New(err, nil)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	// a concurrent writer may have cached it already
+	if _, hit := r.items[name]; hit {
+		os.Remove(file.Name())
+		return false, nil
+	}
+	r.doEvict(valueLen)
+	if err := os.Rename(file.Name(), filepath.Join(r.dir, name)); err != nil {
+		os.Remove(file.Name())
+		return false, iodine.New(err, nil)
+	}
+	r.items[name] = valueLen
+	r.currentSize += valueLen
+	r.policy.Add(name)
+	return true, nil
+}
+
+// Delete deletes a given key if exists
+func (r *Cache) Delete(key string) {
+	name := fileName(key)
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.items[name]; !ok {
+		return
+	}
+	r.policy.Remove(name)
+	r.doDelete(name)
+}
+
+func (r *Cache) doDelete(name string) {
+	r.currentSize -= r.items[name]
+	delete(r.items, name)
+	os.Remove(filepath.Join(r.dir, name))
+}
+
+// doEvict - evict files chosen by the policy until valueLen more bytes fit
+func (r *Cache) doEvict(valueLen uint64) {
+	if r.maxSize == 0 {
+		return
+	}
+	for r.currentSize+valueLen > r.maxSize {
+		name, ok := r.policy.Evict()
+		if !ok {
+			return
+		}
+		r.doDelete(name.(string))
+		r.totalEvicted++
+	}
+}
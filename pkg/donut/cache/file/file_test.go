@@ -0,0 +1,129 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/minio/check"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MySuite struct {
+	root string
+}
+
+var _ = Suite(&MySuite{})
+
+func (s *MySuite) SetUpTest(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "file-cache-")
+	c.Assert(err, IsNil)
+	s.root = root
+}
+
+func (s *MySuite) TearDownTest(c *C) {
+	os.RemoveAll(s.root)
+}
+
+func (s *MySuite) TestCache(c *C) {
+	cache, err := NewCache(s.root, 1000, nil)
+	c.Assert(err, IsNil)
+	data := []byte("Hello, world!")
+	ok, err := cache.Set("bucket/object", data)
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	ok, err = cache.Set("bucket/object", data)
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, false)
+
+	storedata, ok := cache.Get("bucket/object")
+	c.Assert(ok, Equals, true)
+	c.Assert(storedata, DeepEquals, data)
+
+	cache.Delete("bucket/object")
+	_, ok = cache.Get("bucket/object")
+	c.Assert(ok, Equals, false)
+	stats := cache.Stats()
+	c.Assert(stats.Hits, Equals, uint64(1))
+	c.Assert(stats.Misses, Equals, uint64(1))
+	c.Assert(stats.Items, Equals, 0)
+}
+
+func (s *MySuite) TestCacheEvictsToFit(c *C) {
+	cache, err := NewCache(s.root, 10, nil)
+	c.Assert(err, IsNil)
+	for _, key := range []string{"a", "b", "c"} {
+		ok, err := cache.Set(key, []byte("1234"))
+		c.Assert(err, IsNil)
+		c.Assert(ok, Equals, true)
+	}
+	_, ok := cache.Get("a")
+	c.Assert(ok, Equals, false)
+	_, ok = cache.Get("c")
+	c.Assert(ok, Equals, true)
+	ok, err = cache.Set("d", []byte("12345678901"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, false)
+
+	stats := cache.Stats()
+	c.Assert(stats.Bytes, Equals, uint64(8))
+	c.Assert(stats.Evicted, Equals, 1)
+	files, err := ioutil.ReadDir(s.root)
+	c.Assert(err, IsNil)
+	c.Assert(len(files), Equals, 2)
+}
+
+func (s *MySuite) TestCacheDetectsCorruption(c *C) {
+	cache, err := NewCache(s.root, 1000, nil)
+	c.Assert(err, IsNil)
+	_, err = cache.Set("key", []byte("Hello, world!"))
+	c.Assert(err, IsNil)
+
+	name := filepath.Join(s.root, fileName("key"))
+	content, err := ioutil.ReadFile(name)
+	c.Assert(err, IsNil)
+	content[len(content)-1]++
+	c.Assert(ioutil.WriteFile(name, content, 0600), IsNil)
+
+	_, ok := cache.Get("key")
+	c.Assert(ok, Equals, false)
+	c.Assert(cache.Stats().Corrupted, Equals, 1)
+	c.Assert(cache.Stats().Items, Equals, 0)
+	_, err = os.Stat(name)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *MySuite) TestCacheIsReloaded(c *C) {
+	cache, err := NewCache(s.root, 1000, nil)
+	c.Assert(err, IsNil)
+	_, err = cache.Set("key", []byte("Hello, world!"))
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.root, tempPrefix+"partial"), []byte("Hello"), 0600), IsNil)
+
+	cache, err = NewCache(s.root, 1000, nil)
+	c.Assert(err, IsNil)
+	value, ok := cache.Get("key")
+	c.Assert(ok, Equals, true)
+	c.Assert(value, DeepEquals, []byte("Hello, world!"))
+	c.Assert(cache.Stats().Bytes, Equals, uint64(len("Hello, world!")))
+	_, err = os.Stat(filepath.Join(s.root, tempPrefix+"partial"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
@@ -84,6 +84,18 @@ func IsValidPrefix(prefix string) bool {
 	return IsValidObjectName(prefix)
 }
 
+// isETagMatch - verify if an etag matches any of the comma separated etags in a conditional header,
+// a value of "*" matches any etag
+func isETagMatch(condition, etag string) bool {
+	for _, conditionETag := range strings.Split(condition, ",") {
+		conditionETag = strings.Trim(strings.TrimSpace(conditionETag), "\"")
+		if conditionETag == "*" || conditionETag == etag {
+			return true
+		}
+	}
+	return false
+}
+
 // ProxyWriter implements io.Writer to trap written bytes
 type ProxyWriter struct {
 	writer       io.Writer
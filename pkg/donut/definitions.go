@@ -16,7 +16,12 @@
 
 package donut
 
-import "time"
+import (
+	"time"
+
+	"github.com/minio/minio/pkg/donut/cache/data"
+	"github.com/minio/minio/pkg/donut/cache/file"
+)
 
 // ObjectMetadata container for object on donut system
 type ObjectMetadata struct {
@@ -62,7 +67,7 @@ type BucketMetadata struct {
 	ACL           BucketACL              `json:"acl"`
 	Created       time.Time              `json:"created"`
 	Metadata      map[string]string      `json:"metadata"`
-	BucketObjects map[string]interface{} `json:"objects"`
+	BucketObjects map[string]interface{} `json:"objects,omitempty"` // only read to migrate into the bucket index
 }
 
 // ListObjectsResults container for list objects response
@@ -70,6 +75,7 @@ type ListObjectsResults struct {
 	Objects        map[string]ObjectMetadata `json:"objects"`
 	CommonPrefixes []string                  `json:"commonPrefixes"`
 	IsTruncated    bool                      `json:"isTruncated"`
+	NextMarker     string                    `json:"nextMarker"`
 }
 
 // MultiPartSession multipart session
@@ -77,6 +83,7 @@ type MultiPartSession struct {
 	totalParts int
 	uploadID   string
 	initiated  time.Time
+	metadata   map[string]string
 }
 
 // PartMetadata - various types of individual part resources
@@ -153,4 +160,30 @@ type BucketResourcesMetadata struct {
 	Delimiter      string
 	IsTruncated    bool
 	CommonPrefixes []string
+
+	// list objects version 2
+	ListType          int
+	ContinuationToken string
+	StartAfter        string
+	FetchOwner        bool
+}
+
+// Stats - usage statistics of a donut
+type Stats struct {
+	Cache data.Stats
+	// DiskCache is only set if a disk cache is configured
+	DiskCache *file.Stats
+	Disks     []DiskStats
+
+	MultipartSessions int
+	// WriteBackPending counts the objects acknowledged but not yet on the disks
+	WriteBackPending int
+}
+
+// DiskStats - usage of a disk of a node
+type DiskStats struct {
+	Node  string
+	Path  string
+	Total int64
+	Free  int64
 }
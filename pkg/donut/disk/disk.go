@@ -86,7 +86,12 @@ func (disk Disk) GetFSInfo() map[string]string {
 	disk.fsInfo["Free"] = formatBytes(int64(s.Bsize) * int64(s.Bfree))
 	disk.fsInfo["TotalB"] = strconv.FormatInt(int64(s.Bsize)*int64(s.Blocks), 10)
 	disk.fsInfo["FreeB"] = strconv.FormatInt(int64(s.Bsize)*int64(s.Bfree), 10)
-	return disk.fsInfo
+	// the map is updated by every call, callers get a copy to read without the lock
+	fsInfo := make(map[string]string)
+	for k, v := range disk.fsInfo {
+		fsInfo[k] = v
+	}
+	return fsInfo
 }
 
 // MakeDir - make a directory inside disk root path
@@ -96,6 +101,20 @@ func (disk Disk) MakeDir(dirname string) error {
 	return os.MkdirAll(filepath.Join(disk.path, dirname), 0700)
 }
 
+// RemoveAll - remove a file or a directory and all its contents inside disk root path
+func (disk Disk) RemoveAll(name string) error {
+	disk.lock.Lock()
+	defer disk.lock.Unlock()
+
+	if name == "" {
+		return iodine.New(InvalidArgument{}, nil)
+	}
+	if err := os.RemoveAll(filepath.Join(disk.path, name)); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
 // ListDir - list a directory inside disk root path, get only directories
 func (disk Disk) ListDir(dirname string) ([]os.FileInfo, error) {
 	disk.lock.Lock()
@@ -57,7 +57,7 @@ func (donut API) getBucketMetadata(bucketName string) (BucketMetadata, error) {
 	if err := donut.listDonutBuckets(); err != nil {
 		return BucketMetadata{}, iodine.New(err, nil)
 	}
-	if _, ok := donut.buckets[bucketName]; !ok {
+	if _, ok := donut.lookupBucket(bucketName); !ok {
 		return BucketMetadata{}, iodine.New(BucketNotFound{Bucket: bucketName}, nil)
 	}
 	metadata, err := donut.getDonutBucketMetadata()
@@ -110,13 +110,11 @@ func (donut API) listObjects(bucket, prefix, marker, delimiter string, maxkeys i
 		"delimiter": delimiter,
 		"maxkeys":   strconv.Itoa(maxkeys),
 	}
-	if err := donut.listDonutBuckets(); err != nil {
+	b, err := donut.getDonutBucket(bucket)
+	if err != nil {
 		return ListObjectsResults{}, iodine.New(err, errParams)
 	}
-	if _, ok := donut.buckets[bucket]; !ok {
-		return ListObjectsResults{}, iodine.New(BucketNotFound{Bucket: bucket}, errParams)
-	}
-	listObjects, err := donut.buckets[bucket].ListObjects(prefix, marker, delimiter, maxkeys)
+	listObjects, err := b.ListObjects(prefix, marker, delimiter, maxkeys)
 	if err != nil {
 		return ListObjectsResults{}, iodine.New(err, errParams)
 	}
@@ -135,27 +133,15 @@ func (donut API) putObject(bucket, object, expectedMD5Sum string, reader io.Read
 	if object == "" || strings.TrimSpace(object) == "" {
 		return ObjectMetadata{}, iodine.New(InvalidArgument{}, errParams)
 	}
-	if err := donut.listDonutBuckets(); err != nil {
-		return ObjectMetadata{}, iodine.New(err, errParams)
-	}
-	if _, ok := donut.buckets[bucket]; !ok {
-		return ObjectMetadata{}, iodine.New(BucketNotFound{Bucket: bucket}, nil)
-	}
-	bucketMeta, err := donut.getDonutBucketMetadata()
+	b, err := donut.getDonutBucket(bucket)
 	if err != nil {
 		return ObjectMetadata{}, iodine.New(err, errParams)
 	}
-	if _, ok := bucketMeta.Buckets[bucket].BucketObjects[object]; ok {
-		return ObjectMetadata{}, iodine.New(ObjectExists{Object: object}, errParams)
-	}
-	objMetadata, err := donut.buckets[bucket].WriteObject(object, reader, expectedMD5Sum, metadata, signature)
+	// existence of the object is verified by the caller against its write preconditions
+	objMetadata, err := b.WriteObject(object, reader, expectedMD5Sum, metadata, signature)
 	if err != nil {
 		return ObjectMetadata{}, iodine.New(err, errParams)
 	}
-	bucketMeta.Buckets[bucket].BucketObjects[object] = 1
-	if err := donut.setDonutBucketMetadata(bucketMeta); err != nil {
-		return ObjectMetadata{}, iodine.New(err, errParams)
-	}
 	return objMetadata, nil
 }
 
@@ -171,13 +157,30 @@ func (donut API) getObject(bucket, object string) (reader io.ReadCloser, size in
 	if object == "" || strings.TrimSpace(object) == "" {
 		return nil, 0, iodine.New(InvalidArgument{}, errParams)
 	}
-	if err := donut.listDonutBuckets(); err != nil {
-		return nil, 0, iodine.New(err, nil)
+	b, err := donut.getDonutBucket(bucket)
+	if err != nil {
+		return nil, 0, iodine.New(err, errParams)
 	}
-	if _, ok := donut.buckets[bucket]; !ok {
-		return nil, 0, iodine.New(BucketNotFound{Bucket: bucket}, errParams)
+	return b.ReadObject(object)
+}
+
+// getPartialObject - get a range of an object
+func (donut API) getPartialObject(bucket, object string, start, length int64) (reader io.ReadCloser, err error) {
+	errParams := map[string]string{
+		"bucket": bucket,
+		"object": object,
+	}
+	if bucket == "" || strings.TrimSpace(bucket) == "" {
+		return nil, iodine.New(InvalidArgument{}, errParams)
+	}
+	if object == "" || strings.TrimSpace(object) == "" {
+		return nil, iodine.New(InvalidArgument{}, errParams)
+	}
+	b, err := donut.getDonutBucket(bucket)
+	if err != nil {
+		return nil, iodine.New(err, errParams)
 	}
-	return donut.buckets[bucket].ReadObject(object)
+	return b.ReadObjectRange(object, start, length)
 }
 
 // getObjectMetadata - get object metadata
@@ -186,20 +189,14 @@ func (donut API) getObjectMetadata(bucket, object string) (ObjectMetadata, error
 		"bucket": bucket,
 		"object": object,
 	}
-	if err := donut.listDonutBuckets(); err != nil {
-		return ObjectMetadata{}, iodine.New(err, errParams)
-	}
-	if _, ok := donut.buckets[bucket]; !ok {
-		return ObjectMetadata{}, iodine.New(BucketNotFound{Bucket: bucket}, errParams)
-	}
-	bucketMeta, err := donut.getDonutBucketMetadata()
+	b, err := donut.getDonutBucket(bucket)
 	if err != nil {
 		return ObjectMetadata{}, iodine.New(err, errParams)
 	}
-	if _, ok := bucketMeta.Buckets[bucket].BucketObjects[object]; !ok {
-		return ObjectMetadata{}, iodine.New(ObjectNotFound{Object: object}, errParams)
+	if err := b.isObjectIndexed(object); err != nil {
+		return ObjectMetadata{}, iodine.New(err, errParams)
 	}
-	objectMetadata, err := donut.buckets[bucket].GetObjectMetadata(object)
+	objectMetadata, err := b.GetObjectMetadata(object)
 	if err != nil {
 		return ObjectMetadata{}, iodine.New(err, nil)
 	}
@@ -208,6 +205,69 @@ func (donut API) getObjectMetadata(bucket, object string) (ObjectMetadata, error
 
 //// internal functions
 
+// lookupBucket - look up a bucket listed from disk
+func (donut API) lookupBucket(bucketName string) (bucket, bool) {
+	donut.bucketsLock.RLock()
+	defer donut.bucketsLock.RUnlock()
+	b, ok := donut.buckets[bucketName]
+	return b, ok
+}
+
+// storeBucket - remember a bucket, buckets are listed from disk by concurrent requests
+func (donut API) storeBucket(bucketName string, b bucket) {
+	donut.bucketsLock.Lock()
+	defer donut.bucketsLock.Unlock()
+	donut.buckets[bucketName] = b
+}
+
+// getDonutBucket - get a bucket to do object I/O on, only the lookup is done holding the donut lock
+func (donut API) getDonutBucket(bucketName string) (bucket, error) {
+	donut.lock.Lock()
+	defer donut.lock.Unlock()
+	if err := donut.listDonutBuckets(); err != nil {
+		return bucket{}, iodine.New(err, nil)
+	}
+	b, ok := donut.lookupBucket(bucketName)
+	if !ok {
+		return bucket{}, iodine.New(BucketNotFound{Bucket: bucketName}, nil)
+	}
+	return b, nil
+}
+
+// migrateObjectIndexes - move object names kept in bucket metadata by earlier versions into the bucket indexes
+func (donut API) migrateObjectIndexes() error {
+	if err := donut.listDonutBuckets(); err != nil {
+		return iodine.New(err, nil)
+	}
+	metadata, err := donut.getDonutBucketMetadata()
+	if err != nil {
+		if os.IsNotExist(iodine.ToError(err)) {
+			return nil
+		}
+		return iodine.New(err, nil)
+	}
+	var migrated bool
+	for bucketName, bucketMetadata := range metadata.Buckets {
+		if len(bucketMetadata.BucketObjects) == 0 {
+			continue
+		}
+		if bucket, ok := donut.lookupBucket(bucketName); ok {
+			for object := range bucketMetadata.BucketObjects {
+				if err := bucket.indexObject(object); err != nil {
+					return iodine.New(err, nil)
+				}
+			}
+		}
+		bucketMetadata.BucketObjects = nil
+		metadata.Buckets[bucketName] = bucketMetadata
+		migrated = true
+	}
+	if !migrated {
+		return nil
+	}
+	return donut.setDonutBucketMetadata(metadata)
+}
+
 // getBucketMetadataWriters -
 func (donut API) getBucketMetadataWriters() ([]io.WriteCloser, error) {
 	var writers []io.WriteCloser
@@ -292,7 +352,7 @@ func (donut API) makeDonutBucket(bucketName, acl string) error {
 	if err := donut.listDonutBuckets(); err != nil {
 		return iodine.New(err, nil)
 	}
-	if _, ok := donut.buckets[bucketName]; ok {
+	if _, ok := donut.lookupBucket(bucketName); ok {
 		return iodine.New(BucketExists{Bucket: bucketName}, nil)
 	}
 	bucket, bucketMetadata, err := newBucket(bucketName, acl, donut.config.DonutName, donut.nodes)
@@ -300,7 +360,7 @@ func (donut API) makeDonutBucket(bucketName, acl string) error {
 		return iodine.New(err, nil)
 	}
 	nodeNumber := 0
-	donut.buckets[bucketName] = bucket
+	donut.storeBucket(bucketName, bucket)
 	for _, node := range donut.nodes {
 		disks, err := node.ListDisks()
 		if err != nil {
@@ -360,7 +420,7 @@ func (donut API) listDonutBuckets() error {
 				if err != nil {
 					return iodine.New(err, nil)
 				}
-				donut.buckets[bucketName] = bucket
+				donut.storeBucket(bucketName, bucket)
 			}
 		}
 	}
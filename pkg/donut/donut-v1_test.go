@@ -21,13 +21,19 @@ import (
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/xml"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	. "github.com/minio/check"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/bufpool"
 )
 
 func TestDonut(t *testing.T) { TestingT(t) }
@@ -211,6 +217,480 @@ func (s *MyDonutSuite) TestNewObjectCanBeWritten(c *C) {
 	c.Assert(int64(len(data)), Equals, actualMetadata.Size)
 }
 
+// test partial reads spanning erasure blocks
+func (s *MyDonutSuite) TestPartialObjectAcrossBlocks(c *C) {
+	c.Assert(dd.MakeBucket("foo-ranges", "private", nil), IsNil)
+
+	data := make([]byte, 2*blockSize+1024)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	reader := ioutil.NopCloser(bytes.NewReader(data))
+	_, err := dd.CreateObject("foo-ranges", "obj", "", int64(len(data)), reader, nil, nil)
+	c.Assert(err, IsNil)
+
+	ranges := [][2]int64{
+		{0, 10},
+		{blockSize - 5, 10},
+		{blockSize + 100, 1000},
+		{blockSize - 5, blockSize + 10},
+		{2*blockSize + 1000, 24},
+	}
+	for _, r := range ranges {
+		var buffer bytes.Buffer
+		size, err := dd.GetPartialObject(&buffer, "foo-ranges", "obj", r[0], r[1])
+		c.Assert(err, IsNil)
+		c.Assert(size, Equals, r[1])
+		c.Assert(buffer.Bytes(), DeepEquals, data[r[0]:r[0]+r[1]])
+	}
+
+	var buffer bytes.Buffer
+	_, err = dd.GetPartialObject(&buffer, "foo-ranges", "obj", 2*blockSize, 2048)
+	c.Assert(err, Not(IsNil))
+}
+
+// test ranges of an object are cached chunk by chunk and served from cache
+func (s *MyDonutSuite) TestPartialObjectIsCachedInChunks(c *C) {
+	c.Assert(dd.MakeBucket("foo-chunks", "private", nil), IsNil)
+
+	data := make([]byte, 4*cacheChunkSize+100)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	reader := ioutil.NopCloser(bytes.NewReader(data))
+	objMetadata, err := dd.CreateObject("foo-chunks", "obj", "", int64(len(data)), reader, nil, nil)
+	c.Assert(err, IsNil)
+
+	var buffer bytes.Buffer
+	start := int64(2*cacheChunkSize + 10)
+	size, err := dd.GetPartialObject(&buffer, "foo-chunks", "obj", start, 100)
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(100))
+	c.Assert(buffer.Bytes(), DeepEquals, data[start:start+100])
+
+	// only the chunk covering the range is cached
+	objects := dd.(API).objects
+	_, ok := objects.Get(newChunkKey(objMetadata, 2))
+	c.Assert(ok, Equals, true)
+	_, ok = objects.Get(newChunkKey(objMetadata, 1))
+	c.Assert(ok, Equals, false)
+
+	// a cached chunk is served without going to disk
+	chunk, _ := objects.Get(newChunkKey(objMetadata, 2))
+	chunk[10] = chunk[10] + 1
+	buffer.Reset()
+	_, err = dd.GetPartialObject(&buffer, "foo-chunks", "obj", start, 1)
+	c.Assert(err, IsNil)
+	c.Assert(buffer.Bytes()[0], Equals, data[start]+1)
+	chunk[10] = data[start]
+
+	// whole object reads combine cached chunks with chunks read from disk
+	buffer.Reset()
+	size, err = dd.GetObject(&buffer, "foo-chunks", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(len(data)))
+	c.Assert(buffer.Bytes(), DeepEquals, data)
+
+	// the object is too large next to the cache, reading it whole does not fill the cache
+	_, ok = objects.Get(newChunkKey(objMetadata, 1))
+	c.Assert(ok, Equals, false)
+
+	_, err = dd.GetPartialObject(&buffer, "foo-chunks", "obj", int64(len(data))-10, 20)
+	c.Assert(err, Not(IsNil))
+}
+
+// test chunks read from disk are kept on the disk cache, verified and served from there
+func (s *MyDonutSuite) TestSecondTierDiskCache(c *C) {
+	c.Assert(dd.MakeBucket("foo-ssd", "private", nil), IsNil)
+	data := make([]byte, 2*cacheChunkSize+10)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	reader := ioutil.NopCloser(bytes.NewReader(data))
+	_, err := dd.CreateObject("foo-ssd", "obj", "", int64(len(data)), reader, nil, nil)
+	c.Assert(err, IsNil)
+
+	customConfigPath := CustomConfigPath
+	defer func() { CustomConfigPath = customConfigPath }()
+	CustomConfigPath = filepath.Join(s.root, "donut-ssd.json")
+	conf := &Config{Version: "0.0.1", DonutName: "test", MaxSize: 100000}
+	conf.NodeDiskMap = createTestNodeDiskMap(s.root)
+	conf.DiskCache = filepath.Join(s.root, "ssd")
+	conf.DiskCacheSize = 1000000
+	c.Assert(SaveConfig(conf), IsNil)
+	tiered, err := New()
+	c.Assert(err, IsNil)
+	diskCache := tiered.(API).diskCache
+
+	// too large for memory, not for the disk cache
+	for i := 0; i < 2; i++ {
+		var buffer bytes.Buffer
+		_, err = tiered.GetObject(&buffer, "foo-ssd", "obj")
+		c.Assert(err, IsNil)
+		c.Assert(buffer.Bytes(), DeepEquals, data)
+	}
+	c.Assert(diskCache.Stats().Items, Equals, 3)
+	c.Assert(diskCache.Stats().Hits, Equals, uint64(3))
+	c.Assert(tiered.(API).objects.Stats().Items, Equals, 0)
+
+	// a damaged chunk is read from disk again
+	files, err := ioutil.ReadDir(conf.DiskCache)
+	c.Assert(err, IsNil)
+	for _, file := range files {
+		c.Assert(ioutil.WriteFile(filepath.Join(conf.DiskCache, file.Name()), []byte("garbage"), 0600), IsNil)
+	}
+	var buffer bytes.Buffer
+	_, err = tiered.GetObject(&buffer, "foo-ssd", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.Bytes(), DeepEquals, data)
+	c.Assert(diskCache.Stats().Corrupted, Not(Equals), 0)
+}
+
+// test written back objects are readable right away, reach the disks on flush and are replayed on start
+func (s *MyDonutSuite) TestWriteBackObjects(c *C) {
+	customConfigPath := CustomConfigPath
+	defer func() { CustomConfigPath = customConfigPath }()
+	CustomConfigPath = filepath.Join(s.root, "donut-write-back.json")
+	conf := &Config{Version: "0.0.1", DonutName: "test", MaxSize: 100000}
+	conf.NodeDiskMap = createTestNodeDiskMap(s.root)
+	conf.WriteBack = filepath.Join(s.root, "journal")
+	c.Assert(SaveConfig(conf), IsNil)
+	writeBack, err := New()
+	c.Assert(err, IsNil)
+	c.Assert(writeBack.MakeBucket("foo-write-back", "private", nil), IsNil)
+
+	// the second version supersedes the first one before it is written
+	var metadata map[string]string
+	for _, content := range []string{"one", "two"} {
+		reader := ioutil.NopCloser(bytes.NewReader([]byte(content)))
+		objMetadata, err := writeBack.CreateObject("foo-write-back", "obj", "", int64(len(content)), reader, metadata, nil)
+		c.Assert(err, IsNil)
+		metadata = map[string]string{"ifMatch": objMetadata.MD5Sum}
+		var buffer bytes.Buffer
+		_, err = writeBack.GetObject(&buffer, "foo-write-back", "obj")
+		c.Assert(err, IsNil)
+		c.Assert(buffer.String(), Equals, content)
+	}
+	c.Assert(writeBack.Flush(), IsNil)
+	objMetadata, err := writeBack.(API).getObjectMetadata("foo-write-back", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(objMetadata.Size, Equals, int64(len("two")))
+	files, err := ioutil.ReadDir(conf.WriteBack)
+	c.Assert(err, IsNil)
+	c.Assert(len(files), Equals, 0)
+
+	// an object journaled but never written, as if the process crashed
+	journal, err := newWriteBack(conf.WriteBack)
+	c.Assert(err, IsNil)
+	sum := md5.Sum([]byte("three"))
+	entry := &journalEntry{
+		Bucket:   "foo-write-back",
+		Object:   "crashed",
+		MD5Sum:   hex.EncodeToString(sum[:]),
+		Metadata: map[string]string{"contentType": "application/octet-stream"},
+		chunks:   [][]byte{[]byte("three")},
+	}
+	c.Assert(journal.journal(entry), IsNil)
+
+	writeBack, err = New()
+	c.Assert(err, IsNil)
+	var buffer bytes.Buffer
+	_, err = writeBack.GetObject(&buffer, "foo-write-back", "crashed")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "three")
+	files, err = ioutil.ReadDir(conf.WriteBack)
+	c.Assert(err, IsNil)
+	c.Assert(len(files), Equals, 0)
+}
+
+// test a journal entry which can not be replayed does not keep the donut from starting
+func (s *MyDonutSuite) TestWriteBackReplaySkipsFailures(c *C) {
+	customConfigPath := CustomConfigPath
+	defer func() { CustomConfigPath = customConfigPath }()
+	CustomConfigPath = filepath.Join(s.root, "donut-write-back-failures.json")
+	conf := &Config{Version: "0.0.1", DonutName: "test", MaxSize: 100000}
+	conf.NodeDiskMap = createTestNodeDiskMap(s.root)
+	conf.WriteBack = filepath.Join(s.root, "failures")
+	c.Assert(SaveConfig(conf), IsNil)
+
+	journal, err := newWriteBack(conf.WriteBack)
+	c.Assert(err, IsNil)
+	sum := md5.Sum([]byte("lost"))
+	entry := &journalEntry{
+		Bucket: "foo-missing",
+		Object: "obj",
+		MD5Sum: hex.EncodeToString(sum[:]),
+		chunks: [][]byte{[]byte("lost")},
+	}
+	c.Assert(journal.journal(entry), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(conf.WriteBack, "garbage"), []byte("garbage"), 0600), IsNil)
+
+	writeBack, err := New()
+	c.Assert(err, IsNil)
+	// the entry stays journaled for the next start, the garbage is set aside
+	c.Assert(writeBack.Flush(), Not(IsNil))
+	files, err := ioutil.ReadDir(conf.WriteBack)
+	c.Assert(err, IsNil)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	c.Assert(names, DeepEquals, []string{journalInvalidPrefix + "garbage", entry.name})
+}
+
+// test objects are written to the disks right away while another process owns the journal, the journal is
+// replayed once it is given up
+func (s *MyDonutSuite) TestWriteBackWaitsForJournal(c *C) {
+	customConfigPath := CustomConfigPath
+	defer func() { CustomConfigPath = customConfigPath }()
+	CustomConfigPath = filepath.Join(s.root, "donut-write-back-owned.json")
+	conf := &Config{Version: "0.0.1", DonutName: "test", MaxSize: 100000}
+	conf.NodeDiskMap = createTestNodeDiskMap(s.root)
+	conf.WriteBack = filepath.Join(s.root, "owned")
+	c.Assert(SaveConfig(conf), IsNil)
+
+	previous, err := newWriteBack(conf.WriteBack)
+	c.Assert(err, IsNil)
+	acquired, err := previous.acquire(false)
+	c.Assert(err, IsNil)
+	c.Assert(acquired, Equals, true)
+	sum := md5.Sum([]byte("flushing"))
+	entry := &journalEntry{
+		Bucket:   "foo-owned",
+		Object:   "flushing",
+		MD5Sum:   hex.EncodeToString(sum[:]),
+		Metadata: map[string]string{"contentType": "application/octet-stream"},
+		chunks:   [][]byte{[]byte("flushing")},
+	}
+	c.Assert(previous.journal(entry), IsNil)
+
+	writeBack, err := New()
+	c.Assert(err, IsNil)
+	c.Assert(writeBack.MakeBucket("foo-owned", "private", nil), IsNil)
+	reader := ioutil.NopCloser(bytes.NewReader([]byte("direct")))
+	_, err = writeBack.CreateObject("foo-owned", "direct", "", int64(len("direct")), reader, nil, nil)
+	c.Assert(err, IsNil)
+	_, err = writeBack.(API).getObjectMetadata("foo-owned", "direct")
+	c.Assert(err, IsNil)
+	_, err = writeBack.(API).getObjectMetadata("foo-owned", "flushing")
+	c.Assert(err, Not(IsNil))
+
+	previous.release()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, err = writeBack.(API).getObjectMetadata("foo-owned", "flushing"); err == nil {
+			break
+		}
+	}
+	c.Assert(err, IsNil)
+}
+
+// test paging through objects and common prefixes of the bucket index
+func (s *MyDonutSuite) TestPagedListObjects(c *C) {
+	c.Assert(dd.MakeBucket("foo-paging", "private", nil), IsNil)
+	for _, object := range []string{"a/1", "a/2", "b", "c/1", "d"} {
+		reader := ioutil.NopCloser(bytes.NewReader([]byte(object)))
+		_, err := dd.CreateObject("foo-paging", object, "", int64(len(object)), reader, nil, nil)
+		c.Assert(err, IsNil)
+	}
+
+	var resources BucketResourcesMetadata
+	resources.Delimiter = "/"
+	resources.Maxkeys = 2
+	objectsMetadata, resources, err := dd.ListObjects("foo-paging", resources, nil)
+	c.Assert(err, IsNil)
+	c.Assert(resources.IsTruncated, Equals, true)
+	c.Assert(resources.CommonPrefixes, DeepEquals, []string{"a/"})
+	c.Assert(len(objectsMetadata), Equals, 1)
+	c.Assert(objectsMetadata[0].Object, Equals, "b")
+	c.Assert(resources.NextMarker, Equals, "b")
+
+	resources.Marker = resources.NextMarker
+	objectsMetadata, resources, err = dd.ListObjects("foo-paging", resources, nil)
+	c.Assert(err, IsNil)
+	c.Assert(resources.IsTruncated, Equals, false)
+	c.Assert(resources.CommonPrefixes, DeepEquals, []string{"c/"})
+	c.Assert(len(objectsMetadata), Equals, 1)
+	c.Assert(objectsMetadata[0].Object, Equals, "d")
+
+	// a common prefix as marker skips the objects under it
+	resources = BucketResourcesMetadata{Prefix: "", Marker: "a/", Delimiter: "/", Maxkeys: 1}
+	objectsMetadata, resources, err = dd.ListObjects("foo-paging", resources, nil)
+	c.Assert(err, IsNil)
+	c.Assert(resources.IsTruncated, Equals, true)
+	c.Assert(len(resources.CommonPrefixes), Equals, 0)
+	c.Assert(objectsMetadata[0].Object, Equals, "b")
+}
+
+// test listing falls back to the index of another disk if the first one cannot be read
+func (s *MyDonutSuite) TestObjectIndexFallback(c *C) {
+	c.Assert(dd.MakeBucket("foo-index", "private", nil), IsNil)
+	for _, object := range []string{"a/1", "a/2", "b"} {
+		reader := ioutil.NopCloser(bytes.NewReader([]byte(object)))
+		_, err := dd.CreateObject("foo-index", object, "", int64(len(object)), reader, nil, nil)
+		c.Assert(err, IsNil)
+	}
+	err := ioutil.WriteFile(filepath.Join(s.root, "0", "test", "foo-index$objects.log"), []byte("x\n"), 0600)
+	c.Assert(err, IsNil)
+
+	resources := BucketResourcesMetadata{Delimiter: "/", Maxkeys: 10}
+	objectsMetadata, resources, err := dd.ListObjects("foo-index", resources, nil)
+	c.Assert(err, IsNil)
+	c.Assert(resources.CommonPrefixes, DeepEquals, []string{"a/"})
+	c.Assert(len(objectsMetadata), Equals, 1)
+	c.Assert(objectsMetadata[0].Object, Equals, "b")
+}
+
+// test an object whose index cannot be updated on every disk is rolled back from the other disks, while
+// an overwritten object stays indexed
+func (s *MyDonutSuite) TestObjectIndexRollback(c *C) {
+	c.Assert(dd.MakeBucket("foo-rollback", "private", nil), IsNil)
+	reader := ioutil.NopCloser(bytes.NewReader([]byte("old")))
+	_, err := dd.CreateObject("foo-rollback", "old", "", 3, reader, nil, nil)
+	c.Assert(err, IsNil)
+
+	// the log of a disk halfway through cannot be appended to, it lost its records
+	brokenLog := filepath.Join(s.root, "8", "test", "foo-rollback$objects.log")
+	c.Assert(os.Remove(brokenLog), IsNil)
+	c.Assert(os.Symlink(filepath.Join(s.root, "missing", "log"), brokenLog), IsNil)
+
+	reader = ioutil.NopCloser(bytes.NewReader([]byte("new")))
+	_, err = dd.CreateObject("foo-rollback", "new", "", 3, reader, nil, nil)
+	c.Assert(err, Not(IsNil))
+	_, err = dd.GetObjectMetadata("foo-rollback", "new", nil)
+	c.Assert(iodine.ToError(err), FitsTypeOf, ObjectNotFound{})
+
+	reader = ioutil.NopCloser(bytes.NewReader([]byte("two")))
+	_, err = dd.CreateObject("foo-rollback", "old", "", 3, reader, map[string]string{"ifMatch": "*"}, nil)
+	c.Assert(err, Not(IsNil))
+	_, err = dd.GetObjectMetadata("foo-rollback", "old", nil)
+	c.Assert(err, IsNil)
+
+	c.Assert(os.Remove(brokenLog), IsNil)
+	resources := BucketResourcesMetadata{Maxkeys: 10}
+	objectsMetadata, _, err := dd.ListObjects("foo-rollback", resources, nil)
+	c.Assert(err, IsNil)
+	c.Assert(len(objectsMetadata), Equals, 1)
+	c.Assert(objectsMetadata[0].Object, Equals, "old")
+}
+
+// test a decoded block stays intact while another one is decoded from pooled buffers, until its buffer
+// is handed back
+func (s *MyDonutSuite) TestOverlappingDecodes(c *C) {
+	encoder, err := newEncoder(8, 8, "Cauchy")
+	c.Assert(err, IsNil)
+	decode := func(data []byte) ([]byte, []byte) {
+		encodedData, err := encoder.Encode(data)
+		c.Assert(err, IsNil)
+		readers := make([]io.ReadCloser, len(encodedData))
+		for i, block := range encodedData {
+			readers[i] = ioutil.NopCloser(bytes.NewReader(block))
+		}
+		decodedData, buffer, err := bucket{}.decodeEncodedData(int64(len(data)), int64(len(data)), readers, encoder, nil)
+		c.Assert(err, IsNil)
+		return decodedData, buffer
+	}
+	first := bytes.Repeat([]byte("first"), 100000)
+	second := bytes.Repeat([]byte("other"), 100000)
+
+	// intact blocks decode in place, the data shares the pooled buffer
+	firstData, firstBuffer := decode(first)
+	c.Assert(&firstData[0], Equals, &firstBuffer[0])
+	secondData, secondBuffer := decode(second)
+	c.Assert(bytes.Equal(secondData, second), Equals, true)
+	// a buffer handed back is reused by the next decode, one still held is not
+	bufpool.Put(secondBuffer)
+	thirdData, thirdBuffer := decode(second)
+	c.Assert(bytes.Equal(firstData, first), Equals, true)
+	c.Assert(bytes.Equal(thirdData, second), Equals, true)
+	bufpool.Put(firstBuffer)
+	bufpool.Put(thirdBuffer)
+}
+
+// test parallel reads and writes on different objects, meant to be run with -race
+func (s *MyDonutSuite) TestParallelObjectIO(c *C) {
+	c.Assert(dd.MakeBucket("foo-parallel", "private", nil), IsNil)
+	reader := ioutil.NopCloser(bytes.NewReader([]byte("read")))
+	_, err := dd.CreateObject("foo-parallel", "read", "", 4, reader, nil, nil)
+	c.Assert(err, IsNil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			object := "write-" + strconv.Itoa(i)
+			reader := ioutil.NopCloser(bytes.NewReader([]byte(object)))
+			_, err := dd.CreateObject("foo-parallel", object, "", int64(len(object)), reader, nil, nil)
+			errs <- err
+		}(i)
+		go func() {
+			defer wg.Done()
+			var buffer bytes.Buffer
+			_, err := dd.GetObject(&buffer, "foo-parallel", "read")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		c.Assert(err, IsNil)
+	}
+}
+
+// test parts of multipart uploads are streamed onto the disks and removed once the upload is done
+func (s *MyDonutSuite) TestPartsAreStoredOnDisk(c *C) {
+	c.Assert(dd.MakeBucket("foo-parts", "private", nil), IsNil)
+	uploadID, err := dd.NewMultipartUpload("foo-parts", "obj", nil, nil)
+	c.Assert(err, IsNil)
+	uploadPath := filepath.Join(s.root, "0", "test", "foo-parts$0$0", "$multiparts", uploadID)
+
+	var complete CompleteMultipartUpload
+	for partID, part := range []string{"hello ", "world"} {
+		etag, err := dd.CreateObjectPart("foo-parts", "obj", uploadID, partID+1, "", "", int64(len(part)), bytes.NewReader([]byte(part)), nil)
+		c.Assert(err, IsNil)
+		complete.Part = append(complete.Part, CompletePart{PartNumber: partID + 1, ETag: etag})
+		_, err = os.Stat(filepath.Join(uploadPath, strconv.Itoa(partID+1), "data"))
+		c.Assert(err, IsNil)
+	}
+
+	var completeBuffer bytes.Buffer
+	c.Assert(xml.NewEncoder(&completeBuffer).Encode(complete), IsNil)
+	objMetadata, err := dd.CompleteMultipartUpload("foo-parts", "obj", uploadID, &completeBuffer, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(objMetadata.Size, Equals, int64(len("hello world")))
+	_, err = os.Stat(uploadPath)
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	var buffer bytes.Buffer
+	_, err = dd.GetObject(&buffer, "foo-parts", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "hello world")
+
+	// aborting an upload removes its parts as well
+	uploadID, err = dd.NewMultipartUpload("foo-parts", "aborted", nil, nil)
+	c.Assert(err, IsNil)
+	_, err = dd.CreateObjectPart("foo-parts", "aborted", uploadID, 1, "", "", 3, bytes.NewReader([]byte("one")), nil)
+	c.Assert(err, IsNil)
+	c.Assert(dd.AbortMultipartUpload("foo-parts", "aborted", uploadID, nil), IsNil)
+	_, err = os.Stat(filepath.Join(s.root, "0", "test", "foo-parts$0$0", "$multiparts", uploadID))
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+// test an upload shorter than its declared size is not committed
+func (s *MyDonutSuite) TestShortObjectIsNotCommitted(c *C) {
+	c.Assert(dd.MakeBucket("foo-short", "private", nil), IsNil)
+	reader := ioutil.NopCloser(bytes.NewReader([]byte("short")))
+	_, err := dd.CreateObject("foo-short", "obj", "", 1024, reader, nil, nil)
+	c.Assert(err, Not(IsNil))
+
+	_, err = dd.GetObjectMetadata("foo-short", "obj", nil)
+	c.Assert(err, Not(IsNil))
+	var buffer bytes.Buffer
+	_, err = dd.GetObject(&buffer, "foo-short", "obj")
+	c.Assert(err, Not(IsNil))
+}
+
 // test list objects
 func (s *MyDonutSuite) TestMultipleNewObjects(c *C) {
 	c.Assert(dd.MakeBucket("foo5", "private", nil), IsNil)
@@ -288,3 +768,74 @@ func (s *MyDonutSuite) TestMultipleNewObjects(c *C) {
 	c.Assert(resources.IsTruncated, Equals, true)
 	c.Assert(len(objectsMetadata), Equals, 2)
 }
+
+// test readiness requires a majority of writable disks on every node
+func (s *MyDonutSuite) TestReady(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+
+	customConfigPath := CustomConfigPath
+	defer func() { CustomConfigPath = customConfigPath }()
+	CustomConfigPath = filepath.Join(root, "donut.json")
+	conf := &Config{Version: "0.0.1", DonutName: "test", MaxSize: 100000}
+	conf.NodeDiskMap = createTestNodeDiskMap(root)
+	c.Assert(SaveConfig(conf), IsNil)
+	ready, err := New()
+	c.Assert(err, IsNil)
+	c.Assert(ready.Ready(), IsNil)
+
+	// a disk replaced by a file can not be written to
+	disks := conf.NodeDiskMap["localhost"]
+	for i := 0; i < len(disks)/2; i++ {
+		c.Assert(ready.Ready(), IsNil)
+		c.Assert(os.RemoveAll(disks[i]), IsNil)
+		c.Assert(ioutil.WriteFile(disks[i], []byte("gone"), 0600), IsNil)
+	}
+	c.Assert(ready.Ready(), Not(IsNil))
+
+	// a config which fails to load is reported
+	c.Assert(ioutil.WriteFile(CustomConfigPath, []byte("{"), 0600), IsNil)
+	broken, err := New()
+	c.Assert(err, IsNil)
+	c.Assert(broken.Ready(), Not(IsNil))
+}
+
+// test an overwrite waits until an open version has been read, its data matches its metadata
+func (s *MyDonutSuite) TestOpenObjectIsConsistent(c *C) {
+	c.Assert(dd.MakeBucket("foo-version", "private", nil), IsNil)
+	reader := ioutil.NopCloser(bytes.NewReader([]byte("first version")))
+	objMetadata, err := dd.CreateObject("foo-version", "obj", "", int64(len("first version")), reader, nil, nil)
+	c.Assert(err, IsNil)
+
+	version, err := dd.OpenObject("foo-version", "obj", nil)
+	c.Assert(err, IsNil)
+	c.Assert(version.Metadata.MD5Sum, Equals, objMetadata.MD5Sum)
+
+	overwritten := make(chan error)
+	go func() {
+		reader := ioutil.NopCloser(bytes.NewReader([]byte("second")))
+		_, err := dd.CreateObject("foo-version", "obj", "", int64(len("second")), reader, map[string]string{"ifMatch": objMetadata.MD5Sum}, nil)
+		overwritten <- err
+	}()
+	select {
+	case <-overwritten:
+		c.Fatal("overwrite did not wait for the open version")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	var buffer bytes.Buffer
+	_, err = version.Read(&buffer, 6, 7)
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "version")
+	version.Close()
+	version.Close()
+	c.Assert(<-overwritten, IsNil)
+
+	buffer.Reset()
+	_, err = version.Read(&buffer, 0, 1)
+	c.Assert(err, Not(IsNil))
+	_, err = dd.GetObject(&buffer, "foo-version", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "second")
+}
@@ -23,9 +23,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"io"
-	"io/ioutil"
 	"log"
-	"runtime/debug"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -34,6 +33,7 @@ import (
 
 	"github.com/minio/minio/pkg/crypto/sha256"
 	"github.com/minio/minio/pkg/donut/cache/data"
+	"github.com/minio/minio/pkg/donut/cache/file"
 	"github.com/minio/minio/pkg/donut/cache/metadata"
 	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/quick"
@@ -45,22 +45,50 @@ const (
 )
 
 // Config donut config
+//
+// CachePolicy is one of "lru", "lfu" or "arc", defaults to "lru". CacheTTL is the number of seconds
+// an object stays cached, zero keeps it until evicted. Without disks the cache holds the only copy,
+// expired objects are gone
+//
+// DiskCache is a directory on a fast local drive caching the chunks read from the disks beneath the
+// memory cache, up to DiskCacheSize bytes, zero is unlimited. It is only used with disks
+//
+// WriteBack is a directory on a local drive journaling small objects, they are acknowledged once
+// journaled and written to the disks in the background. Until then they are read from memory, but
+// not listed. Journaled objects are written to the disks on Flush and when starting up
+//
+// Snapshot is a file keeping the buckets and objects of a donut without disks across restarts. It is
+// written on Flush and every SnapshotInterval seconds, zero only on Flush, changes in between are
+// journaled next to it
 type Config struct {
-	Version     string              `json:"version"`
-	MaxSize     uint64              `json:"max-size"`
-	DonutName   string              `json:"donut-name"`
-	NodeDiskMap map[string][]string `json:"node-disk-map"`
+	Version          string              `json:"version"`
+	MaxSize          uint64              `json:"max-size"`
+	CachePolicy      string              `json:"cache-policy"`
+	CacheTTL         uint64              `json:"cache-ttl"`
+	DiskCache        string              `json:"disk-cache"`
+	DiskCacheSize    uint64              `json:"disk-cache-size"`
+	WriteBack        string              `json:"write-back"`
+	Snapshot         string              `json:"snapshot"`
+	SnapshotInterval uint64              `json:"snapshot-interval"`
+	DonutName        string              `json:"donut-name"`
+	NodeDiskMap      map[string][]string `json:"node-disk-map"`
 }
 
 // API - local variables
 type API struct {
 	config           *Config
-	lock             *sync.Mutex
+	configErr        error       // set if a config was found but failed to load, the default config is used
+	lock             *sync.Mutex // guards in-memory state only, object I/O is serialized by nsMutex
+	nsMutex          *nsLockMap
 	objects          *data.Cache
+	diskCache        *file.Cache // nil unless configured, chunks missing in objects are looked up here
+	writeBack        *writeBack  // nil unless configured, objects journaled but not yet on the disks
+	snapshot         *snapshot   // nil unless configured, keeps a donut without disks across restarts
 	multiPartObjects map[string]*data.Cache
 	storedBuckets    *metadata.Cache
 	nodes            map[string]node
 	buckets          map[string]bucket
+	bucketsLock      *sync.RWMutex // guards buckets, which is refreshed from disk without holding lock
 }
 
 // storedBucket saved bucket
@@ -76,6 +104,11 @@ func New() (Interface, error) {
 	var conf *Config
 	var err error
 	conf, err = LoadConfig()
+	// a missing config is the default, anything else is reported by Ready
+	var configErr error
+	if err != nil && !os.IsNotExist(iodine.ToError(err)) {
+		configErr = err
+	}
 	if err != nil {
 		conf = &Config{
 			Version:     "0.0.1",
@@ -87,16 +120,33 @@ func New() (Interface, error) {
 			return nil, iodine.New(err, nil)
 		}
 	}
-	a := API{config: conf}
+	a := API{config: conf, configErr: configErr}
 	a.storedBuckets = metadata.NewCache()
 	a.nodes = make(map[string]node)
 	a.buckets = make(map[string]bucket)
-	a.objects = data.NewCache(a.config.MaxSize)
+	a.bucketsLock = new(sync.RWMutex)
+	cachePolicy, err := getCachePolicy(a.config.CachePolicy)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	a.objects = data.NewCache(a.config.MaxSize, time.Duration(a.config.CacheTTL)*time.Second, cachePolicy)
 	a.multiPartObjects = make(map[string]*data.Cache)
-	a.objects.OnEvicted = a.evictedObject
 	a.lock = new(sync.Mutex)
+	a.nsMutex = newNSLockMap()
+	// evictedObject holds a copy of the API, the lock must be set before
+	a.objects.OnEvicted = a.evictedObject
 
 	if len(a.config.NodeDiskMap) > 0 {
+		if a.config.DiskCache != "" {
+			diskCachePolicy, err := getCachePolicy(a.config.CachePolicy)
+			if err != nil {
+				return nil, iodine.New(err, nil)
+			}
+			a.diskCache, err = file.NewCache(a.config.DiskCache, a.config.DiskCacheSize, diskCachePolicy)
+			if err != nil {
+				return nil, iodine.New(err, nil)
+			}
+		}
 		for k, v := range a.config.NodeDiskMap {
 			if len(v) == 0 {
 				return nil, iodine.New(InvalidDisksArgument{}, nil)
@@ -106,6 +156,9 @@ func New() (Interface, error) {
 				return nil, iodine.New(err, nil)
 			}
 		}
+		if err := a.migrateObjectIndexes(); err != nil {
+			return nil, iodine.New(err, nil)
+		}
 		/// Initialization, populate all buckets into memory
 		buckets, err := a.listBuckets()
 		if err != nil {
@@ -119,52 +172,66 @@ func New() (Interface, error) {
 			newBucket.partMetadata = make(map[string]map[int]PartMetadata)
 			a.storedBuckets.Set(k, newBucket)
 		}
+		if a.config.WriteBack != "" {
+			a.writeBack, err = newWriteBack(a.config.WriteBack)
+			if err != nil {
+				return nil, iodine.New(err, nil)
+			}
+			// objects journaled before a shutdown or crash are written first
+			if err := a.startWriteBack(); err != nil {
+				return nil, iodine.New(err, nil)
+			}
+		}
+	} else if a.config.Snapshot != "" {
+		a.snapshot, err = newSnapshot(a.config.Snapshot)
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		// evictions are journaled, evictedObject needs a copy of the API holding the snapshot
+		a.objects.OnEvicted = a.evictedObject
+		if err := a.replaySnapshot(); err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		// a fresh snapshot replaces the journals replayed, from here on changes are journaled
+		if err := a.writeSnapshot(); err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		if a.config.SnapshotInterval > 0 {
+			go a.snapshotEvery(time.Duration(a.config.SnapshotInterval) * time.Second)
+		}
 	}
 	return a, nil
 }
 
+// Flush - write everything acknowledged but only held in memory, written back objects to the disks and
+// without disks a snapshot. Used on shutdown once no more writes are accepted
+func (donut API) Flush() error {
+	if donut.writeBack != nil {
+		err := donut.waitWriteBack()
+		// the next process replays whatever could not be written
+		donut.writeBack.release()
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	if donut.snapshot != nil {
+		if err := donut.writeSnapshot(); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
 /// V2 API functions
 
 // GetObject - GET object from cache buffer
 func (donut API) GetObject(w io.Writer, bucket string, object string) (int64, error) {
-	donut.lock.Lock()
-	defer donut.lock.Unlock()
-
-	if !IsValidBucket(bucket) {
-		return 0, iodine.New(BucketNameInvalid{Bucket: bucket}, nil)
-	}
-	if !IsValidObjectName(object) {
-		return 0, iodine.New(ObjectNameInvalid{Object: object}, nil)
-	}
-	if !donut.storedBuckets.Exists(bucket) {
-		return 0, iodine.New(BucketNotFound{Bucket: bucket}, nil)
-	}
-	objectKey := bucket + "/" + object
-	data, ok := donut.objects.Get(objectKey)
-	if !ok {
-		if len(donut.config.NodeDiskMap) > 0 {
-			reader, size, err := donut.getObject(bucket, object)
-			if err != nil {
-				return 0, iodine.New(err, nil)
-			}
-			// new proxy writer to capture data read from disk
-			pw := NewProxyWriter(w)
-			written, err := io.CopyN(pw, reader, size)
-			if err != nil {
-				return 0, iodine.New(err, nil)
-			}
-			/// cache object read from disk
-			ok := donut.objects.Append(objectKey, pw.writtenBytes)
-			pw.writtenBytes = nil
-			go debug.FreeOSMemory()
-			if !ok {
-				return 0, iodine.New(InternalError{}, nil)
-			}
-			return written, nil
-		}
-		return 0, iodine.New(ObjectNotFound{Object: object}, nil)
+	version, err := donut.OpenObject(bucket, object, nil)
+	if err != nil {
+		return 0, iodine.New(err, nil)
 	}
-	written, err := io.CopyN(w, bytes.NewBuffer(data), int64(donut.objects.Len(objectKey)))
+	defer version.Close()
+	written, err := version.Read(w, 0, version.Metadata.Size)
 	if err != nil {
 		return 0, iodine.New(err, nil)
 	}
@@ -173,61 +240,90 @@ func (donut API) GetObject(w io.Writer, bucket string, object string) (int64, er
 
 // GetPartialObject - GET object from cache buffer range
 func (donut API) GetPartialObject(w io.Writer, bucket, object string, start, length int64) (int64, error) {
-	donut.lock.Lock()
-	defer donut.lock.Unlock()
+	version, err := donut.OpenObject(bucket, object, nil)
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	defer version.Close()
+	written, err := version.Read(w, start, length)
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	return written, nil
+}
+
+// ObjectVersion - a version of an object held open for reading, its metadata and data stay consistent
+// as overwrites of the object wait until it is closed
+type ObjectVersion struct {
+	Metadata ObjectMetadata
+	donut    API
+	bucket   string
+	key      string
+	closed   bool
+}
+
+// OpenObject - open the current version of an object under the object read lock, the version must be closed
+func (donut API) OpenObject(bucket, key string, signature *Signature) (*ObjectVersion, error) {
+	if signature != nil {
+		ok, err := signature.DoesSignatureMatch("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		if !ok {
+			return nil, iodine.New(SignatureDoesNotMatch{}, nil)
+		}
+	}
+	if !IsValidBucket(bucket) {
+		return nil, iodine.New(BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	if !IsValidObjectName(key) {
+		return nil, iodine.New(ObjectNameInvalid{Object: key}, nil)
+	}
+	if !donut.storedBuckets.Exists(bucket) {
+		return nil, iodine.New(BucketNotFound{Bucket: bucket}, nil)
+	}
+	donut.nsMutex.RLock(bucket, key)
+	objMetadata, err := donut.lookupObjectMetadata(bucket, key)
+	if err != nil {
+		donut.nsMutex.RUnlock(bucket, key)
+		return nil, iodine.New(err, nil)
+	}
+	return &ObjectVersion{Metadata: objMetadata, donut: donut, bucket: bucket, key: key}, nil
+}
 
+// Read - write a range of the version to w, only the chunks covering the range are read and cached
+func (v *ObjectVersion) Read(w io.Writer, start, length int64) (int64, error) {
 	errParams := map[string]string{
-		"bucket": bucket,
-		"object": object,
+		"bucket": v.bucket,
+		"object": v.key,
 		"start":  strconv.FormatInt(start, 10),
 		"length": strconv.FormatInt(length, 10),
 	}
-
-	if !IsValidBucket(bucket) {
-		return 0, iodine.New(BucketNameInvalid{Bucket: bucket}, errParams)
-	}
-	if !IsValidObjectName(object) {
-		return 0, iodine.New(ObjectNameInvalid{Object: object}, errParams)
+	if v.closed {
+		return 0, iodine.New(errors.New("read of a closed object version"), errParams)
 	}
-	if start < 0 {
+	if start < 0 || length < 0 || start+length > v.Metadata.Size {
 		return 0, iodine.New(InvalidRange{
 			Start:  start,
 			Length: length,
 		}, errParams)
 	}
-	objectKey := bucket + "/" + object
-	data, ok := donut.objects.Get(objectKey)
-	if !ok {
-		if len(donut.config.NodeDiskMap) > 0 {
-			reader, _, err := donut.getObject(bucket, object)
-			if err != nil {
-				return 0, iodine.New(err, nil)
-			}
-			if _, err := io.CopyN(ioutil.Discard, reader, start); err != nil {
-				return 0, iodine.New(err, nil)
-			}
-			pw := NewProxyWriter(w)
-			written, err := io.CopyN(w, reader, length)
-			if err != nil {
-				return 0, iodine.New(err, nil)
-			}
-			ok := donut.objects.Append(objectKey, pw.writtenBytes)
-			pw.writtenBytes = nil
-			go debug.FreeOSMemory()
-			if !ok {
-				return 0, iodine.New(InternalError{}, nil)
-			}
-			return written, nil
-		}
-		return 0, iodine.New(ObjectNotFound{Object: object}, nil)
-	}
-	written, err := io.CopyN(w, bytes.NewBuffer(data[start:]), length)
+	written, err := v.donut.getObjectChunks(w, v.Metadata, start, length)
 	if err != nil {
-		return 0, iodine.New(err, nil)
+		return 0, iodine.New(err, errParams)
 	}
 	return written, nil
 }
 
+// Close - release the object read lock, closing twice is harmless
+func (v *ObjectVersion) Close() {
+	if v.closed {
+		return
+	}
+	v.closed = true
+	v.donut.nsMutex.RUnlock(v.bucket, v.key)
+}
+
 // GetBucketMetadata -
 func (donut API) GetBucketMetadata(bucket string, signature *Signature) (BucketMetadata, error) {
 	donut.lock.Lock()
@@ -290,6 +386,7 @@ func (donut API) SetBucketMetadata(bucket string, metadata map[string]string, si
 	storedBucket := donut.storedBuckets.Get(bucket).(storedBucket)
 	storedBucket.bucketMetadata.ACL = BucketACL(metadata["acl"])
 	donut.storedBuckets.Set(bucket, storedBucket)
+	donut.journalBucket(storedBucket.bucketMetadata)
 	return nil
 }
 
@@ -314,19 +411,16 @@ func isMD5SumEqual(expectedMD5Sum, actualMD5Sum string) error {
 
 // CreateObject - create an object
 func (donut API) CreateObject(bucket, key, expectedMD5Sum string, size int64, data io.Reader, metadata map[string]string, signature *Signature) (ObjectMetadata, error) {
-	donut.lock.Lock()
-	defer donut.lock.Unlock()
-
-	contentType := metadata["contentType"]
-	objectMetadata, err := donut.createObject(bucket, key, contentType, expectedMD5Sum, size, data, signature)
-	// free
-	debug.FreeOSMemory()
+	donut.nsMutex.Lock(bucket, key)
+	defer donut.nsMutex.Unlock(bucket, key)
 
+	objectMetadata, err := donut.createObject(bucket, key, expectedMD5Sum, size, data, metadata, signature)
 	return objectMetadata, iodine.New(err, nil)
 }
 
-// createObject - PUT object to cache buffer
-func (donut API) createObject(bucket, key, contentType, expectedMD5Sum string, size int64, data io.Reader, signature *Signature) (ObjectMetadata, error) {
+// createObject - PUT object, caller holds the namespace write lock of the object. With disks the object
+// is streamed to them and only enters the cache once read, otherwise it is kept in the cache buffer
+func (donut API) createObject(bucket, key, expectedMD5Sum string, size int64, data io.Reader, metadata map[string]string, signature *Signature) (ObjectMetadata, error) {
 	if len(donut.config.NodeDiskMap) == 0 {
 		if size > int64(donut.config.MaxSize) {
 			generic := GenericObjectError{Bucket: bucket, Object: key}
@@ -346,17 +440,32 @@ func (donut API) createObject(bucket, key, contentType, expectedMD5Sum string, s
 	if !donut.storedBuckets.Exists(bucket) {
 		return ObjectMetadata{}, iodine.New(BucketNotFound{Bucket: bucket}, nil)
 	}
-	storedBucket := donut.storedBuckets.Get(bucket).(storedBucket)
 	// get object key
 	objectKey := bucket + "/" + key
-	if _, ok := storedBucket.objectMetadata[objectKey]; ok == true {
-		return ObjectMetadata{}, iodine.New(ObjectExists{Object: key}, nil)
+	// preconditions are verified while holding the object write lock, the check and the commit below are atomic
+	replace, err := donut.checkWritePreconditions(bucket, key, metadata)
+	if err != nil {
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	// the previous version stays cached until the new one is stored, a failed write must not lose it
+	var previousObject ObjectMetadata
+	if replace {
+		previousObject, replace = donut.getCachedObjectMetadata(bucket, objectKey)
 	}
 
+	// persist all object metadata, write preconditions are only meant for this request
+	objectMetadata := make(map[string]string)
+	for k, v := range metadata {
+		if k == "ifMatch" || k == "ifNoneMatch" {
+			continue
+		}
+		objectMetadata[k] = v
+	}
+	contentType := objectMetadata["contentType"]
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
-	contentType = strings.TrimSpace(contentType)
+	objectMetadata["contentType"] = strings.TrimSpace(contentType)
 	if strings.TrimSpace(expectedMD5Sum) != "" {
 		expectedMD5SumBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(expectedMD5Sum))
 		if err != nil {
@@ -367,84 +476,181 @@ func (donut API) createObject(bucket, key, contentType, expectedMD5Sum string, s
 	}
 
 	if len(donut.config.NodeDiskMap) > 0 {
-		objMetadata, err := donut.putObject(
-			bucket,
-			key,
-			expectedMD5Sum,
-			data,
-			map[string]string{
-				"contentType":   contentType,
-				"contentLength": strconv.FormatInt(size, 10),
-			},
-			signature,
-		)
+		objectMetadata["contentLength"] = strconv.FormatInt(size, 10)
+		var objMetadata ObjectMetadata
+		if donut.writeBack != nil && size <= maxWriteBackSize {
+			objMetadata, err = donut.writeBackObject(bucket, key, expectedMD5Sum, size, data, objectMetadata, signature)
+		} else {
+			objMetadata, err = donut.putObject(
+				bucket,
+				key,
+				expectedMD5Sum,
+				data,
+				objectMetadata,
+				signature,
+			)
+		}
 		if err != nil {
 			return ObjectMetadata{}, iodine.New(err, nil)
 		}
-		storedBucket.objectMetadata[objectKey] = objMetadata
-		donut.storedBuckets.Set(bucket, storedBucket)
+		if replace {
+			donut.deleteObjectChunks(previousObject)
+		}
+		donut.setCachedObjectMetadata(bucket, objectKey, objMetadata)
 		return objMetadata, nil
 	}
-	// calculate md5
+	chunks, md5Sum, err := readObjectChunks(bucket, key, size, data, expectedMD5Sum, signature)
+	if err != nil {
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+
+	newObject := ObjectMetadata{
+		Bucket: bucket,
+		Object: key,
+
+		Metadata: objectMetadata,
+		Created:  time.Now().UTC(),
+		MD5Sum:   md5Sum,
+		Size:     size,
+	}
+	// an identical previous version shares its chunk keys with the new one
+	if replace && previousObject.MD5Sum == newObject.MD5Sum {
+		donut.deleteObjectChunks(previousObject)
+	}
+	// all chunks are added at once, the later chunks of the object must not evict its earlier ones
+	chunkKeys := make([]interface{}, len(chunks))
+	for index := range chunks {
+		chunkKeys[index] = newChunkKey(newObject, int64(index))
+	}
+	if ok := donut.objects.SetAll(chunkKeys, chunks); !ok {
+		return ObjectMetadata{}, iodine.New(InternalError{}, nil)
+	}
+
+	donut.lock.Lock()
+	storedBucket := donut.storedBuckets.Get(bucket).(storedBucket)
+	storedBucket.objectMetadata[objectKey] = newObject
+	donut.storedBuckets.Set(bucket, storedBucket)
+	donut.journalObject(newObject, chunks)
+	donut.lock.Unlock()
+	if replace && previousObject.MD5Sum != newObject.MD5Sum {
+		donut.deleteObjectChunks(previousObject)
+	}
+	return newObject, nil
+}
+
+// readObjectChunks - read an object of the given size into cache sized chunks, verifying its md5sum and
+// signature. Chunks are only cached once the object is verified, their keys carry its md5sum
+func readObjectChunks(bucket, key string, size int64, data io.Reader, expectedMD5Sum string, signature *Signature) ([][]byte, string, error) {
 	hash := md5.New()
 	sha256hash := sha256.New()
 
-	var err error
+	var chunks [][]byte
 	var totalLength int64
-	for err == nil {
-		var length int
-		byteBuffer := make([]byte, 1024*1024)
-		length, err = data.Read(byteBuffer)
-		hash.Write(byteBuffer[0:length])
-		sha256hash.Write(byteBuffer[0:length])
-		ok := donut.objects.Append(objectKey, byteBuffer[0:length])
-		if !ok {
-			return ObjectMetadata{}, iodine.New(InternalError{}, nil)
+	for {
+		chunk := make([]byte, cacheChunkSize)
+		length, err := io.ReadFull(data, chunk)
+		if length > 0 {
+			hash.Write(chunk[:length])
+			sha256hash.Write(chunk[:length])
+			chunks = append(chunks, chunk[:length])
+			totalLength += int64(length)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, "", iodine.New(err, nil)
 		}
-		totalLength += int64(length)
-		go debug.FreeOSMemory()
 	}
 	if totalLength != size {
-		// Delete perhaps the object is already saved, due to the nature of append()
-		donut.objects.Delete(objectKey)
-		return ObjectMetadata{}, iodine.New(IncompleteBody{Bucket: bucket, Object: key}, nil)
+		return nil, "", iodine.New(IncompleteBody{Bucket: bucket, Object: key}, nil)
 	}
-	if err != io.EOF {
-		return ObjectMetadata{}, iodine.New(err, nil)
-	}
-	md5SumBytes := hash.Sum(nil)
-	md5Sum := hex.EncodeToString(md5SumBytes)
+	md5Sum := hex.EncodeToString(hash.Sum(nil))
 	// Verify if the written object is equal to what is expected, only if it is requested as such
 	if strings.TrimSpace(expectedMD5Sum) != "" {
 		if err := isMD5SumEqual(strings.TrimSpace(expectedMD5Sum), md5Sum); err != nil {
-			return ObjectMetadata{}, iodine.New(BadDigest{}, nil)
+			return nil, "", iodine.New(BadDigest{}, nil)
 		}
 	}
 	if signature != nil {
 		ok, err := signature.DoesSignatureMatch(hex.EncodeToString(sha256hash.Sum(nil)))
 		if err != nil {
-			return ObjectMetadata{}, iodine.New(err, nil)
+			return nil, "", iodine.New(err, nil)
 		}
 		if !ok {
-			return ObjectMetadata{}, iodine.New(SignatureDoesNotMatch{}, nil)
+			return nil, "", iodine.New(SignatureDoesNotMatch{}, nil)
 		}
 	}
+	return chunks, md5Sum, nil
+}
 
-	m := make(map[string]string)
-	m["contentType"] = contentType
-	newObject := ObjectMetadata{
-		Bucket: bucket,
-		Object: key,
-
-		Metadata: m,
-		Created:  time.Now().UTC(),
-		MD5Sum:   md5Sum,
-		Size:     int64(totalLength),
+// lookupObjectMetadata - get object metadata from memory, or from disk holding on to it, caller holds
+// the namespace lock of the object
+func (donut API) lookupObjectMetadata(bucket, object string) (ObjectMetadata, error) {
+	objectKey := bucket + "/" + object
+	if objMetadata, ok := donut.getCachedObjectMetadata(bucket, objectKey); ok {
+		return objMetadata, nil
+	}
+	if len(donut.config.NodeDiskMap) > 0 {
+		objMetadata, err := donut.getObjectMetadata(bucket, object)
+		if err != nil {
+			return ObjectMetadata{}, iodine.New(err, nil)
+		}
+		donut.setCachedObjectMetadata(bucket, objectKey, objMetadata)
+		return objMetadata, nil
 	}
+	return ObjectMetadata{}, iodine.New(ObjectNotFound{Object: object}, nil)
+}
 
-	storedBucket.objectMetadata[objectKey] = newObject
+// getCachedObjectMetadata - get object metadata held in memory
+func (donut API) getCachedObjectMetadata(bucket, objectKey string) (ObjectMetadata, bool) {
+	donut.lock.Lock()
+	defer donut.lock.Unlock()
+	objMetadata, ok := donut.storedBuckets.Get(bucket).(storedBucket).objectMetadata[objectKey]
+	return objMetadata, ok
+}
+
+// setCachedObjectMetadata - hold object metadata in memory
+func (donut API) setCachedObjectMetadata(bucket, objectKey string, objMetadata ObjectMetadata) {
+	donut.lock.Lock()
+	defer donut.lock.Unlock()
+	storedBucket := donut.storedBuckets.Get(bucket).(storedBucket)
+	storedBucket.objectMetadata[objectKey] = objMetadata
 	donut.storedBuckets.Set(bucket, storedBucket)
-	return newObject, nil
+}
+
+// checkWritePreconditions - verify 'ifMatch' and 'ifNoneMatch' conditions against the current version
+// of an object, returns true if the current version is to be replaced
+func (donut API) checkWritePreconditions(bucket, key string, metadata map[string]string) (bool, error) {
+	objMetadata, exists := donut.getCachedObjectMetadata(bucket, bucket+"/"+key)
+	if !exists && len(donut.config.NodeDiskMap) > 0 {
+		var err error
+		objMetadata, err = donut.getObjectMetadata(bucket, key)
+		switch iodine.ToError(err).(type) {
+		case nil:
+			exists = true
+		case ObjectNotFound:
+			exists = false
+		default:
+			return false, iodine.New(err, nil)
+		}
+	}
+	ifMatch := strings.TrimSpace(metadata["ifMatch"])
+	ifNoneMatch := strings.TrimSpace(metadata["ifNoneMatch"])
+	if exists && ifNoneMatch != "" && isETagMatch(ifNoneMatch, objMetadata.MD5Sum) {
+		return false, iodine.New(PreconditionFailed{Object: key}, nil)
+	}
+	if ifMatch != "" {
+		// like S3, a missing object fails If-Match rather than being not found
+		if !exists || !isETagMatch(ifMatch, objMetadata.MD5Sum) {
+			return false, iodine.New(PreconditionFailed{Object: key}, nil)
+		}
+		return true, nil
+	}
+	if exists {
+		return false, iodine.New(ObjectExists{Object: key}, nil)
+	}
+	return false, nil
 }
 
 // MakeBucket - create bucket in cache
@@ -493,14 +699,12 @@ func (donut API) MakeBucket(bucketName, acl string, signature *Signature) error
 	newBucket.bucketMetadata.Created = time.Now().UTC()
 	newBucket.bucketMetadata.ACL = BucketACL(acl)
 	donut.storedBuckets.Set(bucketName, newBucket)
+	donut.journalBucket(newBucket.bucketMetadata)
 	return nil
 }
 
 // ListObjects - list objects from cache
 func (donut API) ListObjects(bucket string, resources BucketResourcesMetadata, signature *Signature) ([]ObjectMetadata, BucketResourcesMetadata, error) {
-	donut.lock.Lock()
-	defer donut.lock.Unlock()
-
 	if signature != nil {
 		ok, err := signature.DoesSignatureMatch("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
 		if err != nil {
@@ -535,9 +739,6 @@ func (donut API) ListObjects(bucket string, resources BucketResourcesMetadata, s
 		}
 		resources.CommonPrefixes = listObjects.CommonPrefixes
 		resources.IsTruncated = listObjects.IsTruncated
-		if resources.IsTruncated && resources.Delimiter != "" {
-			resources.NextMarker = results[len(results)-1].Object
-		}
 		for key := range listObjects.Objects {
 			keys = append(keys, key)
 		}
@@ -545,8 +746,12 @@ func (donut API) ListObjects(bucket string, resources BucketResourcesMetadata, s
 		for _, key := range keys {
 			results = append(results, listObjects.Objects[key])
 		}
+		resources.NextMarker = listObjects.NextMarker
 		return results, resources, nil
 	}
+	donut.lock.Lock()
+	defer donut.lock.Unlock()
+
 	storedBucket := donut.storedBuckets.Get(bucket).(storedBucket)
 	for key := range storedBucket.objectMetadata {
 		if strings.HasPrefix(key, bucket+"/") {
@@ -579,9 +784,7 @@ func (donut API) ListObjects(bucket string, resources BucketResourcesMetadata, s
 	for _, key := range filteredKeys {
 		if len(results) == resources.Maxkeys {
 			resources.IsTruncated = true
-			if resources.IsTruncated && resources.Delimiter != "" {
-				resources.NextMarker = results[len(results)-1].Object
-			}
+			resources.NextMarker = getNextMarker(results, resources.CommonPrefixes)
 			return results, resources, nil
 		}
 		object := storedBucket.objectMetadata[bucket+"/"+resources.Prefix+key]
@@ -592,6 +795,18 @@ func (donut API) ListObjects(bucket string, resources BucketResourcesMetadata, s
 	return results, resources, nil
 }
 
+// getNextMarker - marker to continue a truncated listing from, the last object listed
+// or the last common prefix when no objects were listed
+func getNextMarker(results []ObjectMetadata, commonPrefixes []string) string {
+	if len(results) > 0 {
+		return results[len(results)-1].Object
+	}
+	if len(commonPrefixes) > 0 {
+		return commonPrefixes[len(commonPrefixes)-1]
+	}
+	return ""
+}
+
 // byBucketName is a type for sorting bucket metadata by bucket name
 type byBucketName []BucketMetadata
 
@@ -635,56 +850,34 @@ func (donut API) ListBuckets(signature *Signature) ([]BucketMetadata, error) {
 
 // GetObjectMetadata - get object metadata from cache
 func (donut API) GetObjectMetadata(bucket, key string, signature *Signature) (ObjectMetadata, error) {
-	donut.lock.Lock()
-	defer donut.lock.Unlock()
-
-	if signature != nil {
-		ok, err := signature.DoesSignatureMatch("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
-		if err != nil {
-			return ObjectMetadata{}, iodine.New(err, nil)
-		}
-		if !ok {
-			return ObjectMetadata{}, iodine.New(SignatureDoesNotMatch{}, nil)
-		}
-	}
-
-	// check if bucket exists
-	if !IsValidBucket(bucket) {
-		return ObjectMetadata{}, iodine.New(BucketNameInvalid{Bucket: bucket}, nil)
-	}
-	if !IsValidObjectName(key) {
-		return ObjectMetadata{}, iodine.New(ObjectNameInvalid{Object: key}, nil)
-	}
-	if !donut.storedBuckets.Exists(bucket) {
-		return ObjectMetadata{}, iodine.New(BucketNotFound{Bucket: bucket}, nil)
-	}
-	storedBucket := donut.storedBuckets.Get(bucket).(storedBucket)
-	objectKey := bucket + "/" + key
-	if objMetadata, ok := storedBucket.objectMetadata[objectKey]; ok == true {
-		return objMetadata, nil
-	}
-	if len(donut.config.NodeDiskMap) > 0 {
-		objMetadata, err := donut.getObjectMetadata(bucket, key)
-		if err != nil {
-			return ObjectMetadata{}, iodine.New(err, nil)
-		}
-		// update
-		storedBucket.objectMetadata[objectKey] = objMetadata
-		donut.storedBuckets.Set(bucket, storedBucket)
-		return objMetadata, nil
+	version, err := donut.OpenObject(bucket, key, signature)
+	if err != nil {
+		return ObjectMetadata{}, iodine.New(err, nil)
 	}
-	return ObjectMetadata{}, iodine.New(ObjectNotFound{Object: key}, nil)
+	version.Close()
+	return version.Metadata, nil
 }
 
 // evictedObject callback function called when an item is evicted from memory
 func (donut API) evictedObject(a ...interface{}) {
+	// with disks a chunk is merely read again, otherwise the object it belongs to is lost
+	if len(donut.config.NodeDiskMap) > 0 {
+		return
+	}
+	key := a[0].(chunkKey)
 	cacheStats := donut.objects.Stats()
-	log.Printf("CurrentSize: %d, CurrentItems: %d, TotalEvicted: %d",
+	log.Printf("Evicted %s/%s, CurrentSize: %d, CurrentItems: %d, TotalEvicted: %d", key.bucket, key.object,
 		cacheStats.Bytes, cacheStats.Items, cacheStats.Evicted)
-	key := a[0].(string)
-	// loop through all buckets
-	for _, bucket := range donut.storedBuckets.GetAll() {
-		delete(bucket.(storedBucket).objectMetadata, key)
+	donut.lock.Lock()
+	defer donut.lock.Unlock()
+	if !donut.storedBuckets.Exists(key.bucket) {
+		return
+	}
+	// remaining chunks of the object are never read again and age out of the cache
+	objectKey := key.bucket + "/" + key.object
+	objectMetadata := donut.storedBuckets.Get(key.bucket).(storedBucket).objectMetadata
+	if objMetadata, ok := objectMetadata[objectKey]; ok && objMetadata.MD5Sum == key.etag {
+		delete(objectMetadata, objectKey)
+		donut.journalEviction(objMetadata)
 	}
-	debug.FreeOSMemory()
 }
@@ -21,12 +21,16 @@ import (
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/xml"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/minio/check"
+	"github.com/minio/minio/pkg/iodine"
 )
 
 func TestCache(t *testing.T) { TestingT(t) }
@@ -263,3 +267,251 @@ func (s *MyCacheSuite) TestMultipleNewObjects(c *C) {
 	c.Assert(resources.IsTruncated, Equals, true)
 	c.Assert(len(objectsMetadata), Equals, 2)
 }
+
+// test a stalled upload does not block other objects
+func (s *MyCacheSuite) TestSlowObjectDoesNotBlockOthers(c *C) {
+	c.Assert(dc.MakeBucket("foo-slow", "private", nil), IsNil)
+
+	one := ioutil.NopCloser(bytes.NewReader([]byte("one")))
+	_, err := dc.CreateObject("foo-slow", "obj1", "", int64(len("one")), one, nil, nil)
+	c.Assert(err, IsNil)
+
+	// upload which stalls until its writer is closed
+	reader, writer := io.Pipe()
+	uploadErr := make(chan error)
+	go func() {
+		_, err := dc.CreateObject("foo-slow", "obj2", "", 1024, reader, nil, nil)
+		uploadErr <- err
+	}()
+	_, err = writer.Write([]byte("partial"))
+	c.Assert(err, IsNil)
+
+	done := make(chan error)
+	go func() {
+		var buffer bytes.Buffer
+		if _, err := dc.GetObject(&buffer, "foo-slow", "obj1"); err != nil {
+			done <- err
+			return
+		}
+		_, err := dc.GetObjectMetadata("foo-slow", "obj1", nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		c.Assert(err, IsNil)
+	case <-time.After(5 * time.Second):
+		c.Fatal("reading an object was blocked by an unrelated upload")
+	}
+
+	writer.Close()
+	c.Assert(<-uploadErr, Not(IsNil))
+}
+
+// test objects evicted to make room for new ones do not stall the cache
+func (s *MyCacheSuite) TestSmallCacheEvictsObjects(c *C) {
+	customConfigPath := CustomConfigPath
+	defer func() { CustomConfigPath = customConfigPath }()
+	CustomConfigPath = filepath.Join(s.root, "donut-small.json")
+	c.Assert(SaveConfig(&Config{Version: "0.0.1", MaxSize: 200000}), IsNil)
+	small, err := New()
+	c.Assert(err, IsNil)
+	c.Assert(small.MakeBucket("foo-small", "private", nil), IsNil)
+
+	data := bytes.Repeat([]byte("a"), 100000)
+	for _, object := range []string{"obj1", "obj2", "obj3"} {
+		reader := ioutil.NopCloser(bytes.NewReader(data))
+		_, err := small.CreateObject("foo-small", object, "", int64(len(data)), reader, nil, nil)
+		c.Assert(err, IsNil)
+	}
+	// the oldest object had to go, the newest one is intact
+	_, err = small.GetObjectMetadata("foo-small", "obj1", nil)
+	c.Assert(err, Not(IsNil))
+	var buffer bytes.Buffer
+	_, err = small.GetObject(&buffer, "foo-small", "obj3")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.Bytes(), DeepEquals, data)
+}
+
+// test an object is never stored without its first chunks, even if the policy prefers evicting new chunks
+func (s *MyCacheSuite) TestSmallLFUCacheKeepsWholeObjects(c *C) {
+	customConfigPath := CustomConfigPath
+	defer func() { CustomConfigPath = customConfigPath }()
+	CustomConfigPath = filepath.Join(s.root, "donut-lfu.json")
+	c.Assert(SaveConfig(&Config{Version: "0.0.1", MaxSize: 200000, CachePolicy: "lfu"}), IsNil)
+	small, err := New()
+	c.Assert(err, IsNil)
+	c.Assert(small.MakeBucket("foo-lfu", "private", nil), IsNil)
+
+	data := bytes.Repeat([]byte("a"), 100000)
+	reader := ioutil.NopCloser(bytes.NewReader(data))
+	_, err = small.CreateObject("foo-lfu", "obj1", "", int64(len(data)), reader, nil, nil)
+	c.Assert(err, IsNil)
+	var buffer bytes.Buffer
+	_, err = small.GetObject(&buffer, "foo-lfu", "obj1")
+	c.Assert(err, IsNil)
+
+	// the chunks of obj1 are used more often, still they have to go for obj2
+	data = bytes.Repeat([]byte("b"), 150000)
+	reader = ioutil.NopCloser(bytes.NewReader(data))
+	_, err = small.CreateObject("foo-lfu", "obj2", "", int64(len(data)), reader, nil, nil)
+	c.Assert(err, IsNil)
+	buffer.Reset()
+	_, err = small.GetObject(&buffer, "foo-lfu", "obj2")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.Bytes(), DeepEquals, data)
+	_, err = small.GetObjectMetadata("foo-lfu", "obj1", nil)
+	c.Assert(err, Not(IsNil))
+}
+
+// test buckets and objects survive a restart through the snapshot and the changes journaled after it
+func (s *MyCacheSuite) TestSnapshotSurvivesRestart(c *C) {
+	customConfigPath := CustomConfigPath
+	defer func() { CustomConfigPath = customConfigPath }()
+	CustomConfigPath = filepath.Join(s.root, "donut-snapshot.json")
+	conf := &Config{Version: "0.0.1", MaxSize: 1000000, Snapshot: filepath.Join(s.root, "snapshot", "donut.snapshot")}
+	c.Assert(SaveConfig(conf), IsNil)
+	snapshotted, err := New()
+	c.Assert(err, IsNil)
+
+	data := bytes.Repeat([]byte("a"), 2*cacheChunkSize+10)
+	c.Assert(snapshotted.MakeBucket("foo-snapshot", "private", nil), IsNil)
+	reader := ioutil.NopCloser(bytes.NewReader(data))
+	_, err = snapshotted.CreateObject("foo-snapshot", "flushed", "", int64(len(data)), reader, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(snapshotted.Flush(), IsNil)
+
+	// changes after the snapshot are only journaled, as if the process crashed before shutting down
+	c.Assert(snapshotted.SetBucketMetadata("foo-snapshot", map[string]string{"acl": "public-read"}, nil), IsNil)
+	reader = ioutil.NopCloser(bytes.NewReader([]byte("journaled")))
+	_, err = snapshotted.CreateObject("foo-snapshot", "journaled", "", int64(len("journaled")), reader, nil, nil)
+	c.Assert(err, IsNil)
+
+	restarted, err := New()
+	c.Assert(err, IsNil)
+	bucketMetadata, err := restarted.GetBucketMetadata("foo-snapshot", nil)
+	c.Assert(err, IsNil)
+	c.Assert(bucketMetadata.ACL, Equals, BucketACL("public-read"))
+	var buffer bytes.Buffer
+	_, err = restarted.GetObject(&buffer, "foo-snapshot", "flushed")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.Bytes(), DeepEquals, data)
+	buffer.Reset()
+	_, err = restarted.GetObject(&buffer, "foo-snapshot", "journaled")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "journaled")
+
+	// the journals replayed are folded into a fresh snapshot
+	journals, err := filepath.Glob(conf.Snapshot + ".journal.*")
+	c.Assert(err, IsNil)
+	c.Assert(len(journals), Equals, 1)
+}
+
+// test objects evicted before a crash stay evicted, and writing a snapshot is not a cache access
+func (s *MyCacheSuite) TestSnapshotJournalsEvictions(c *C) {
+	customConfigPath := CustomConfigPath
+	defer func() { CustomConfigPath = customConfigPath }()
+	CustomConfigPath = filepath.Join(s.root, "donut-snapshot-evictions.json")
+	conf := &Config{Version: "0.0.1", MaxSize: 2 * cacheChunkSize, Snapshot: filepath.Join(s.root, "evictions", "donut.snapshot")}
+	c.Assert(SaveConfig(conf), IsNil)
+	snapshotted, err := New()
+	c.Assert(err, IsNil)
+
+	data := bytes.Repeat([]byte("a"), cacheChunkSize)
+	c.Assert(snapshotted.MakeBucket("foo-evictions", "private", nil), IsNil)
+	_, err = snapshotted.CreateObject("foo-evictions", "first", "", int64(len(data)), ioutil.NopCloser(bytes.NewReader(data)), nil, nil)
+	c.Assert(err, IsNil)
+	stats := snapshotted.(API).objects.Stats()
+	c.Assert(snapshotted.Flush(), IsNil)
+	c.Assert(snapshotted.(API).objects.Stats(), DeepEquals, stats)
+
+	// both objects do not fit, the second one evicts the first
+	_, err = snapshotted.CreateObject("foo-evictions", "second", "", int64(len(data)), ioutil.NopCloser(bytes.NewReader(data)), nil, nil)
+	c.Assert(err, IsNil)
+	_, err = snapshotted.CreateObject("foo-evictions", "third", "", int64(len(data)), ioutil.NopCloser(bytes.NewReader(data)), nil, nil)
+	c.Assert(err, IsNil)
+	_, err = snapshotted.GetObjectMetadata("foo-evictions", "first", nil)
+	c.Assert(err, Not(IsNil))
+
+	// a restart with room for all of them does not bring the evicted object back
+	conf.MaxSize = 1000000
+	c.Assert(SaveConfig(conf), IsNil)
+	restarted, err := New()
+	c.Assert(err, IsNil)
+	_, err = restarted.GetObjectMetadata("foo-evictions", "first", nil)
+	c.Assert(err, Not(IsNil))
+	_, err = restarted.GetObjectMetadata("foo-evictions", "third", nil)
+	c.Assert(err, IsNil)
+}
+
+// test a failed conditional overwrite keeps the previous version
+func (s *MyCacheSuite) TestReplaceFailureKeepsObject(c *C) {
+	c.Assert(dc.MakeBucket("foo-replace", "private", nil), IsNil)
+	reader := ioutil.NopCloser(bytes.NewReader([]byte("one")))
+	objMetadata, err := dc.CreateObject("foo-replace", "obj", "", int64(len("one")), reader, nil, nil)
+	c.Assert(err, IsNil)
+
+	hasher := md5.New()
+	hasher.Write([]byte("something else"))
+	badMD5 := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
+	reader = ioutil.NopCloser(bytes.NewReader([]byte("two")))
+	_, err = dc.CreateObject("foo-replace", "obj", badMD5, int64(len("two")), reader, map[string]string{"ifMatch": objMetadata.MD5Sum}, nil)
+	c.Assert(err, Not(IsNil))
+
+	var buffer bytes.Buffer
+	_, err = dc.GetObject(&buffer, "foo-replace", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.Bytes(), DeepEquals, []byte("one"))
+
+	// rewriting identical content keeps the object readable
+	reader = ioutil.NopCloser(bytes.NewReader([]byte("one")))
+	_, err = dc.CreateObject("foo-replace", "obj", "", int64(len("one")), reader, map[string]string{"ifMatch": objMetadata.MD5Sum}, nil)
+	c.Assert(err, IsNil)
+	buffer.Reset()
+	_, err = dc.GetObject(&buffer, "foo-replace", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.Bytes(), DeepEquals, []byte("one"))
+}
+
+// completeMultipart - upload a single part and complete the upload with the given preconditions
+func completeMultipart(c *C, bucket, object, part string, preconditions map[string]string) (ObjectMetadata, error) {
+	uploadID, err := dc.NewMultipartUpload(bucket, object, nil, nil)
+	c.Assert(err, IsNil)
+	etag, err := dc.CreateObjectPart(bucket, object, uploadID, 1, "", "", int64(len(part)), bytes.NewReader([]byte(part)), nil)
+	c.Assert(err, IsNil)
+
+	var completeBuffer bytes.Buffer
+	complete := CompleteMultipartUpload{Part: []CompletePart{{PartNumber: 1, ETag: etag}}}
+	c.Assert(xml.NewEncoder(&completeBuffer).Encode(complete), IsNil)
+	objMetadata, err := dc.CompleteMultipartUpload(bucket, object, uploadID, &completeBuffer, preconditions, nil)
+	if err != nil {
+		c.Assert(dc.AbortMultipartUpload(bucket, object, uploadID, nil), IsNil)
+	}
+	return objMetadata, err
+}
+
+// test write preconditions of completing a multipart upload
+func (s *MyCacheSuite) TestMultipartConditionalComplete(c *C) {
+	c.Assert(dc.MakeBucket("foo-conditional", "private", nil), IsNil)
+
+	first, err := completeMultipart(c, "foo-conditional", "obj", "one", map[string]string{"ifNoneMatch": "*"})
+	c.Assert(err, IsNil)
+
+	_, err = completeMultipart(c, "foo-conditional", "obj", "two", map[string]string{"ifNoneMatch": "*"})
+	c.Assert(iodine.ToError(err), FitsTypeOf, PreconditionFailed{})
+
+	_, err = completeMultipart(c, "foo-conditional", "obj", "two", map[string]string{"ifMatch": "d41d8cd98f00b204e9800998ecf8427e"})
+	c.Assert(iodine.ToError(err), FitsTypeOf, PreconditionFailed{})
+
+	var buffer bytes.Buffer
+	_, err = dc.GetObject(&buffer, "foo-conditional", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.Bytes(), DeepEquals, []byte("one"))
+
+	second, err := completeMultipart(c, "foo-conditional", "obj", "two", map[string]string{"ifMatch": "\"" + first.MD5Sum + "\""})
+	c.Assert(err, IsNil)
+	c.Assert(second.MD5Sum, Not(Equals), first.MD5Sum)
+	buffer.Reset()
+	_, err = dc.GetObject(&buffer, "foo-conditional", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.Bytes(), DeepEquals, []byte("two"))
+}
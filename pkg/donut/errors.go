@@ -16,7 +16,10 @@
 
 package donut
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // InvalidArgument invalid argument
 type InvalidArgument struct{}
@@ -52,6 +55,15 @@ func (e ObjectExists) Error() string {
 	return "Object exists: " + e.Object
 }
 
+// PreconditionFailed object does not satisfy the conditions of a request
+type PreconditionFailed struct {
+	Object string
+}
+
+func (e PreconditionFailed) Error() string {
+	return "At least one of the preconditions specified did not hold: " + e.Object
+}
+
 // ObjectNotFound object does not exist
 type ObjectNotFound struct {
 	Object string
@@ -141,6 +153,33 @@ func (e InvalidErasureTechnique) Error() string {
 	return "Invalid erasure technique: " + e.Technique
 }
 
+// InvalidCachePolicy invalid cache eviction policy
+type InvalidCachePolicy struct {
+	Policy string
+}
+
+func (e InvalidCachePolicy) Error() string {
+	return "Invalid cache policy: " + e.Policy
+}
+
+// InvalidJournalEntry a write back journal entry which can not be read
+type InvalidJournalEntry struct {
+	Name string
+}
+
+func (e InvalidJournalEntry) Error() string {
+	return "Invalid write back journal entry: " + e.Name
+}
+
+// WriteBackFailed objects which could not be written back to the disks, they stay journaled
+type WriteBackFailed struct {
+	Objects []string
+}
+
+func (e WriteBackFailed) Error() string {
+	return "Failed to write back objects: " + strings.Join(e.Objects, ", ")
+}
+
 // InternalError - generic internal error
 type InternalError struct {
 }
@@ -357,3 +396,12 @@ type MalformedXML struct{}
 func (e MalformedXML) Error() string {
 	return "Malformed XML"
 }
+
+// NotReady donut can not serve requests
+type NotReady struct {
+	Reason string
+}
+
+func (e NotReady) Error() string {
+	return "Not ready: " + e.Reason
+}
@@ -0,0 +1,116 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio/pkg/donut/disk"
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// disks are probed by writing this file at their root, it is removed right away
+const healthCheckFile = ".minio.health"
+
+// disks and the cache not answering within this time are taken as gone and wedged
+const healthCheckTimeout = 5 * time.Second
+
+// writeQuorum - number of writable disks a node needs out of totalDisks, a majority of them
+func writeQuorum(totalDisks int) int {
+	return totalDisks/2 + 1
+}
+
+// Ready - check the donut can serve requests, its config loaded, every node has enough writable disks
+// to reach write quorum and the cache answers
+func (donut API) Ready() error {
+	if donut.configErr != nil {
+		return iodine.New(NotReady{Reason: "config failed to load: " + iodine.ToError(donut.configErr).Error()}, nil)
+	}
+	var nodeNames []string
+	for nodeName := range donut.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	for _, nodeName := range nodeNames {
+		disks, err := donut.nodes[nodeName].ListDisks()
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		if writable := probeDisks(disks); writable < writeQuorum(len(disks)) {
+			return iodine.New(NotReady{Reason: nodeName + " has too few writable disks"},
+				map[string]string{"writable": strconv.Itoa(writable), "disks": strconv.Itoa(len(disks))})
+		}
+	}
+	// a cache stuck behind its lock would hang every request
+	answered := make(chan struct{})
+	go func() {
+		donut.objects.Stats()
+		close(answered)
+	}()
+	select {
+	case <-answered:
+	case <-time.After(healthCheckTimeout):
+		return iodine.New(NotReady{Reason: "cache is not answering"}, nil)
+	}
+	return nil
+}
+
+// probeDisks - number of disks a file can be written to, all disks are probed at once
+func probeDisks(disks map[int]disk.Disk) int {
+	results := make(chan bool, len(disks))
+	for _, d := range disks {
+		go func(d disk.Disk) {
+			results <- probeDisk(d) == nil
+		}(d)
+	}
+	var writable int
+	timeout := time.After(healthCheckTimeout)
+	for i := 0; i < len(disks); i++ {
+		select {
+		case ok := <-results:
+			if ok {
+				writable++
+			}
+		case <-timeout:
+			// disks which did not answer yet are not writable
+			return writable
+		}
+	}
+	return writable
+}
+
+// probeDisk - write and remove a file on a disk
+func probeDisk(d disk.Disk) error {
+	file, err := d.CreateFile(healthCheckFile)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if _, err := file.Write([]byte("ok")); err != nil {
+		file.CloseAndPurge()
+		return iodine.New(err, nil)
+	}
+	if err := file.Sync(); err != nil {
+		file.CloseAndPurge()
+		return iodine.New(err, nil)
+	}
+	if err := file.Close(); err != nil {
+		return iodine.New(err, nil)
+	}
+	return d.RemoveAll(healthCheckFile)
+}
@@ -0,0 +1,645 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package index implements a sorted on-disk index of keys, made of an append-only log of changes
+// and sorted runs which are sought into through a sparse index. Once the log is full it is written
+// out as a new run, and newer runs are merged into older ones of comparable size so that every key
+// is rewritten a logarithmic number of times as the index grows
+//
+// Run layout, runs are named <name>.<sequence>.table with newer runs having higher sequences, a
+// <name>.table written by earlier versions is the oldest run
+//
+//  "key"\n                  sorted keys, quoted
+//  -"key"\n                 key removed, dropped once merged into the oldest run
+//  ...
+//  offset "key"\n           sparse index, every sparseInterval'th key and its offset
+//  ...
+//  %020d                    offset of the sparse index
+//
+// Log layout
+//
+//  +"key"\n                 key added
+//  -"key"\n                 key removed
+package index
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/atomic"
+)
+
+const (
+	// number of log records after which the log is written out as a run
+	compactThreshold = 1024
+	// a run is merged into the next older one unless that one is more than mergeFactor times larger
+	mergeFactor = 4
+	// every sparseInterval'th key of a run is recorded in the sparse index
+	sparseInterval = 128
+	// length of the run footer
+	footerLen = 20
+	// length of the sequence in run names
+	sequenceLen = 20
+)
+
+// Index sorted on-disk index of keys
+type Index struct {
+	dir   string
+	name  string
+	table string
+	log   string
+}
+
+// indexState state of an index shared by all its instances, which serializes access to it
+type indexState struct {
+	sync.Mutex
+	// records held by the log, negative until counted
+	records int
+}
+
+var states = struct {
+	sync.Mutex
+	m map[string]*indexState
+}{m: make(map[string]*indexState)}
+
+func (i Index) lock() *indexState {
+	states.Lock()
+	defer states.Unlock()
+	if _, ok := states.m[i.table]; !ok {
+		states.m[i.table] = &indexState{records: -1}
+	}
+	return states.m[i.table]
+}
+
+// New - instantiate an index named name inside directory dir
+func New(dir, name string) Index {
+	return Index{
+		dir:   dir,
+		name:  name,
+		table: filepath.Join(dir, name+".table"),
+		log:   filepath.Join(dir, name+".log"),
+	}
+}
+
+// Exists - verify if index has been written to
+func (i Index) Exists() bool {
+	if _, err := os.Stat(i.log); err == nil {
+		return true
+	}
+	runs, err := i.runs()
+	return err == nil && len(runs) > 0
+}
+
+// Add - add a key to index
+func (i Index) Add(key string) error {
+	state := i.lock()
+	state.Lock()
+	defer state.Unlock()
+	return i.append(state, '+', key)
+}
+
+// Remove - remove a key from index
+func (i Index) Remove(key string) error {
+	state := i.lock()
+	state.Lock()
+	defer state.Unlock()
+	return i.append(state, '-', key)
+}
+
+// Has - verify if a key is present in index
+func (i Index) Has(key string) (bool, error) {
+	var found bool
+	err := i.Walk(key, "", func(k string) bool {
+		found = k == key
+		return false
+	})
+	if err != nil {
+		return false, iodine.New(err, nil)
+	}
+	return found, nil
+}
+
+// Walk - call fn for every key with prefix, greater than marker, in sorted order until fn returns false
+func (i Index) Walk(prefix, marker string, fn func(key string) bool) error {
+	state := i.lock()
+	state.Lock()
+	defer state.Unlock()
+
+	changes, _, err := i.readLog()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	runs, err := i.runs()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	start := prefix
+	if marker > start {
+		start = marker
+	}
+	sources, closers, err := openSources(changes, runs, start, prefix, marker)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	defer closeAll(closers)
+	return mergeSources(sources, func(key string, present bool) (bool, error) {
+		if !present {
+			return true, nil
+		}
+		return fn(key), nil
+	})
+}
+
+// Compact - merge the log and all runs into a single run
+func (i Index) Compact() error {
+	state := i.lock()
+	state.Lock()
+	defer state.Unlock()
+
+	changes, _, err := i.readLog()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	runs, err := i.runs()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	state.records = -1
+	if len(runs) == 0 {
+		return i.flush()
+	}
+	if err := i.writeRun(runs[0].path, changes, runs, true); err != nil {
+		return iodine.New(err, nil)
+	}
+	// newer runs are removed oldest first, those left behind by a failure still override correctly
+	for _, r := range runs[1:] {
+		if err := os.Remove(r.path); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	if err := os.Remove(i.log); err != nil && !os.IsNotExist(err) {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// append - append a record to the log, writing it out as a run once it holds compactThreshold records.
+// Records are counted rather than keys, so that overwriting the same keys does not grow the log unbounded
+func (i Index) append(state *indexState, op byte, key string) error {
+	file, err := os.OpenFile(i.log, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	// a failed write may leave a partial record behind, the log is counted again
+	if _, err := file.WriteString(string(op) + strconv.Quote(key) + "\n"); err != nil {
+		state.records = -1
+		file.Close()
+		return iodine.New(err, nil)
+	}
+	if err := file.Close(); err != nil {
+		state.records = -1
+		return iodine.New(err, nil)
+	}
+	// the log is counted once, later records are counted as they are appended
+	if state.records < 0 {
+		if _, state.records, err = i.readLog(); err != nil {
+			state.records = -1
+			return iodine.New(err, nil)
+		}
+	} else {
+		state.records++
+	}
+	if state.records < compactThreshold {
+		return nil
+	}
+	state.records = -1
+	return i.flush()
+}
+
+// readLog - read the log into a map of keys and their presence, later records win. Also returns the
+// number of records read
+func (i Index) readLog() (map[string]bool, int, error) {
+	changes := make(map[string]bool)
+	file, err := os.Open(i.log)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return changes, 0, nil
+		}
+		return nil, 0, iodine.New(err, nil)
+	}
+	defer file.Close()
+	var records int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < 3 {
+			return nil, 0, iodine.New(CorruptedIndex{Path: i.log}, nil)
+		}
+		key, err := strconv.Unquote(line[1:])
+		if err != nil {
+			return nil, 0, iodine.New(CorruptedIndex{Path: i.log}, nil)
+		}
+		changes[key] = line[0] == '+'
+		records++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, iodine.New(err, nil)
+	}
+	return changes, records, nil
+}
+
+// run - a sorted run of keys on disk, newer runs override older ones
+type run struct {
+	path     string
+	sequence int64
+	size     int64
+}
+
+// runs - runs of the index, oldest first
+func (i Index) runs() ([]run, error) {
+	dir, err := os.Open(i.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, iodine.New(err, nil)
+	}
+	// the directory is shared with other indexes and bucket data, only names are read
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var runs []run
+	for _, name := range names {
+		var sequence int64
+		switch {
+		case name == i.name+".table":
+			// written by earlier versions, older than any numbered run
+			sequence = -1
+		case strings.HasPrefix(name, i.name+".") && strings.HasSuffix(name, ".table"):
+			number := strings.TrimSuffix(strings.TrimPrefix(name, i.name+"."), ".table")
+			if len(number) != sequenceLen {
+				continue
+			}
+			if sequence, err = strconv.ParseInt(number, 10, 64); err != nil || sequence < 0 {
+				continue
+			}
+		default:
+			continue
+		}
+		stat, err := os.Stat(filepath.Join(i.dir, name))
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		runs = append(runs, run{path: filepath.Join(i.dir, name), sequence: sequence, size: stat.Size()})
+	}
+	sort.Sort(bySequence(runs))
+	return runs, nil
+}
+
+// runPath - path of the run of a sequence
+func (i Index) runPath(sequence int64) string {
+	return filepath.Join(i.dir, fmt.Sprintf("%s.%0*d.table", i.name, sequenceLen, sequence))
+}
+
+// flush - write the log out as the newest run, remove the log and merge runs of comparable size
+func (i Index) flush() error {
+	changes, _, err := i.readLog()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	runs, err := i.runs()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if len(changes) > 0 {
+		var sequence int64
+		if len(runs) > 0 {
+			sequence = runs[len(runs)-1].sequence + 1
+		}
+		// removals only matter while an older run may still hold the key
+		if err := i.writeRun(i.runPath(sequence), changes, nil, len(runs) == 0); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	// replaying the log over the new run is harmless, should we fail to remove it
+	if err := os.Remove(i.log); err != nil && !os.IsNotExist(err) {
+		return iodine.New(err, nil)
+	}
+	return i.mergeRuns()
+}
+
+// mergeRuns - merge the newest run into the next older one for as long as that one is at most
+// mergeFactor times larger. Run sizes thus grow geometrically from the newest to the oldest
+func (i Index) mergeRuns() error {
+	for {
+		runs, err := i.runs()
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		if len(runs) < 2 {
+			return nil
+		}
+		older, newer := runs[len(runs)-2], runs[len(runs)-1]
+		if older.size > mergeFactor*newer.size {
+			return nil
+		}
+		if err := i.writeRun(older.path, nil, runs[len(runs)-2:], len(runs) == 2); err != nil {
+			return iodine.New(err, nil)
+		}
+		// the merged run holds everything the newer one does, it may stay behind on failure
+		if err := os.Remove(newer.path); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+}
+
+// writeRun - write the merge of the log changes and runs to path atomically, removals are dropped
+// if the new run is going to be the oldest one
+func (i Index) writeRun(path string, changes map[string]bool, runs []run, dropRemovals bool) error {
+	sources, closers, err := openSources(changes, runs, "", "", "")
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	defer closeAll(closers)
+
+	file, err := atomic.FileCreate(path)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	writer := &countingWriter{writer: bufio.NewWriter(file)}
+	var sparse []string
+	var count int
+	err = mergeSources(sources, func(key string, present bool) (bool, error) {
+		if !present && dropRemovals {
+			return true, nil
+		}
+		if count%sparseInterval == 0 {
+			sparse = append(sparse, strconv.FormatInt(writer.count, 10)+" "+strconv.Quote(key))
+		}
+		count++
+		line := strconv.Quote(key) + "\n"
+		if !present {
+			line = "-" + line
+		}
+		_, err := writer.Write([]byte(line))
+		return err == nil, err
+	})
+	if err != nil {
+		file.CloseAndPurge()
+		return iodine.New(err, nil)
+	}
+	sparseOffset := writer.count
+	for _, entry := range sparse {
+		if _, err := writer.Write([]byte(entry + "\n")); err != nil {
+			file.CloseAndPurge()
+			return iodine.New(err, nil)
+		}
+	}
+	if _, err := writer.Write([]byte(fmt.Sprintf("%020d", sparseOffset))); err != nil {
+		file.CloseAndPurge()
+		return iodine.New(err, nil)
+	}
+	if err := writer.writer.Flush(); err != nil {
+		file.CloseAndPurge()
+		return iodine.New(err, nil)
+	}
+	if err := file.Close(); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// seekRun - scanner over the keys of a run, positioned at or before the first key not less than start
+func seekRun(path string, start string) (*bufio.Scanner, io.Closer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return bufio.NewScanner(strings.NewReader("")), nopCloser{}, nil
+		}
+		return nil, nil, iodine.New(err, nil)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, iodine.New(err, nil)
+	}
+	if stat.Size() < footerLen {
+		file.Close()
+		return nil, nil, iodine.New(CorruptedIndex{Path: path}, nil)
+	}
+	footer := make([]byte, footerLen)
+	if _, err := file.ReadAt(footer, stat.Size()-footerLen); err != nil {
+		file.Close()
+		return nil, nil, iodine.New(err, nil)
+	}
+	sparseOffset, err := strconv.ParseInt(string(footer), 10, 64)
+	if err != nil || sparseOffset > stat.Size()-footerLen {
+		file.Close()
+		return nil, nil, iodine.New(CorruptedIndex{Path: path}, nil)
+	}
+	// find the last sparse entry not greater than start
+	var offset int64
+	sparse := bufio.NewScanner(io.NewSectionReader(file, sparseOffset, stat.Size()-footerLen-sparseOffset))
+	for sparse.Scan() {
+		entry := strings.SplitN(sparse.Text(), " ", 2)
+		if len(entry) != 2 {
+			file.Close()
+			return nil, nil, iodine.New(CorruptedIndex{Path: path}, nil)
+		}
+		key, err := strconv.Unquote(entry[1])
+		if err != nil {
+			file.Close()
+			return nil, nil, iodine.New(CorruptedIndex{Path: path}, nil)
+		}
+		if key > start {
+			break
+		}
+		if offset, err = strconv.ParseInt(entry[0], 10, 64); err != nil {
+			file.Close()
+			return nil, nil, iodine.New(CorruptedIndex{Path: path}, nil)
+		}
+	}
+	if err := sparse.Err(); err != nil {
+		file.Close()
+		return nil, nil, iodine.New(err, nil)
+	}
+	return bufio.NewScanner(io.NewSectionReader(file, offset, sparseOffset-offset)), file, nil
+}
+
+// source - keys of the log or of a run in sorted order, along with whether they are present
+type source interface {
+	next() (key string, present bool, ok bool, err error)
+}
+
+// openSources - sources of the log changes and of the runs, newest first, holding keys with prefix
+// greater than marker. Runs are sought to start. The returned closers must be closed
+func openSources(changes map[string]bool, runs []run, start, prefix, marker string) ([]source, []io.Closer, error) {
+	sources := []source{newLogSource(changes, prefix, marker)}
+	var closers []io.Closer
+	for j := len(runs) - 1; j >= 0; j-- {
+		scanner, closer, err := seekRun(runs[j].path, start)
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, iodine.New(err, nil)
+		}
+		closers = append(closers, closer)
+		sources = append(sources, &runSource{scanner: scanner, path: runs[j].path, prefix: prefix, marker: marker})
+	}
+	return sources, closers, nil
+}
+
+func closeAll(closers []io.Closer) {
+	for _, closer := range closers {
+		closer.Close()
+	}
+}
+
+// mergeSources - merge sources given newest first, calling fn for every key in sorted order with its
+// presence in the newest source holding it, until fn returns false
+func mergeSources(sources []source, fn func(key string, present bool) (bool, error)) error {
+	type head struct {
+		key     string
+		present bool
+		ok      bool
+	}
+	heads := make([]head, len(sources))
+	for j, source := range sources {
+		key, present, ok, err := source.next()
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		heads[j] = head{key, present, ok}
+	}
+	for {
+		// on equal keys the newest source wins
+		newest := -1
+		for j, h := range heads {
+			if h.ok && (newest < 0 || h.key < heads[newest].key) {
+				newest = j
+			}
+		}
+		if newest < 0 {
+			return nil
+		}
+		key, present := heads[newest].key, heads[newest].present
+		for j := range heads {
+			if !heads[j].ok || heads[j].key != key {
+				continue
+			}
+			k, p, ok, err := sources[j].next()
+			if err != nil {
+				return iodine.New(err, nil)
+			}
+			heads[j] = head{k, p, ok}
+		}
+		proceed, err := fn(key, present)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		if !proceed {
+			return nil
+		}
+	}
+}
+
+// logSource - log changes with prefix greater than marker in sorted order
+type logSource struct {
+	keys    []string
+	changes map[string]bool
+}
+
+func newLogSource(changes map[string]bool, prefix, marker string) *logSource {
+	var keys []string
+	for key := range changes {
+		if key > marker && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return &logSource{keys: keys, changes: changes}
+}
+
+func (l *logSource) next() (string, bool, bool, error) {
+	if len(l.keys) == 0 {
+		return "", false, false, nil
+	}
+	key := l.keys[0]
+	l.keys = l.keys[1:]
+	return key, l.changes[key], true, nil
+}
+
+// runSource - keys of a run with prefix greater than marker, the run must be positioned at or before them
+type runSource struct {
+	scanner *bufio.Scanner
+	path    string
+	prefix  string
+	marker  string
+}
+
+func (r *runSource) next() (string, bool, bool, error) {
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		present := !strings.HasPrefix(line, "-")
+		key, err := strconv.Unquote(strings.TrimPrefix(line, "-"))
+		if err != nil {
+			return "", false, false, iodine.New(CorruptedIndex{Path: r.path}, nil)
+		}
+		if key <= r.marker || key < r.prefix {
+			continue
+		}
+		if !strings.HasPrefix(key, r.prefix) {
+			return "", false, false, nil
+		}
+		return key, present, true, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return "", false, false, iodine.New(err, nil)
+	}
+	return "", false, false, nil
+}
+
+// bySequence is a sortable interface for runs, oldest first
+type bySequence []run
+
+func (b bySequence) Len() int           { return len(b) }
+func (b bySequence) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b bySequence) Less(i, j int) bool { return b[i].sequence < b[j].sequence }
+
+// countingWriter - writer keeping track of the offset written to
+type countingWriter struct {
+	writer *bufio.Writer
+	count  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += int64(n)
+	return n, err
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,178 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package index
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/minio/check"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MySuite struct {
+	root string
+}
+
+var _ = Suite(&MySuite{})
+
+func (s *MySuite) SetUpSuite(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "index-")
+	c.Assert(err, IsNil)
+	s.root = root
+}
+
+func (s *MySuite) TearDownSuite(c *C) {
+	os.RemoveAll(s.root)
+}
+
+func walk(c *C, index Index, prefix, marker string, max int) []string {
+	var keys []string
+	err := index.Walk(prefix, marker, func(key string) bool {
+		keys = append(keys, key)
+		return len(keys) < max
+	})
+	c.Assert(err, IsNil)
+	return keys
+}
+
+func (s *MySuite) TestIndex(c *C) {
+	index := New(s.root, "simple")
+	c.Assert(index.Exists(), Equals, false)
+	c.Assert(walk(c, index, "", "", 10), IsNil)
+
+	for _, key := range []string{"b", "a/2", "a/1", "c\nnewline", "d"} {
+		c.Assert(index.Add(key), IsNil)
+	}
+	c.Assert(index.Remove("d"), IsNil)
+	c.Assert(index.Exists(), Equals, true)
+
+	c.Assert(walk(c, index, "", "", 10), DeepEquals, []string{"a/1", "a/2", "b", "c\nnewline"})
+	c.Assert(walk(c, index, "a/", "", 10), DeepEquals, []string{"a/1", "a/2"})
+	c.Assert(walk(c, index, "", "a/2", 10), DeepEquals, []string{"b", "c\nnewline"})
+	c.Assert(walk(c, index, "", "", 2), DeepEquals, []string{"a/1", "a/2"})
+
+	found, err := index.Has("b")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	found, err = index.Has("d")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+
+	// compacted table gives the same results, and is overridden by later changes
+	c.Assert(index.Compact(), IsNil)
+	c.Assert(walk(c, index, "", "", 10), DeepEquals, []string{"a/1", "a/2", "b", "c\nnewline"})
+	c.Assert(index.Remove("a/1"), IsNil)
+	c.Assert(index.Add("a/3"), IsNil)
+	c.Assert(walk(c, index, "a/", "", 10), DeepEquals, []string{"a/2", "a/3"})
+}
+
+func (s *MySuite) TestIndexCompaction(c *C) {
+	index := New(s.root, "compaction")
+	total := 3*compactThreshold + 10
+	for i := 0; i < total; i++ {
+		c.Assert(index.Add(fmt.Sprintf("key-%06d", i)), IsNil)
+	}
+	for i := 0; i < total; i += 2 {
+		c.Assert(index.Remove(fmt.Sprintf("key-%06d", i)), IsNil)
+	}
+
+	keys := walk(c, index, "", "", total)
+	c.Assert(len(keys), Equals, total/2)
+	c.Assert(keys[0], Equals, "key-000001")
+
+	// seeks through the sparse index land on the right key
+	c.Assert(walk(c, index, "", "key-002000", 2), DeepEquals, []string{"key-002001", "key-002003"})
+	c.Assert(walk(c, index, "key-0025", "", 3), DeepEquals, []string{"key-002501", "key-002503", "key-002505"})
+	found, err := index.Has("key-001001")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	found, err = index.Has("key-001000")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+}
+
+func (s *MySuite) TestIndexCompactionOnOverwrites(c *C) {
+	index := New(s.root, "overwrites")
+	for i := 0; i < 3*compactThreshold+1; i++ {
+		c.Assert(index.Add("same"), IsNil)
+	}
+	// the log is compacted by its number of records, not by the number of keys it holds
+	stat, err := os.Stat(index.log)
+	c.Assert(err, IsNil)
+	c.Assert(stat.Size() < int64(compactThreshold*len("+\"same\"\n")), Equals, true)
+	c.Assert(walk(c, index, "", "", 10), DeepEquals, []string{"same"})
+}
+
+func (s *MySuite) TestIndexRunsAreTiered(c *C) {
+	index := New(s.root, "tiered")
+	total := 24 * compactThreshold
+	for i := 0; i < total; i++ {
+		c.Assert(index.Add(fmt.Sprintf("key-%06d", i)), IsNil)
+	}
+	// removals written to newer runs hide keys held by older ones
+	for i := 0; i < total; i += 3 {
+		c.Assert(index.Remove(fmt.Sprintf("key-%06d", i)), IsNil)
+	}
+
+	// run sizes grow geometrically, the index holds a logarithmic number of them
+	runs, err := index.runs()
+	c.Assert(err, IsNil)
+	c.Assert(len(runs) > 1, Equals, true)
+	c.Assert(len(runs) <= 6, Equals, true)
+	for j := 1; j < len(runs); j++ {
+		c.Assert(runs[j-1].size > mergeFactor*runs[j].size, Equals, true)
+	}
+
+	keys := walk(c, index, "", "", total)
+	c.Assert(len(keys), Equals, total-(total+2)/3)
+	c.Assert(keys[0], Equals, "key-000001")
+	c.Assert(walk(c, index, "key-0200", "key-020000", 2), DeepEquals, []string{"key-020002", "key-020003"})
+	found, err := index.Has("key-020001")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+
+	// a full compaction leaves a single run without removals
+	c.Assert(index.Compact(), IsNil)
+	runs, err = index.runs()
+	c.Assert(err, IsNil)
+	c.Assert(len(runs), Equals, 1)
+	c.Assert(walk(c, index, "", "", total), DeepEquals, keys)
+}
+
+func (s *MySuite) TestIndexReadsEarlierTable(c *C) {
+	index := New(s.root, "earlier")
+	c.Assert(index.Add("a"), IsNil)
+	c.Assert(index.Add("b"), IsNil)
+	c.Assert(index.Compact(), IsNil)
+	// earlier versions kept a single table named after the index
+	runs, err := index.runs()
+	c.Assert(err, IsNil)
+	c.Assert(len(runs), Equals, 1)
+	c.Assert(os.Rename(runs[0].path, index.table), IsNil)
+
+	c.Assert(index.Remove("a"), IsNil)
+	c.Assert(index.Add("c"), IsNil)
+	c.Assert(walk(c, index, "", "", 10), DeepEquals, []string{"b", "c"})
+	c.Assert(index.Compact(), IsNil)
+	c.Assert(walk(c, index, "", "", 10), DeepEquals, []string{"b", "c"})
+	runs, err = index.runs()
+	c.Assert(err, IsNil)
+	c.Assert(runs, DeepEquals, []run{{path: index.table, sequence: -1, size: runs[0].size}})
+}
@@ -41,6 +41,8 @@ type ObjectStorage interface {
 	GetObject(w io.Writer, bucket, object string) (int64, error)
 	GetPartialObject(w io.Writer, bucket, object string, start, length int64) (int64, error)
 	GetObjectMetadata(bucket, object string, signature *Signature) (ObjectMetadata, error)
+	// OpenObject holds the current version open, its metadata and data are read consistently
+	OpenObject(bucket, object string, signature *Signature) (*ObjectVersion, error)
 	// bucket, object, expectedMD5Sum, size, reader, metadata, signature
 	CreateObject(string, string, string, int64, io.Reader, map[string]string, *Signature) (ObjectMetadata, error)
 
@@ -49,10 +51,10 @@ type ObjectStorage interface {
 
 // Multipart API
 type Multipart interface {
-	NewMultipartUpload(bucket, key, contentType string, signature *Signature) (string, error)
+	NewMultipartUpload(bucket, key string, metadata map[string]string, signature *Signature) (string, error)
 	AbortMultipartUpload(bucket, key, uploadID string, signature *Signature) error
 	CreateObjectPart(string, string, string, int, string, string, int64, io.Reader, *Signature) (string, error)
-	CompleteMultipartUpload(bucket, key, uploadID string, data io.Reader, signature *Signature) (ObjectMetadata, error)
+	CompleteMultipartUpload(bucket, key, uploadID string, data io.Reader, metadata map[string]string, signature *Signature) (ObjectMetadata, error)
 	ListMultipartUploads(string, BucketMultipartResourcesMetadata, *Signature) (BucketMultipartResourcesMetadata, error)
 	ListObjectParts(string, string, ObjectResourcesMetadata, *Signature) (ObjectResourcesMetadata, error)
 }
@@ -62,10 +64,16 @@ type Management interface {
 	Heal() error
 	Rebalance() error
 	Info() (map[string][]string, error)
+	Stats() (Stats, error)
+	// Ready fails unless the config loaded, every node reaches write quorum and the cache answers
+	Ready() error
 
 	AttachNode(hostname string, disks []string) error
 	DetachNode(hostname string) error
 
 	SaveConfig() error
 	LoadConfig() error
+
+	// Flush writes everything acknowledged but held in memory to the disks, before shutting down
+	Flush() error
 }
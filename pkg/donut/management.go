@@ -19,6 +19,8 @@ package donut
 import (
 	"encoding/json"
 	"path/filepath"
+	"sort"
+	"strconv"
 
 	"github.com/minio/minio/pkg/donut/disk"
 	"github.com/minio/minio/pkg/iodine"
@@ -46,6 +48,50 @@ func (donut API) Info() (nodeDiskMap map[string][]string, err error) {
 	return nodeDiskMap, nil
 }
 
+// Stats - return cache, disk and multipart usage statistics
+func (donut API) Stats() (Stats, error) {
+	stats := Stats{Cache: donut.objects.Stats()}
+	if donut.diskCache != nil {
+		diskCacheStats := donut.diskCache.Stats()
+		stats.DiskCache = &diskCacheStats
+	}
+	var nodeNames []string
+	for nodeName := range donut.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	for _, nodeName := range nodeNames {
+		disks, err := donut.nodes[nodeName].ListDisks()
+		if err != nil {
+			return Stats{}, iodine.New(err, nil)
+		}
+		for order := 0; order < len(disks); order++ {
+			disk, ok := disks[order]
+			if !ok {
+				continue
+			}
+			diskStats := DiskStats{Node: nodeName, Path: disk.GetPath()}
+			// statfs failures leave the usage unknown
+			if fsInfo := disk.GetFSInfo(); fsInfo != nil {
+				diskStats.Total, _ = strconv.ParseInt(fsInfo["TotalB"], 10, 64)
+				diskStats.Free, _ = strconv.ParseInt(fsInfo["FreeB"], 10, 64)
+			}
+			stats.Disks = append(stats.Disks, diskStats)
+		}
+	}
+	donut.lock.Lock()
+	for _, bucket := range donut.storedBuckets.GetAll() {
+		stats.MultipartSessions += len(bucket.(storedBucket).multiPartSession)
+	}
+	donut.lock.Unlock()
+	if donut.writeBack != nil {
+		donut.writeBack.lock.Lock()
+		stats.WriteBackPending = len(donut.writeBack.pending)
+		donut.writeBack.lock.Unlock()
+	}
+	return stats, nil
+}
+
 // AttachNode - attach node
 func (donut API) AttachNode(hostname string, disks []string) error {
 	if hostname == "" || len(disks) == 0 {
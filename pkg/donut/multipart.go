@@ -39,8 +39,8 @@ import (
 
 /// V2 API functions
 
-// NewMultipartUpload - initiate a new multipart session
-func (donut API) NewMultipartUpload(bucket, key, contentType string, signature *Signature) (string, error) {
+// NewMultipartUpload - initiate a new multipart session, metadata is persisted with the object on completion
+func (donut API) NewMultipartUpload(bucket, key string, metadata map[string]string, signature *Signature) (string, error) {
 	donut.lock.Lock()
 	defer donut.lock.Unlock()
 
@@ -64,10 +64,7 @@ func (donut API) NewMultipartUpload(bucket, key, contentType string, signature *
 		return "", iodine.New(BucketNotFound{Bucket: bucket}, nil)
 	}
 	storedBucket := donut.storedBuckets.Get(bucket).(storedBucket)
-	objectKey := bucket + "/" + key
-	if _, ok := storedBucket.objectMetadata[objectKey]; ok == true {
-		return "", iodine.New(ObjectExists{Object: key}, nil)
-	}
+	// an existing object is verified during CompleteMultipartUpload() against its write preconditions
 	id := []byte(strconv.FormatInt(rand.Int63(), 10) + bucket + key + time.Now().String())
 	uploadIDSum := sha512.Sum512(id)
 	uploadID := base64.URLEncoding.EncodeToString(uploadIDSum[:])[:47]
@@ -76,9 +73,10 @@ func (donut API) NewMultipartUpload(bucket, key, contentType string, signature *
 		uploadID:   uploadID,
 		initiated:  time.Now(),
 		totalParts: 0,
+		metadata:   metadata,
 	}
 	storedBucket.partMetadata[key] = make(map[int]PartMetadata)
-	multiPartCache := data.NewCache(0)
+	multiPartCache := data.NewCache(0, 0, nil)
 	multiPartCache.OnEvicted = donut.evictedPart
 	donut.multiPartObjects[uploadID] = multiPartCache
 	donut.storedBuckets.Set(bucket, storedBucket)
@@ -87,6 +85,17 @@ func (donut API) NewMultipartUpload(bucket, key, contentType string, signature *
 
 // AbortMultipartUpload - abort an incomplete multipart session
 func (donut API) AbortMultipartUpload(bucket, key, uploadID string, signature *Signature) error {
+	if err := donut.abortMultipartUpload(bucket, key, uploadID, signature); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := donut.deleteObjectParts(bucket, uploadID); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// abortMultipartUpload - drop the multipart session from memory
+func (donut API) abortMultipartUpload(bucket, key, uploadID string, signature *Signature) error {
 	donut.lock.Lock()
 	defer donut.lock.Unlock()
 
@@ -114,18 +123,26 @@ func (donut API) AbortMultipartUpload(bucket, key, uploadID string, signature *S
 	return nil
 }
 
+// deleteObjectParts - remove the parts of a multipart upload from the disks, called without holding the donut lock
+func (donut API) deleteObjectParts(bucket, uploadID string) error {
+	if len(donut.config.NodeDiskMap) == 0 {
+		return nil
+	}
+	b, err := donut.getDonutBucket(bucket)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	return b.DeleteObjectParts(uploadID)
+}
+
 // CreateObjectPart - create a part in a multipart session
 func (donut API) CreateObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum string, size int64, data io.Reader, signature *Signature) (string, error) {
-	donut.lock.Lock()
 	etag, err := donut.createObjectPart(bucket, key, uploadID, partID, "", expectedMD5Sum, size, data, signature)
-	donut.lock.Unlock()
-	// possible free
-	debug.FreeOSMemory()
-
 	return etag, iodine.New(err, nil)
 }
 
-// createObject - internal wrapper function called by CreateObjectPart
+// createObjectPart - internal wrapper function called by CreateObjectPart, parts are read without holding
+// the donut lock into a buffer of their own and only committed to the session once complete
 func (donut API) createObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum string, size int64, data io.Reader, signature *Signature) (string, error) {
 	if !IsValidBucket(bucket) {
 		return "", iodine.New(BucketNameInvalid{Bucket: bucket}, nil)
@@ -136,17 +153,19 @@ func (donut API) createObjectPart(bucket, key, uploadID string, partID int, cont
 	if !donut.storedBuckets.Exists(bucket) {
 		return "", iodine.New(BucketNotFound{Bucket: bucket}, nil)
 	}
+	donut.lock.Lock()
 	strBucket := donut.storedBuckets.Get(bucket).(storedBucket)
 	// Verify upload id
 	if strBucket.multiPartSession[key].uploadID != uploadID {
+		donut.lock.Unlock()
 		return "", iodine.New(InvalidUploadID{UploadID: uploadID}, nil)
 	}
-
 	// get object key
-	parts := strBucket.partMetadata[key]
-	if _, ok := parts[partID]; ok {
-		return parts[partID].ETag, nil
+	if part, ok := strBucket.partMetadata[key][partID]; ok {
+		donut.lock.Unlock()
+		return part.ETag, nil
 	}
+	donut.lock.Unlock()
 
 	if contentType == "" {
 		contentType = "application/octet-stream"
@@ -161,32 +180,22 @@ func (donut API) createObjectPart(bucket, key, uploadID string, partID int, cont
 		expectedMD5Sum = hex.EncodeToString(expectedMD5SumBytes)
 	}
 
+	if len(donut.config.NodeDiskMap) > 0 {
+		return donut.writeObjectPart(bucket, key, uploadID, partID, expectedMD5Sum, size, data, signature)
+	}
+
 	// calculate md5
 	hash := md5.New()
 	sha256hash := sha256.New()
 
-	var err error
-	var totalLength int64
-	for err == nil {
-		var length int
-		byteBuffer := make([]byte, 1024*1024)
-		length, err = data.Read(byteBuffer)
-		hash.Write(byteBuffer[0:length])
-		sha256hash.Write(byteBuffer[0:length])
-		ok := donut.multiPartObjects[uploadID].Append(partID, byteBuffer[0:length])
-		if !ok {
-			return "", iodine.New(InternalError{}, nil)
-		}
-		totalLength += int64(length)
-		go debug.FreeOSMemory()
+	var partData bytes.Buffer
+	totalLength, err := io.Copy(io.MultiWriter(&partData, hash, sha256hash), data)
+	if err != nil {
+		return "", iodine.New(err, nil)
 	}
 	if totalLength != size {
-		donut.multiPartObjects[uploadID].Delete(partID)
 		return "", iodine.New(IncompleteBody{Bucket: bucket, Object: key}, nil)
 	}
-	if err != io.EOF {
-		return "", iodine.New(err, nil)
-	}
 
 	md5SumBytes := hash.Sum(nil)
 	md5Sum := hex.EncodeToString(md5SumBytes)
@@ -213,14 +222,72 @@ func (donut API) createObjectPart(bucket, key, uploadID string, partID int, cont
 		ETag:         md5Sum,
 		Size:         totalLength,
 	}
+	return donut.commitObjectPart(bucket, key, uploadID, newPart, partData.Bytes())
+}
+
+// writeObjectPart - stream a part onto the disks through the erasure block writer, only a block of it is
+// held in memory at a time. Concurrent uploads of the same part are serialized, the later one finds it committed
+func (donut API) writeObjectPart(bucket, key, uploadID string, partID int, expectedMD5Sum string, size int64, data io.Reader, signature *Signature) (string, error) {
+	partName := getPartName(uploadID, partID)
+	donut.nsMutex.Lock(bucket, partName)
+	defer donut.nsMutex.Unlock(bucket, partName)
+
+	donut.lock.Lock()
+	part, ok := donut.storedBuckets.Get(bucket).(storedBucket).partMetadata[key][partID]
+	donut.lock.Unlock()
+	if ok {
+		return part.ETag, nil
+	}
+
+	b, err := donut.getDonutBucket(bucket)
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
+	partMetadata, err := b.WriteObjectPart(key, uploadID, partID, data, size, expectedMD5Sum, signature)
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
+	newPart := PartMetadata{
+		PartNumber:   partID,
+		LastModified: partMetadata.Created,
+		ETag:         partMetadata.MD5Sum,
+		Size:         partMetadata.Size,
+	}
+	etag, err := donut.commitObjectPart(bucket, key, uploadID, newPart, nil)
+	if err != nil {
+		// the session was aborted while the part was written, do not leave it behind
+		b.DeleteObjectParts(uploadID)
+		return "", iodine.New(err, nil)
+	}
+	return etag, nil
+}
 
-	parts[partID] = newPart
+// commitObjectPart - add a part to its multipart session, without disks the part data is cached along
+func (donut API) commitObjectPart(bucket, key, uploadID string, newPart PartMetadata, partData []byte) (string, error) {
+	donut.lock.Lock()
+	defer donut.lock.Unlock()
+	// the session may have been aborted or completed meanwhile
+	strBucket := donut.storedBuckets.Get(bucket).(storedBucket)
+	if strBucket.multiPartSession[key].uploadID != uploadID {
+		return "", iodine.New(InvalidUploadID{UploadID: uploadID}, nil)
+	}
+	parts := strBucket.partMetadata[key]
+	// a concurrent upload of the same part won the race
+	if part, ok := parts[newPart.PartNumber]; ok {
+		return part.ETag, nil
+	}
+	if len(donut.config.NodeDiskMap) == 0 {
+		if ok := donut.multiPartObjects[uploadID].Set(newPart.PartNumber, partData); !ok {
+			return "", iodine.New(InternalError{}, nil)
+		}
+	}
+	parts[newPart.PartNumber] = newPart
 	strBucket.partMetadata[key] = parts
 	multiPartSession := strBucket.multiPartSession[key]
 	multiPartSession.totalParts++
 	strBucket.multiPartSession[key] = multiPartSession
 	donut.storedBuckets.Set(bucket, strBucket)
-	return md5Sum, nil
+	return newPart.ETag, nil
 }
 
 // cleanupMultipartSession invoked during an abort or complete multipart session to cleanup session from memory
@@ -229,105 +296,200 @@ func (donut API) cleanupMultipartSession(bucket, key, uploadID string) {
 	for i := 1; i <= storedBucket.multiPartSession[key].totalParts; i++ {
 		donut.multiPartObjects[uploadID].Delete(i)
 	}
+	delete(donut.multiPartObjects, uploadID)
 	delete(storedBucket.multiPartSession, key)
 	delete(storedBucket.partMetadata, key)
 	donut.storedBuckets.Set(bucket, storedBucket)
 }
 
-// CompleteMultipartUpload - complete a multipart upload and persist the data
-func (donut API) CompleteMultipartUpload(bucket, key, uploadID string, data io.Reader, signature *Signature) (ObjectMetadata, error) {
-	donut.lock.Lock()
-
+// CompleteMultipartUpload - complete a multipart upload and persist the data, metadata carries
+// write preconditions 'ifMatch' and 'ifNoneMatch' verified atomically with the final write
+func (donut API) CompleteMultipartUpload(bucket, key, uploadID string, data io.Reader, metadata map[string]string, signature *Signature) (ObjectMetadata, error) {
 	if !IsValidBucket(bucket) {
-		donut.lock.Unlock()
 		return ObjectMetadata{}, iodine.New(BucketNameInvalid{Bucket: bucket}, nil)
 	}
 	if !IsValidObjectName(key) {
-		donut.lock.Unlock()
 		return ObjectMetadata{}, iodine.New(ObjectNameInvalid{Object: key}, nil)
 	}
 	if !donut.storedBuckets.Exists(bucket) {
-		donut.lock.Unlock()
 		return ObjectMetadata{}, iodine.New(BucketNotFound{Bucket: bucket}, nil)
 	}
-	storedBucket := donut.storedBuckets.Get(bucket).(storedBucket)
+	donut.lock.Lock()
+	strBucket := donut.storedBuckets.Get(bucket).(storedBucket)
 	// Verify upload id
-	if storedBucket.multiPartSession[key].uploadID != uploadID {
+	if strBucket.multiPartSession[key].uploadID != uploadID {
 		donut.lock.Unlock()
 		return ObjectMetadata{}, iodine.New(InvalidUploadID{UploadID: uploadID}, nil)
 	}
+	multiPartCache := donut.multiPartObjects[uploadID]
+	partMetadata := make(map[int]PartMetadata)
+	for partID, part := range strBucket.partMetadata[key] {
+		partMetadata[partID] = part
+	}
+	// metadata provided during initiate along with write preconditions of this request
+	objectMetadata := make(map[string]string)
+	for k, v := range strBucket.multiPartSession[key].metadata {
+		objectMetadata[k] = v
+	}
+	for k, v := range metadata {
+		objectMetadata[k] = v
+	}
+	donut.lock.Unlock()
+
 	partBytes, err := ioutil.ReadAll(data)
 	if err != nil {
-		donut.lock.Unlock()
 		return ObjectMetadata{}, iodine.New(err, nil)
 	}
 	if signature != nil {
 		ok, err := signature.DoesSignatureMatch(hex.EncodeToString(sha256.Sum256(partBytes)[:]))
 		if err != nil {
-			donut.lock.Unlock()
 			return ObjectMetadata{}, iodine.New(err, nil)
 		}
 		if !ok {
-			donut.lock.Unlock()
 			return ObjectMetadata{}, iodine.New(SignatureDoesNotMatch{}, nil)
 		}
 	}
 	parts := &CompleteMultipartUpload{}
 	if err := xml.Unmarshal(partBytes, parts); err != nil {
-		donut.lock.Unlock()
 		return ObjectMetadata{}, iodine.New(MalformedXML{}, nil)
 	}
 	if !sort.IsSorted(completedParts(parts.Part)) {
-		donut.lock.Unlock()
 		return ObjectMetadata{}, iodine.New(InvalidPartOrder{}, nil)
 	}
 
+	var newObjectMetadata ObjectMetadata
+	if len(donut.config.NodeDiskMap) > 0 {
+		newObjectMetadata, err = donut.completeObjectParts(bucket, key, uploadID, parts.Part, partMetadata, objectMetadata)
+	} else {
+		newObjectMetadata, err = donut.completeCachedObjectParts(bucket, key, multiPartCache, parts.Part, objectMetadata)
+	}
+	if err != nil {
+		// No need to call internal cleanup functions here, caller will call AbortMultipartUpload()
+		// which would in-turn cleanup properly in accordance with S3 Spec
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+
+	donut.lock.Lock()
+	// the session may have been aborted meanwhile
+	if donut.storedBuckets.Get(bucket).(storedBucket).multiPartSession[key].uploadID == uploadID {
+		donut.cleanupMultipartSession(bucket, key, uploadID)
+	}
+	donut.lock.Unlock()
+	if err := donut.deleteObjectParts(bucket, uploadID); err != nil {
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	return newObjectMetadata, nil
+}
+
+// completeCachedObjectParts - create the object from parts cached in memory, parts are streamed from the
+// cache in order, the full object is never assembled in memory
+func (donut API) completeCachedObjectParts(bucket, key string, multiPartCache *data.Cache, completedParts []CompletePart, objectMetadata map[string]string) (ObjectMetadata, error) {
 	var size int64
-	var fullObject bytes.Buffer
-	for i := 0; i < len(parts.Part); i++ {
-		recvMD5 := parts.Part[i].ETag
-		object, ok := donut.multiPartObjects[uploadID].Get(parts.Part[i].PartNumber)
+	var partReaders []io.Reader
+	hash := md5.New()
+	for _, completedPart := range completedParts {
+		object, ok := multiPartCache.Get(completedPart.PartNumber)
 		if ok == false {
-			donut.lock.Unlock()
 			return ObjectMetadata{}, iodine.New(InvalidPart{}, nil)
 		}
 		size += int64(len(object))
 		calcMD5Bytes := md5.Sum(object)
 		// complete multi part request header md5sum per part is hex encoded
-		recvMD5Bytes, err := hex.DecodeString(strings.Trim(recvMD5, "\""))
+		recvMD5Bytes, err := hex.DecodeString(strings.Trim(completedPart.ETag, "\""))
 		if err != nil {
-			donut.lock.Unlock()
-			return ObjectMetadata{}, iodine.New(InvalidDigest{Md5: recvMD5}, nil)
+			return ObjectMetadata{}, iodine.New(InvalidDigest{Md5: completedPart.ETag}, nil)
 		}
 		if !bytes.Equal(recvMD5Bytes, calcMD5Bytes[:]) {
-			donut.lock.Unlock()
 			return ObjectMetadata{}, iodine.New(BadDigest{}, nil)
 		}
-		_, err = io.Copy(&fullObject, bytes.NewBuffer(object))
+		hash.Write(object)
+		partReaders = append(partReaders, bytes.NewReader(object))
+	}
+	// this is needed for final verification inside CreateObject, do not convert this to hex
+	md5sum := base64.StdEncoding.EncodeToString(hash.Sum(nil))
+	newObjectMetadata, err := donut.CreateObject(bucket, key, md5sum, size, io.MultiReader(partReaders...), objectMetadata, nil)
+	if err != nil {
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	return newObjectMetadata, nil
+}
+
+// completeObjectParts - create the object from parts stored on the disks, each part is opened only once
+// the previous one is read and its checksum verified while it is read
+func (donut API) completeObjectParts(bucket, key, uploadID string, completedParts []CompletePart, partMetadata map[int]PartMetadata, objectMetadata map[string]string) (ObjectMetadata, error) {
+	b, err := donut.getDonutBucket(bucket)
+	if err != nil {
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	var size int64
+	var partIDs []int
+	for _, completedPart := range completedParts {
+		part, ok := partMetadata[completedPart.PartNumber]
+		if !ok {
+			return ObjectMetadata{}, iodine.New(InvalidPart{}, nil)
+		}
+		// complete multi part request header md5sum per part is hex encoded
+		recvMD5Bytes, err := hex.DecodeString(strings.Trim(completedPart.ETag, "\""))
 		if err != nil {
-			donut.lock.Unlock()
-			return ObjectMetadata{}, iodine.New(err, nil)
+			return ObjectMetadata{}, iodine.New(InvalidDigest{Md5: completedPart.ETag}, nil)
+		}
+		if hex.EncodeToString(recvMD5Bytes) != part.ETag {
+			return ObjectMetadata{}, iodine.New(BadDigest{}, nil)
 		}
-		object = nil
-		go debug.FreeOSMemory()
+		size += part.Size
+		partIDs = append(partIDs, completedPart.PartNumber)
 	}
-
-	md5sumSlice := md5.Sum(fullObject.Bytes())
-	// this is needed for final verification inside CreateObject, do not convert this to hex
-	md5sum := base64.StdEncoding.EncodeToString(md5sumSlice[:])
-	donut.lock.Unlock()
-	objectMetadata, err := donut.CreateObject(bucket, key, md5sum, size, &fullObject, nil, nil)
+	reader := &partsReader{bucket: b, uploadID: uploadID, partIDs: partIDs}
+	defer reader.Close()
+	newObjectMetadata, err := donut.CreateObject(bucket, key, "", size, reader, objectMetadata, nil)
 	if err != nil {
-		// No need to call internal cleanup functions here, caller will call AbortMultipartUpload()
-		// which would in-turn cleanup properly in accordance with S3 Spec
 		return ObjectMetadata{}, iodine.New(err, nil)
 	}
-	fullObject.Reset()
+	return newObjectMetadata, nil
+}
 
-	donut.lock.Lock()
-	donut.cleanupMultipartSession(bucket, key, uploadID)
-	donut.lock.Unlock()
-	return objectMetadata, nil
+// partsReader reads the parts of a multipart upload from the disks one after another
+type partsReader struct {
+	bucket   bucket
+	uploadID string
+	partIDs  []int
+	current  io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.partIDs) == 0 {
+				return 0, io.EOF
+			}
+			reader, err := r.bucket.ReadObjectPart(r.uploadID, r.partIDs[0])
+			if err != nil {
+				return 0, iodine.New(err, nil)
+			}
+			r.current = reader
+			r.partIDs = r.partIDs[1:]
+		}
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Close - close the part being read
+func (r *partsReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
 }
 
 // byKey is a sortable interface for UploadMetadata slice
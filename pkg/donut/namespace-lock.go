@@ -0,0 +1,105 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package donut
+
+import "sync"
+
+// nsParam namespace of a lock, an object within a bucket
+type nsParam struct {
+	bucket string
+	object string
+}
+
+// nsLock reference counted read/write lock of a namespace
+type nsLock struct {
+	*sync.RWMutex
+	ref uint
+}
+
+// nsLockMap namespace lock manager, independent objects proceed concurrently
+// while readers of the same object share access
+type nsLockMap struct {
+	lockMap map[nsParam]*nsLock
+	mutex   *sync.Mutex
+}
+
+// newNSLockMap - instantiate a new namespace lock manager
+func newNSLockMap() *nsLockMap {
+	return &nsLockMap{
+		lockMap: make(map[nsParam]*nsLock),
+		mutex:   new(sync.Mutex),
+	}
+}
+
+// lock - acquire a read or write lock on a namespace, allocated on first use
+func (n *nsLockMap) lock(bucket, object string, readLock bool) {
+	param := nsParam{bucket: bucket, object: object}
+	n.mutex.Lock()
+	nsLk, found := n.lockMap[param]
+	if !found {
+		nsLk = &nsLock{RWMutex: new(sync.RWMutex)}
+		n.lockMap[param] = nsLk
+	}
+	nsLk.ref++
+	n.mutex.Unlock()
+
+	// blocking happens outside the manager's mutex
+	if readLock {
+		nsLk.RLock()
+	} else {
+		nsLk.Lock()
+	}
+}
+
+// unlock - release a read or write lock on a namespace, freed once nobody refers to it
+func (n *nsLockMap) unlock(bucket, object string, readLock bool) {
+	param := nsParam{bucket: bucket, object: object}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	nsLk, found := n.lockMap[param]
+	if !found {
+		return
+	}
+	if readLock {
+		nsLk.RUnlock()
+	} else {
+		nsLk.Unlock()
+	}
+	nsLk.ref--
+	if nsLk.ref == 0 {
+		delete(n.lockMap, param)
+	}
+}
+
+// Lock - acquire write lock on an object
+func (n *nsLockMap) Lock(bucket, object string) {
+	n.lock(bucket, object, false)
+}
+
+// Unlock - release write lock on an object
+func (n *nsLockMap) Unlock(bucket, object string) {
+	n.unlock(bucket, object, false)
+}
+
+// RLock - acquire read lock on an object
+func (n *nsLockMap) RLock(bucket, object string) {
+	n.lock(bucket, object, true)
+}
+
+// RUnlock - release read lock on an object
+func (n *nsLockMap) RUnlock(bucket, object string) {
+	n.unlock(bucket, object, true)
+}
@@ -0,0 +1,67 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"time"
+
+	. "github.com/minio/check"
+)
+
+type MyNamespaceLockSuite struct{}
+
+var _ = Suite(&MyNamespaceLockSuite{})
+
+// test readers share a lock and independent objects do not contend
+func (s *MyNamespaceLockSuite) TestNamespaceLockShared(c *C) {
+	nsMutex := newNSLockMap()
+	nsMutex.RLock("bucket", "object")
+	nsMutex.RLock("bucket", "object")
+	nsMutex.Lock("bucket", "other")
+	c.Assert(len(nsMutex.lockMap), Equals, 2)
+
+	nsMutex.RUnlock("bucket", "object")
+	nsMutex.RUnlock("bucket", "object")
+	nsMutex.Unlock("bucket", "other")
+	c.Assert(len(nsMutex.lockMap), Equals, 0)
+}
+
+// test a writer excludes readers of the same object
+func (s *MyNamespaceLockSuite) TestNamespaceLockExclusive(c *C) {
+	nsMutex := newNSLockMap()
+	nsMutex.Lock("bucket", "object")
+
+	locked := make(chan struct{})
+	go func() {
+		nsMutex.RLock("bucket", "object")
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		c.Fatal("read lock acquired while write lock is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	nsMutex.Unlock("bucket", "object")
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		c.Fatal("read lock not acquired after write lock is released")
+	}
+	nsMutex.RUnlock("bucket", "object")
+	c.Assert(len(nsMutex.lockMap), Equals, 0)
+}
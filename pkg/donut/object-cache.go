@@ -0,0 +1,254 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"io"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/minio/minio/pkg/donut/cache/data"
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// objects are cached in chunks of this size, so that only the read ranges of large objects occupy the cache
+const cacheChunkSize = 64 * 1024
+
+// whole object reads only fill the cache for objects taking at most this fraction of it, a single cold
+// read of a large object would otherwise flush everything else
+const cachedObjectFraction = 4
+
+// chunkKey - cache key of an object chunk, the etag keeps chunks of different versions of an object apart
+type chunkKey struct {
+	bucket string
+	object string
+	etag   string
+	index  int64
+}
+
+// String - name of the chunk on the disk cache
+func (key chunkKey) String() string {
+	return key.bucket + "/" + key.object + "/" + key.etag + "/" + strconv.FormatInt(key.index, 10)
+}
+
+// getCachePolicy - convert cache policy string into an eviction policy
+func getCachePolicy(policy string) (data.Policy, error) {
+	switch policy {
+	case "", "lru":
+		return data.NewLRU(), nil
+	case "lfu":
+		return data.NewLFU(), nil
+	case "arc":
+		return data.NewARC(), nil
+	default:
+		return nil, iodine.New(InvalidCachePolicy{Policy: policy}, nil)
+	}
+}
+
+// newChunkKey - cache key of chunk 'index' of the given object version
+func newChunkKey(objMetadata ObjectMetadata, index int64) chunkKey {
+	return chunkKey{
+		bucket: objMetadata.Bucket,
+		object: objMetadata.Object,
+		etag:   objMetadata.MD5Sum,
+		index:  index,
+	}
+}
+
+// chunkCount - number of chunks an object of the given size is cached in
+func chunkCount(size int64) int64 {
+	return (size + cacheChunkSize - 1) / cacheChunkSize
+}
+
+// chunkLength - length of chunk 'index' of an object of the given size
+func chunkLength(size, index int64) int64 {
+	if remaining := size - index*cacheChunkSize; remaining < cacheChunkSize {
+		return remaining
+	}
+	return cacheChunkSize
+}
+
+// writeChunkRange - write the part of chunk 'index' which falls into the requested range
+func writeChunkRange(w io.Writer, chunk []byte, index, start, length int64) (int64, error) {
+	chunkStart := index * cacheChunkSize
+	from := start - chunkStart
+	if from < 0 {
+		from = 0
+	}
+	to := start + length - chunkStart
+	if to > int64(len(chunk)) {
+		to = int64(len(chunk))
+	}
+	written, err := w.Write(chunk[from:to])
+	if err != nil {
+		return int64(written), iodine.New(err, nil)
+	}
+	return int64(written), nil
+}
+
+// isCacheableRead - ranged reads are always cached, whole objects only if they are small next to the cache
+func isCacheableRead(objMetadata ObjectMetadata, start, length int64, maxSize uint64) bool {
+	if start > 0 || length < objMetadata.Size || maxSize == 0 {
+		return true
+	}
+	return uint64(objMetadata.Size) <= maxSize/cachedObjectFraction
+}
+
+// getCachedChunk - look up chunk 'index' in memory, then on the disk cache. Chunks found on the disk
+// cache move up into memory if the read is cacheable
+func (donut API) getCachedChunk(objMetadata ObjectMetadata, index int64, cache bool) ([]byte, bool) {
+	key := newChunkKey(objMetadata, index)
+	if chunk, ok := donut.objects.Get(key); ok {
+		return chunk, true
+	}
+	// written back objects are read from memory until they are on the disks
+	if donut.writeBack != nil {
+		if chunk, ok := donut.writeBack.getPendingChunk(objMetadata, index); ok {
+			return chunk, true
+		}
+	}
+	if donut.diskCache == nil {
+		return nil, false
+	}
+	chunk, ok := donut.diskCache.Get(key.String())
+	if !ok {
+		return nil, false
+	}
+	if cache {
+		donut.objects.Set(key, chunk)
+	}
+	return chunk, true
+}
+
+// isChunkCached - whether chunk 'index' is in memory or on the disk cache, without reading it
+func (donut API) isChunkCached(objMetadata ObjectMetadata, index int64) bool {
+	key := newChunkKey(objMetadata, index)
+	if donut.objects.Contains(key) {
+		return true
+	}
+	return donut.diskCache != nil && donut.diskCache.Contains(key.String())
+}
+
+// getObjectChunks - write a range of an object, cached chunks are served from memory or the disk
+// cache, runs of missing chunks are read from disk and cached on the way if the read is cacheable.
+// Caller holds the namespace read lock
+func (donut API) getObjectChunks(w io.Writer, objMetadata ObjectMetadata, start, length int64) (int64, error) {
+	if length == 0 {
+		return 0, nil
+	}
+	cache := isCacheableRead(objMetadata, start, length, donut.config.MaxSize)
+	firstChunk := start / cacheChunkSize
+	lastChunk := (start + length - 1) / cacheChunkSize
+
+	var written int64
+	for index := firstChunk; index <= lastChunk; {
+		if chunk, ok := donut.getCachedChunk(objMetadata, index, cache); ok {
+			n, err := writeChunkRange(w, chunk, index, start, length)
+			written += n
+			if err != nil {
+				return written, iodine.New(err, nil)
+			}
+			index++
+			continue
+		}
+		if len(donut.config.NodeDiskMap) == 0 {
+			// without disks the cache holds the only copy, a partially evicted object is lost
+			return written, iodine.New(ObjectNotFound{Object: objMetadata.Object}, nil)
+		}
+		// a run of missing chunks is read from disk in one go
+		missEnd := index + 1
+		for missEnd <= lastChunk && !donut.isChunkCached(objMetadata, missEnd) {
+			missEnd++
+		}
+		n, err := donut.fillObjectChunks(w, objMetadata, index, missEnd, start, length)
+		written += n
+		if err != nil {
+			return written, iodine.New(err, nil)
+		}
+		index = missEnd
+	}
+	return written, nil
+}
+
+// fillObjectChunks - read chunks [from, to) of an object from disk, write their part of the requested
+// range and cache them in memory and on the disk cache if the read is cacheable for each of them.
+// Whole objects are read through the checksum verifying object reader
+func (donut API) fillObjectChunks(w io.Writer, objMetadata ObjectMetadata, from, to, start, length int64) (int64, error) {
+	cache := isCacheableRead(objMetadata, start, length, donut.config.MaxSize)
+	diskCache := donut.diskCache != nil && isCacheableRead(objMetadata, start, length, donut.config.DiskCacheSize)
+
+	var reader io.ReadCloser
+	var err error
+	if from == 0 && to == chunkCount(objMetadata.Size) {
+		reader, _, err = donut.getObject(objMetadata.Bucket, objMetadata.Object)
+	} else {
+		fillStart := from * cacheChunkSize
+		fillEnd := to * cacheChunkSize
+		if fillEnd > objMetadata.Size {
+			fillEnd = objMetadata.Size
+		}
+		reader, err = donut.getPartialObject(objMetadata.Bucket, objMetadata.Object, fillStart, fillEnd-fillStart)
+	}
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	defer reader.Close()
+
+	var written int64
+	for index := from; index < to; index++ {
+		chunk := make([]byte, chunkLength(objMetadata.Size, index))
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return written, iodine.New(err, nil)
+		}
+		n, err := writeChunkRange(w, chunk, index, start, length)
+		written += n
+		if err != nil {
+			return written, iodine.New(err, nil)
+		}
+		// best effort, a concurrent reader may have cached it already
+		key := newChunkKey(objMetadata, index)
+		if cache {
+			donut.objects.Set(key, chunk)
+		}
+		if diskCache {
+			donut.diskCache.Set(key.String(), chunk)
+		}
+	}
+	// a verified read reports checksum mismatches at the end of the object, do not keep what was cached
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		for index := from; index < to; index++ {
+			donut.deleteChunk(newChunkKey(objMetadata, index))
+		}
+		return written, iodine.New(err, nil)
+	}
+	return written, nil
+}
+
+// deleteChunk - purge a chunk from memory and the disk cache
+func (donut API) deleteChunk(key chunkKey) {
+	donut.objects.Delete(key)
+	if donut.diskCache != nil {
+		donut.diskCache.Delete(key.String())
+	}
+}
+
+// deleteObjectChunks - purge all cached chunks of an object version
+func (donut API) deleteObjectChunks(objMetadata ObjectMetadata) {
+	for index := int64(0); index < chunkCount(objMetadata.Size); index++ {
+		donut.deleteChunk(newChunkKey(objMetadata, index))
+	}
+}
@@ -22,8 +22,10 @@ import (
 	"encoding/hex"
 	"errors"
 	"net/http"
+	"net/url"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -38,6 +40,8 @@ type Signature struct {
 	SecretAccessKey string
 	AuthHeader      string
 	Request         *http.Request
+	// Service the request is signed for, s3 if empty
+	Service string
 }
 
 const (
@@ -191,12 +195,20 @@ func (r *Signature) getScope(t time.Time) string {
 	scope := strings.Join([]string{
 		t.Format(yyyymmdd),
 		"milkyway",
-		"s3",
+		r.getService(),
 		"aws4_request",
 	}, "/")
 	return scope
 }
 
+// getService service the request is signed for
+func (r *Signature) getService() string {
+	if r.Service == "" {
+		return "s3"
+	}
+	return r.Service
+}
+
 // getStringToSign a string based on selected query values
 func (r *Signature) getStringToSign(canonicalRequest string, t time.Time) string {
 	stringToSign := authHeaderPrefix + "\n" + t.Format(iso8601Format) + "\n"
@@ -210,7 +222,7 @@ func (r *Signature) getSigningKey(t time.Time) []byte {
 	secret := r.SecretAccessKey
 	date := sumHMAC([]byte("AWS4"+secret), []byte(t.Format(yyyymmdd)))
 	region := sumHMAC(date, []byte("milkyway"))
-	service := sumHMAC(region, []byte("s3"))
+	service := sumHMAC(region, []byte(r.getService()))
 	signingKey := sumHMAC(service, []byte("aws4_request"))
 	return signingKey
 }
@@ -249,3 +261,80 @@ func (r *Signature) DoesSignatureMatch(hashedPayload string) (bool, error) {
 	}
 	return true, nil
 }
+
+// Sign - sign the request with an auth header of AccessKeyID, hashedPayload is the hex encoded sha256 of
+// its body. Used by clients of the server, the controller among them
+func (r *Signature) Sign(hashedPayload string, t time.Time) {
+	r.Request.Header.Set("X-Amz-Date", t.Format(iso8601Format))
+	r.Request.Header.Set("X-Amz-Content-Sha256", hashedPayload)
+	signedHeaders := map[string][]string{
+		"x-amz-content-sha256": r.Request.Header["X-Amz-Content-Sha256"],
+		"x-amz-date":           r.Request.Header["X-Amz-Date"],
+	}
+	r.AuthHeader = authHeaderPrefix + " Credential=" + r.AccessKeyID + "/" + r.getScope(t) +
+		", SignedHeaders=" + r.getSignedHeaders(signedHeaders) + ", Signature="
+	stringToSign := r.getStringToSign(r.getCanonicalRequest(), t)
+	r.AuthHeader += r.getSignature(r.getSigningKey(t), stringToSign)
+	r.Request.Header.Set("Authorization", r.AuthHeader)
+}
+
+// getPresignedCanonicalRequest generate a canonical request of a presigned url, the query string
+// carries everything except the signature itself and the payload is never signed
+func (r *Signature) getPresignedCanonicalRequest(query url.Values) string {
+	signedHeaders := make(map[string][]string)
+	for _, header := range strings.Split(query.Get("X-Amz-SignedHeaders"), ";") {
+		if val, ok := r.Request.Header[http.CanonicalHeaderKey(header)]; ok {
+			signedHeaders[header] = val
+		}
+	}
+	canonicalQuery := make(url.Values)
+	for k, v := range query {
+		if k != "X-Amz-Signature" {
+			canonicalQuery[k] = v
+		}
+	}
+	encodedPath, _ := urlEncodeName(r.Request.URL.Path)
+	// convert any space strings back to "+"
+	encodedPath = strings.Replace(encodedPath, "+", "%20", -1)
+	canonicalRequest := strings.Join([]string{
+		r.Request.Method,
+		encodedPath,
+		strings.Replace(canonicalQuery.Encode(), "+", "%20", -1),
+		r.getCanonicalHeaders(signedHeaders),
+		r.getSignedHeaders(signedHeaders),
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	return canonicalRequest
+}
+
+// DoesPresignedSignatureMatch - Verify query string signature of a presigned url in accordance with - http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+// returns true if matches and has not expired yet, false other wise if error is not nil then it is always false
+func (r *Signature) DoesPresignedSignatureMatch() (bool, error) {
+	query := r.Request.URL.Query()
+	if query.Get("X-Amz-Algorithm") != authHeaderPrefix {
+		return false, nil
+	}
+	date := query.Get("X-Amz-Date")
+	if date == "" {
+		return false, iodine.New(MissingDateHeader{}, nil)
+	}
+	t, err := time.Parse(iso8601Format, date)
+	if err != nil {
+		return false, iodine.New(err, nil)
+	}
+	expires, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil {
+		return false, iodine.New(err, nil)
+	}
+	if time.Now().UTC().After(t.Add(time.Duration(expires) * time.Second)) {
+		return false, nil
+	}
+	canonicalRequest := r.getPresignedCanonicalRequest(query)
+	stringToSign := r.getStringToSign(canonicalRequest, t)
+	signingKey := r.getSigningKey(t)
+	newSignature := r.getSignature(signingKey, stringToSign)
+	if newSignature != query.Get("X-Amz-Signature") {
+		return false, nil
+	}
+	return true, nil
+}
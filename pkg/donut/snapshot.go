@@ -0,0 +1,360 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// snapshotRecord - a bucket or an object with its data, or an object evicted or expired from the cache.
+// Snapshot and journal files are streams of them
+type snapshotRecord struct {
+	Bucket  *BucketMetadata `json:"bucket,omitempty"`
+	Object  *ObjectMetadata `json:"object,omitempty"`
+	Data    []byte          `json:"data,omitempty"`
+	Evicted *ObjectMetadata `json:"evicted,omitempty"`
+}
+
+// snapshot keeps the buckets and objects of a donut without disks in a file. Changes made after the
+// snapshot was taken are appended to numbered journals next to it, every record replaces what an earlier
+// one said about the same bucket or object, so the snapshot and all journals are replayed in order
+type snapshot struct {
+	path string
+	// lock guards the current journal, writing snapshots is serialized by writeLock
+	lock      *sync.Mutex
+	writeLock *sync.Mutex
+	journal   *os.File
+	sequence  int64
+}
+
+// newSnapshot - snapshot in path, journals are named after it
+func newSnapshot(path string) (*snapshot, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return &snapshot{
+		path:      path,
+		lock:      new(sync.Mutex),
+		writeLock: new(sync.Mutex),
+	}, nil
+}
+
+// journalPath - name of journal 'sequence'
+func (s *snapshot) journalPath(sequence int64) string {
+	return fmt.Sprintf("%s.journal.%020d", s.path, sequence)
+}
+
+// listJournals - sequence numbers of all journals, in the order they were written
+func (s *snapshot) listJournals() ([]int64, error) {
+	names, err := filepath.Glob(s.path + ".journal.*")
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var sequences []int64
+	for _, name := range names {
+		sequence, err := strconv.ParseInt(strings.TrimPrefix(name, s.path+".journal."), 10, 64)
+		if err != nil {
+			continue
+		}
+		sequences = append(sequences, sequence)
+	}
+	sort.Sort(int64s(sequences))
+	return sequences, nil
+}
+
+type int64s []int64
+
+func (a int64s) Len() int           { return len(a) }
+func (a int64s) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a int64s) Less(i, j int) bool { return a[i] < a[j] }
+
+// append - journal a record, changes are journaled once they are made in memory and synced before
+// they are acknowledged
+func (s *snapshot) append(record snapshotRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Failed to journal snapshot record: %s", err)
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.journal == nil {
+		return
+	}
+	if _, err := s.journal.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to journal snapshot record: %s", err)
+		return
+	}
+	if err := s.journal.Sync(); err != nil {
+		log.Printf("Failed to journal snapshot record: %s", err)
+	}
+}
+
+// rotate - journal to a new journal from now on, returns its sequence number
+func (s *snapshot) rotate() (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	journal, err := os.OpenFile(s.journalPath(s.sequence+1), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	if s.journal != nil {
+		s.journal.Close()
+	}
+	s.sequence++
+	s.journal = journal
+	return s.sequence, nil
+}
+
+// readRecords - apply all records of a snapshot or journal file, a record cut short by a crash ends it
+func readRecords(path string, apply func(snapshotRecord)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return iodine.New(err, nil)
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var record snapshotRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Printf("Ignoring the rest of %s: %s", path, err)
+			return nil
+		}
+		apply(record)
+	}
+}
+
+// replaySnapshot - restore the buckets and objects of the snapshot and its journals
+func (donut API) replaySnapshot() error {
+	if err := readRecords(donut.snapshot.path, donut.restoreRecord); err != nil {
+		return iodine.New(err, nil)
+	}
+	sequences, err := donut.snapshot.listJournals()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, sequence := range sequences {
+		if err := readRecords(donut.snapshot.journalPath(sequence), donut.restoreRecord); err != nil {
+			return iodine.New(err, nil)
+		}
+		donut.snapshot.sequence = sequence
+	}
+	return nil
+}
+
+// restoreRecord - make a bucket or an object what the record says
+func (donut API) restoreRecord(record snapshotRecord) {
+	switch {
+	case record.Bucket != nil:
+		donut.lock.Lock()
+		defer donut.lock.Unlock()
+		if donut.storedBuckets.Exists(record.Bucket.Name) {
+			storedBucket := donut.storedBuckets.Get(record.Bucket.Name).(storedBucket)
+			storedBucket.bucketMetadata = *record.Bucket
+			donut.storedBuckets.Set(record.Bucket.Name, storedBucket)
+			return
+		}
+		var newBucket = storedBucket{}
+		newBucket.objectMetadata = make(map[string]ObjectMetadata)
+		newBucket.multiPartSession = make(map[string]MultiPartSession)
+		newBucket.partMetadata = make(map[string]map[int]PartMetadata)
+		newBucket.bucketMetadata = *record.Bucket
+		donut.storedBuckets.Set(record.Bucket.Name, newBucket)
+	case record.Object != nil:
+		objMetadata := *record.Object
+		if int64(len(record.Data)) != objMetadata.Size {
+			log.Printf("Dropping %s/%s from snapshot, its data is incomplete", objMetadata.Bucket, objMetadata.Object)
+			return
+		}
+		if !donut.storedBuckets.Exists(objMetadata.Bucket) {
+			log.Printf("Dropping %s/%s from snapshot, its bucket is missing", objMetadata.Bucket, objMetadata.Object)
+			return
+		}
+		objectKey := objMetadata.Bucket + "/" + objMetadata.Object
+		if previousObject, ok := donut.getCachedObjectMetadata(objMetadata.Bucket, objectKey); ok {
+			donut.deleteObjectChunks(previousObject)
+		}
+		var chunkKeys []interface{}
+		var chunks [][]byte
+		for index := int64(0); index < chunkCount(objMetadata.Size); index++ {
+			chunkKeys = append(chunkKeys, newChunkKey(objMetadata, index))
+			chunks = append(chunks, record.Data[index*cacheChunkSize:index*cacheChunkSize+chunkLength(objMetadata.Size, index)])
+		}
+		if ok := donut.objects.SetAll(chunkKeys, chunks); !ok {
+			log.Printf("Dropping %s/%s from snapshot, it does not fit the cache", objMetadata.Bucket, objMetadata.Object)
+			return
+		}
+		donut.setCachedObjectMetadata(objMetadata.Bucket, objectKey, objMetadata)
+	case record.Evicted != nil:
+		objMetadata := *record.Evicted
+		objectKey := objMetadata.Bucket + "/" + objMetadata.Object
+		// a later version of the object is not affected
+		if previousObject, ok := donut.getCachedObjectMetadata(objMetadata.Bucket, objectKey); ok && previousObject.MD5Sum == objMetadata.MD5Sum {
+			donut.deleteObjectChunks(previousObject)
+			donut.lock.Lock()
+			defer donut.lock.Unlock()
+			delete(donut.storedBuckets.Get(objMetadata.Bucket).(storedBucket).objectMetadata, objectKey)
+		}
+	}
+}
+
+// journalBucket - journal the current state of a bucket, caller holds donut.lock
+func (donut API) journalBucket(bucketMetadata BucketMetadata) {
+	if donut.snapshot == nil {
+		return
+	}
+	donut.snapshot.append(snapshotRecord{Bucket: &bucketMetadata})
+}
+
+// journalObject - journal an object stored in memory, caller holds donut.lock so that an eviction of the
+// object is journaled after it
+func (donut API) journalObject(objMetadata ObjectMetadata, chunks [][]byte) {
+	if donut.snapshot == nil {
+		return
+	}
+	var data []byte
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	donut.snapshot.append(snapshotRecord{Object: &objMetadata, Data: data})
+}
+
+// journalEviction - journal an object lost to an eviction or expiry of its chunks, caller holds donut.lock
+func (donut API) journalEviction(objMetadata ObjectMetadata) {
+	if donut.snapshot == nil {
+		return
+	}
+	donut.snapshot.append(snapshotRecord{Evicted: &objMetadata})
+}
+
+// writeSnapshot - write all buckets and objects held in memory to the snapshot, the journals it covers
+// are removed once it is in place
+func (donut API) writeSnapshot() error {
+	donut.snapshot.writeLock.Lock()
+	defer donut.snapshot.writeLock.Unlock()
+
+	// everything changed from here on is journaled to the new journal, the snapshot covers the rest
+	sequence, err := donut.snapshot.rotate()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	var buckets []BucketMetadata
+	var objects []ObjectMetadata
+	donut.lock.Lock()
+	for _, value := range donut.storedBuckets.GetAll() {
+		storedBucket := value.(storedBucket)
+		buckets = append(buckets, storedBucket.bucketMetadata)
+		for _, objMetadata := range storedBucket.objectMetadata {
+			objects = append(objects, objMetadata)
+		}
+	}
+	donut.lock.Unlock()
+
+	file, err := ioutil.TempFile(filepath.Dir(donut.snapshot.path), filepath.Base(donut.snapshot.path)+".tmp-")
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	defer os.Remove(file.Name())
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for i := range buckets {
+		if err := encoder.Encode(snapshotRecord{Bucket: &buckets[i]}); err != nil {
+			file.Close()
+			return iodine.New(err, nil)
+		}
+	}
+	for i := range objects {
+		data, ok := donut.getCachedObjectData(objects[i])
+		if !ok {
+			// evicted meanwhile
+			continue
+		}
+		if err := encoder.Encode(snapshotRecord{Object: &objects[i], Data: data}); err != nil {
+			file.Close()
+			return iodine.New(err, nil)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return iodine.New(err, nil)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return iodine.New(err, nil)
+	}
+	if err := file.Close(); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := os.Rename(file.Name(), donut.snapshot.path); err != nil {
+		return iodine.New(err, nil)
+	}
+
+	sequences, err := donut.snapshot.listJournals()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, journal := range sequences {
+		if journal < sequence {
+			os.Remove(donut.snapshot.journalPath(journal))
+		}
+	}
+	return nil
+}
+
+// getCachedObjectData - all chunks of an object version, false if any of them is evicted. Reading them
+// for a snapshot is not an access, the eviction order is left untouched
+func (donut API) getCachedObjectData(objMetadata ObjectMetadata) ([]byte, bool) {
+	data := make([]byte, 0, objMetadata.Size)
+	for index := int64(0); index < chunkCount(objMetadata.Size); index++ {
+		chunk, ok := donut.objects.Peek(newChunkKey(objMetadata, index))
+		if !ok {
+			return nil, false
+		}
+		data = append(data, chunk...)
+	}
+	return data, true
+}
+
+// snapshotEvery - write a snapshot every interval, runs for the lifetime of the API
+func (donut API) snapshotEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := donut.writeSnapshot(); err != nil {
+			log.Printf("Failed to write snapshot %s: %s", donut.snapshot.path, iodine.ToError(err))
+		}
+	}
+}
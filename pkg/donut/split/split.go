@@ -17,8 +17,6 @@
 package split
 
 import (
-	"bufio"
-	"bytes"
 	"errors"
 	"io"
 	"io/ioutil"
@@ -27,6 +25,7 @@ import (
 	"strings"
 
 	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/bufpool"
 )
 
 // Message - message structure for results from the Stream goroutine
@@ -40,6 +39,9 @@ type Message struct {
 // an error occurs, the method sends the error over the channel and returns.
 // Before returning, the channel is always closed.
 //
+// Chunks are read straight into buffers of the shared buffer pool, once consumed
+// they may be handed back with bufpool.Put.
+//
 // The user should run this as a gorountine and retrieve the data over the
 // channel.
 //
@@ -47,6 +49,7 @@ type Message struct {
 //  go Stream(reader, chunkSize, channel)
 //  for chunk := range channel {
 //    log.Println(chunk.Data)
+//    bufpool.Put(chunk.Data)
 //  }
 func Stream(reader io.Reader, chunkSize uint64) <-chan Message {
 	ch := make(chan Message)
@@ -57,40 +60,27 @@ func Stream(reader io.Reader, chunkSize uint64) <-chan Message {
 func splitStreamGoRoutine(reader io.Reader, chunkSize uint64, ch chan<- Message) {
 	defer close(ch)
 
-	// we read until EOF or another error
-	var readError error
-
 	// run this until an EOF or error occurs
-	for readError == nil {
-		// keep track of how much data has been read
-		var totalRead uint64
-		// Create a buffer to write the current chunk into
-		var bytesBuffer bytes.Buffer
-		bytesWriter := bufio.NewWriter(&bytesBuffer)
-		// read a full chunk
-		for totalRead < chunkSize && readError == nil {
-			var currentRead int
-			// if we didn't read a full chunk, we should attempt to read again.
-			// We create a byte array representing how much space is left
-			// unwritten in the given chunk
-			chunk := make([]byte, chunkSize-totalRead)
-			currentRead, readError = reader.Read(chunk)
-			// keep track of how much we have read in total
-			totalRead = totalRead + uint64(currentRead)
-			// prune the array to only what has been read, write to chunk buffer
-			chunk = chunk[0:currentRead]
-			bytesWriter.Write(chunk)
-		}
-		// flush stream to underlying byte buffer
-		bytesWriter.Flush()
+	for {
+		// read a full chunk, short only at the end of the stream
+		chunk := bufpool.Get(int(chunkSize))
+		currentRead, readError := io.ReadFull(reader, chunk)
 		// if we have data available, send it over the channel
-		if bytesBuffer.Len() != 0 {
-			ch <- Message{bytesBuffer.Bytes(), nil}
+		if currentRead != 0 {
+			ch <- Message{chunk[:currentRead], nil}
+		} else {
+			bufpool.Put(chunk)
+		}
+		switch readError {
+		case nil:
+			continue
+		case io.EOF, io.ErrUnexpectedEOF:
+			return
+		default:
+			// if we have an error other than an EOF, send it over the channel
+			ch <- Message{nil, readError}
+			return
 		}
-	}
-	// if we have an error other than an EOF, send it over the channel
-	if readError != io.EOF {
-		ch <- Message{nil, readError}
 	}
 }
 
@@ -170,6 +160,7 @@ func FileWithPrefix(filename string, chunkSize uint64, outputPrefix string) erro
 			return chunk.Err
 		}
 		err := ioutil.WriteFile(outputPrefix+"."+strconv.Itoa(i), chunk.Data, 0600)
+		bufpool.Put(chunk.Data)
 		if err != nil {
 			return err
 		}
@@ -26,6 +26,7 @@ import (
 
 	. "github.com/minio/check"
 	"github.com/minio/minio/pkg/donut/split"
+	"github.com/minio/minio/pkg/utils/bufpool"
 )
 
 type MySuite struct{}
@@ -70,3 +71,14 @@ func (s *MySuite) TestFileSplitJoin(c *C) {
 	_, err = io.Copy(devnull, reader)
 	c.Assert(err, IsNil)
 }
+
+func BenchmarkSplitStream(b *testing.B) {
+	data := make([]byte, 64*1024*1024)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for chunk := range split.Stream(bytes.NewReader(data), 10*1024*1024) {
+			bufpool.Put(chunk.Data)
+		}
+	}
+}
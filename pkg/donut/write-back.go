@@ -0,0 +1,474 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// objects up to this size are written back, larger ones are written to the disks right away
+const maxWriteBackSize = 1024 * 1024
+
+// journaled objects not yet on the disks are held in memory, past either limit objects are written to the
+// disks right away
+const (
+	maxWriteBackObjects = 1024
+	maxWriteBackBytes   = 64 * maxWriteBackSize
+)
+
+// journal files are written with this prefix and renamed into place once synced
+const journalTempPrefix = ".tmp-"
+
+// journal files which can not be read are renamed with this prefix and left for inspection
+const journalInvalidPrefix = ".invalid-"
+
+// journalEntry - an object acknowledged to the client but not yet written to the disks. Its journal
+// file holds the json encoded entry on the first line, followed by the object data
+type journalEntry struct {
+	Bucket   string            `json:"bucket"`
+	Object   string            `json:"object"`
+	MD5Sum   string            `json:"md5sum"`
+	Metadata map[string]string `json:"metadata"`
+
+	name   string
+	chunks [][]byte
+}
+
+// writeBack holds the journaled objects and the queue of objects to be written to the disks. One process
+// at a time owns the journal, it holds a lock on a file next to the journal directory
+type writeBack struct {
+	dir      string
+	lock     *sync.Mutex
+	lockFile *os.File
+	// journaled and journaledSize count the entries and bytes journaled and not yet removed
+	journaled     int
+	journaledSize int64
+	// pending holds the latest journaled version of each object, by bucket/object
+	pending map[string]*journalEntry
+	queue   []*journalEntry
+	// unflushed counts the queued entries and the one being flushed, flushed is signalled as it drops
+	unflushed int
+	flushed   *sync.Cond
+	wake      chan struct{}
+	sequence  int64
+}
+
+// newWriteBack - journal in dir, journal file names sort in the order they were written
+func newWriteBack(dir string) (*writeBack, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	lock := new(sync.Mutex)
+	return &writeBack{
+		dir:      dir,
+		lock:     lock,
+		pending:  make(map[string]*journalEntry),
+		flushed:  sync.NewCond(lock),
+		wake:     make(chan struct{}, 1),
+		sequence: time.Now().UnixNano(),
+	}, nil
+}
+
+// acquire - take the journal from a previous process, waiting for it to give it up unless wait is false.
+// Returns false if another process owns it
+func (w *writeBack) acquire(wait bool) (bool, error) {
+	lockFile, err := os.OpenFile(filepath.Clean(w.dir)+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return false, iodine.New(err, nil)
+	}
+	how := syscall.LOCK_EX
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	if err := syscall.Flock(int(lockFile.Fd()), how); err != nil {
+		lockFile.Close()
+		if err == syscall.EWOULDBLOCK {
+			return false, nil
+		}
+		return false, iodine.New(err, nil)
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.lockFile = lockFile
+	return true, nil
+}
+
+// release - give up the journal, a process waiting for it replays what is left
+func (w *writeBack) release() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.lockFile == nil {
+		return
+	}
+	w.lockFile.Close()
+	w.lockFile = nil
+}
+
+// reserve - account for an entry of size bytes about to be journaled, false if the journal is not owned
+// or full and the object is to be written to the disks right away
+func (w *writeBack) reserve(size int64) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.lockFile == nil || w.journaled >= maxWriteBackObjects || w.journaledSize+size > maxWriteBackBytes {
+		return false
+	}
+	w.journaled++
+	w.journaledSize += size
+	return true
+}
+
+// unreserve - an entry reserved or journaled is gone
+func (w *writeBack) unreserve(size int64) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.journaled--
+	w.journaledSize -= size
+}
+
+// size - length of the object data of an entry
+func (entry *journalEntry) size() int64 {
+	var size int64
+	for _, chunk := range entry.chunks {
+		size += int64(len(chunk))
+	}
+	return size
+}
+
+// journal - durably record an entry, once this returns the entry survives a crash
+func (w *writeBack) journal(entry *journalEntry) error {
+	header, err := json.Marshal(entry)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	file, err := ioutil.TempFile(w.dir, journalTempPrefix)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	writer := bufio.NewWriter(file)
+	writer.Write(header)
+	writer.WriteByte('\n')
+	for _, chunk := range entry.chunks {
+		writer.Write(chunk)
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return iodine.New(err, nil)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return iodine.New(err, nil)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return iodine.New(err, nil)
+	}
+
+	w.lock.Lock()
+	w.sequence++
+	entry.name = fmt.Sprintf("%020d", w.sequence)
+	w.lock.Unlock()
+	if err := os.Rename(file.Name(), filepath.Join(w.dir, entry.name)); err != nil {
+		os.Remove(file.Name())
+		return iodine.New(err, nil)
+	}
+	// the rename is only durable once the directory is synced
+	dir, err := os.Open(w.dir)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// readJournal - journal entries left behind by a previous process, in the order they were written. Entries
+// which can not be read are logged and skipped, those which are invalid are set aside
+func (w *writeBack) readJournal() ([]*journalEntry, error) {
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	// ReadDir sorts by name
+	var entries []*journalEntry
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), journalInvalidPrefix) {
+			continue
+		}
+		// journal files are only renamed into place once complete
+		if strings.HasPrefix(file.Name(), journalTempPrefix) {
+			os.Remove(filepath.Join(w.dir, file.Name()))
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(w.dir, file.Name()))
+		if err != nil {
+			log.Printf("Failed to read write back journal entry %s: %s", file.Name(), err)
+			continue
+		}
+		entry := &journalEntry{name: file.Name()}
+		newline := bytes.IndexByte(content, '\n')
+		if newline < 0 || json.Unmarshal(content[:newline], entry) != nil {
+			w.setAside(file.Name())
+			continue
+		}
+		entry.chunks = [][]byte{content[newline+1:]}
+		entries = append(entries, entry)
+		w.lock.Lock()
+		w.journaled++
+		w.journaledSize += entry.size()
+		w.lock.Unlock()
+	}
+	return entries, nil
+}
+
+// setAside - keep an invalid journal file out of the way of later replays
+func (w *writeBack) setAside(name string) {
+	log.Printf("Setting aside %s", InvalidJournalEntry{Name: name})
+	if err := os.Rename(filepath.Join(w.dir, name), filepath.Join(w.dir, journalInvalidPrefix+name)); err != nil {
+		log.Printf("Failed to set aside write back journal entry %s: %s", name, err)
+	}
+}
+
+// remove - forget a journal entry, it is on the disks or superseded by a later one
+func (w *writeBack) remove(entry *journalEntry) {
+	if err := os.Remove(filepath.Join(w.dir, entry.name)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove write back journal entry %s: %s", entry.name, err)
+	}
+	w.unreserve(entry.size())
+}
+
+// enqueue - make an entry the latest version of its object and queue it to be flushed
+func (w *writeBack) enqueue(entry *journalEntry) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.pending[entry.Bucket+"/"+entry.Object] = entry
+	w.queue = append(w.queue, entry)
+	w.unflushed++
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next - the oldest queued entry, nil if there is none
+func (w *writeBack) next() *journalEntry {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if len(w.queue) == 0 {
+		return nil
+	}
+	entry := w.queue[0]
+	w.queue = w.queue[1:]
+	return entry
+}
+
+// done - an entry dequeued by next is dealt with
+func (w *writeBack) done() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.unflushed--
+	w.flushed.Broadcast()
+}
+
+// isPending - whether entry is the latest version of its object and not yet on the disks
+func (w *writeBack) isPending(entry *journalEntry) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.pending[entry.Bucket+"/"+entry.Object] == entry
+}
+
+// getPendingChunk - chunk 'index' of an object version which is not yet on the disks
+func (w *writeBack) getPendingChunk(objMetadata ObjectMetadata, index int64) ([]byte, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	entry, ok := w.pending[objMetadata.Bucket+"/"+objMetadata.Object]
+	if !ok || entry.MD5Sum != objMetadata.MD5Sum || index >= int64(len(entry.chunks)) {
+		return nil, false
+	}
+	return entry.chunks[index], true
+}
+
+// writeBackObject - journal an object and queue it to be written to the disks, caller holds the
+// namespace write lock of the object. Written to the disks right away if the journal is full or owned by
+// another process
+func (donut API) writeBackObject(bucket, key, expectedMD5Sum string, size int64, data io.Reader, metadata map[string]string, signature *Signature) (ObjectMetadata, error) {
+	if !donut.writeBack.reserve(size) {
+		return donut.putObject(bucket, key, expectedMD5Sum, data, metadata, signature)
+	}
+	if _, err := donut.getDonutBucket(bucket); err != nil {
+		donut.writeBack.unreserve(size)
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	chunks, md5Sum, err := readObjectChunks(bucket, key, size, data, expectedMD5Sum, signature)
+	if err != nil {
+		donut.writeBack.unreserve(size)
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	entry := &journalEntry{
+		Bucket:   bucket,
+		Object:   key,
+		MD5Sum:   md5Sum,
+		Metadata: metadata,
+		chunks:   chunks,
+	}
+	if err := donut.writeBack.journal(entry); err != nil {
+		donut.writeBack.unreserve(size)
+		return ObjectMetadata{}, iodine.New(err, nil)
+	}
+	donut.writeBack.enqueue(entry)
+	return ObjectMetadata{
+		Version:  objectMetadataVersion,
+		Bucket:   bucket,
+		Object:   key,
+		Metadata: metadata,
+		Created:  time.Now().UTC(),
+		MD5Sum:   md5Sum,
+		Size:     size,
+	}, nil
+}
+
+// flushWriteBack - write queued objects to the disks one after another, runs for the lifetime of the API
+func (donut API) flushWriteBack() {
+	for range donut.writeBack.wake {
+		for entry := donut.writeBack.next(); entry != nil; entry = donut.writeBack.next() {
+			if err := donut.flushEntry(entry); err != nil {
+				// the entry stays readable from memory and is written again on the next start
+				log.Printf("Failed to write back %s/%s: %s", entry.Bucket, entry.Object, iodine.ToError(err))
+			}
+			donut.writeBack.done()
+		}
+	}
+}
+
+// flushEntry - write a journaled object to the disks, unless a later version superseded it
+func (donut API) flushEntry(entry *journalEntry) error {
+	donut.nsMutex.Lock(entry.Bucket, entry.Object)
+	defer donut.nsMutex.Unlock(entry.Bucket, entry.Object)
+
+	if !donut.writeBack.isPending(entry) {
+		donut.writeBack.remove(entry)
+		return nil
+	}
+	objMetadata, err := donut.putObject(entry.Bucket, entry.Object, entry.MD5Sum, newChunksReader(entry.chunks), entry.Metadata, nil)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	objectKey := entry.Bucket + "/" + entry.Object
+	donut.setCachedObjectMetadata(entry.Bucket, objectKey, objMetadata)
+	donut.writeBack.lock.Lock()
+	delete(donut.writeBack.pending, objectKey)
+	donut.writeBack.lock.Unlock()
+	donut.writeBack.remove(entry)
+	return nil
+}
+
+// startWriteBack - take the journal, replay it and flush objects written back from then on. If a previous
+// process still owns the journal, as on a graceful restart, objects are written to the disks right away
+// until it gives the journal up
+func (donut API) startWriteBack() error {
+	acquired, err := donut.writeBack.acquire(false)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if acquired {
+		if err := donut.replayWriteBack(); err != nil {
+			return iodine.New(err, nil)
+		}
+		go donut.flushWriteBack()
+		return nil
+	}
+	log.Printf("Write back journal %s is owned by another process, writing objects right away until it is released", donut.writeBack.dir)
+	go func() {
+		if _, err := donut.writeBack.acquire(true); err != nil {
+			log.Printf("Failed to take write back journal %s: %s", donut.writeBack.dir, iodine.ToError(err))
+			return
+		}
+		if err := donut.replayWriteBack(); err != nil {
+			log.Printf("Failed to replay write back journal %s: %s", donut.writeBack.dir, iodine.ToError(err))
+		}
+		donut.flushWriteBack()
+	}()
+	return nil
+}
+
+// replayWriteBack - write the objects journaled by a previous process to the disks, before any new ones.
+// Objects which can not be written stay journaled, they are queued to be flushed like new ones
+func (donut API) replayWriteBack() error {
+	entries, err := donut.writeBack.readJournal()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, entry := range entries {
+		objectKey := entry.Bucket + "/" + entry.Object
+		if _, err := donut.putObject(entry.Bucket, entry.Object, entry.MD5Sum, newChunksReader(entry.chunks), entry.Metadata, nil); err != nil {
+			log.Printf("Failed to replay write back journal entry %s for %s: %s", entry.name, objectKey, iodine.ToError(err))
+			donut.writeBack.enqueue(entry)
+			continue
+		}
+		// an earlier version which failed is superseded
+		donut.writeBack.lock.Lock()
+		delete(donut.writeBack.pending, objectKey)
+		donut.writeBack.lock.Unlock()
+		donut.writeBack.remove(entry)
+	}
+	return nil
+}
+
+// waitWriteBack - wait until all queued objects are written to the disks, fails if any of them could not be
+func (donut API) waitWriteBack() error {
+	donut.writeBack.lock.Lock()
+	defer donut.writeBack.lock.Unlock()
+	for donut.writeBack.unflushed > 0 {
+		donut.writeBack.flushed.Wait()
+	}
+	if len(donut.writeBack.pending) > 0 {
+		var objects []string
+		for objectKey := range donut.writeBack.pending {
+			objects = append(objects, objectKey)
+		}
+		sort.Strings(objects)
+		return iodine.New(WriteBackFailed{Objects: objects}, nil)
+	}
+	return nil
+}
+
+// newChunksReader - read chunks one after another
+func newChunksReader(chunks [][]byte) io.Reader {
+	readers := make([]io.Reader, len(chunks))
+	for i, chunk := range chunks {
+		readers[i] = bytes.NewReader(chunk)
+	}
+	return io.MultiReader(readers...)
+}
@@ -37,6 +37,10 @@ import (
 // blocks.
 //
 // "dataLen" is the length of original source data
+//
+// If the data blocks are consecutive slices of one buffer, decodedData is a
+// slice of that buffer rather than a copy. It is valid for as long as the caller
+// holds on to the buffer, a buffer handed back to a pool must not be read from.
 func (e *Erasure) Decode(encodedDataBlocks [][]byte, dataLen int) (decodedData []byte, err error) {
 	var source, target **C.uchar
 
@@ -112,6 +116,21 @@ func (e *Erasure) Decode(encodedDataBlocks [][]byte, dataLen int) (decodedData [
 	C.ec_encode_data(C.int(encodedBlockLen), C.int(k), C.int(missingEncodedBlocksCount-1), e.decodeTbls,
 		source, target)
 
+	// Data blocks laid out back to back in a single buffer need no copying
+	if cap(encodedDataBlocks[0]) >= encodedBlockLen*k {
+		contiguous := encodedDataBlocks[0][:encodedBlockLen*k]
+		isContiguous := true
+		for i := 1; i < k; i++ {
+			if &encodedDataBlocks[i][0] != &contiguous[i*encodedBlockLen] {
+				isContiguous = false
+				break
+			}
+		}
+		if isContiguous {
+			return contiguous[:dataLen], nil
+		}
+	}
+
 	// Allocate buffer to output buffer
 	decodedData = make([]byte, 0, encodedBlockLen*int(k))
 	for i := 0; i < int(k); i++ {
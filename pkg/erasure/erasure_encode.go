@@ -138,6 +138,9 @@ func GetEncodedBlockLen(inputLen int, k uint8) (encodedOutputLen int) {
 
 // Encode erasure codes a block of data in "k" data blocks and "m" parity blocks.
 // Output is [k+m][]blocks of data and parity slices.
+//
+// If inputData has enough spare capacity, see GetEncodedBlocksLen(), padding and
+// parity blocks are written into it and the returned blocks share its memory.
 func (e *Erasure) Encode(inputData []byte) (encodedBlocks [][]byte, err error) {
 	k := int(e.params.K) // "k" data blocks
 	m := int(e.params.M) // "m" parity blocks
@@ -150,21 +153,23 @@ func (e *Erasure) Encode(inputData []byte) (encodedBlocks [][]byte, err error) {
 	// Length of total number of "k" data chunks
 	encodedDataBlocksLen := encodedBlockLen * k
 
-	// Length of extra padding required for the data blocks.
-	encodedDataBlocksPadLen := encodedDataBlocksLen - len(inputData)
-
-	// Extend inputData buffer to accommodate coded data blocks if necesssary
-	if encodedDataBlocksPadLen > 0 {
-		padding := make([]byte, encodedDataBlocksPadLen)
-		// Expand with new padded blocks to the byte array
-		inputData = append(inputData, padding...)
-	}
-
-	// Extend inputData buffer to accommodate coded parity blocks
-	{ // Local Scope
-		encodedParityBlocksLen := encodedBlockLen * m
-		parityBlocks := make([]byte, encodedParityBlocksLen)
-		inputData = append(inputData, parityBlocks...)
+	// Length of all "k" data and "m" parity blocks
+	encodedBlocksLen := encodedBlockLen * n
+
+	// Lay out data, padding and parity blocks in one buffer. Spare capacity of
+	// inputData is used in place, otherwise a single buffer is allocated.
+	if cap(inputData) >= encodedBlocksLen {
+		inputLen := len(inputData)
+		inputData = inputData[:encodedBlocksLen]
+		// Padding must be zeroed, parity blocks are overwritten by the encoder
+		padding := inputData[inputLen:encodedDataBlocksLen]
+		for i := range padding {
+			padding[i] = 0
+		}
+	} else {
+		encodedData := make([]byte, encodedBlocksLen)
+		copy(encodedData, inputData)
+		inputData = encodedData
 	}
 
 	// Allocate memory to the "encoded blocks" return buffer
@@ -174,18 +179,12 @@ func (e *Erasure) Encode(inputData []byte) (encodedBlocks [][]byte, err error) {
 	// byte array. "encodedBlocks" is a 2D slice.
 	pointersToEncodedBlock := make([]*byte, n) // Pointers to encoded blocks.
 
-	// Copy data block slices to encoded block buffer
-	for i := 0; i < k; i++ {
+	// Slice data and parity blocks out of the encoded buffer
+	for i := 0; i < n; i++ {
 		encodedBlocks[i] = inputData[i*encodedBlockLen : (i+1)*encodedBlockLen]
 		pointersToEncodedBlock[i] = &encodedBlocks[i][0]
 	}
 
-	// Copy erasure block slices to encoded block buffer
-	for i := k; i < n; i++ {
-		encodedBlocks[i] = make([]byte, encodedBlockLen)
-		pointersToEncodedBlock[i] = &encodedBlocks[i][0]
-	}
-
 	// Erasure code the data into K data blocks and M parity
 	// blocks. Only the parity blocks are filled. Data blocks remain
 	// intact.
@@ -0,0 +1,77 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package erasure
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/minio/check"
+)
+
+func (s *MySuite) TestEncodeDecodeInPlace(c *C) {
+	ep, _ := ValidateParams(k, m, Cauchy)
+	e := NewErasure(ep)
+
+	data := []byte("Lorem Ipsum is simply dummy text of the printing and typesetting industry.")
+	buffer := make([]byte, len(data), GetEncodedBlocksLen(len(data), k, m))
+	copy(buffer, data)
+
+	chunks, err := e.Encode(buffer)
+	c.Assert(err, IsNil)
+	c.Assert(&chunks[0][0], Equals, &buffer[0])
+	c.Assert(&chunks[k+m-1][0], Equals, &buffer[:cap(buffer)][GetEncodedBlockLen(len(data), k)*(k+m-1)])
+
+	// all data blocks intact, decoding hands back the original buffer
+	decodedData, err := e.Decode(chunks, len(data))
+	c.Assert(err, IsNil)
+	c.Assert(&decodedData[0], Equals, &buffer[0])
+	c.Assert(bytes.Equal(decodedData, data), Equals, true)
+
+	// lost data blocks are reconstructed into a fresh buffer, decode tables
+	// are cached per erasure so a new one is needed for this set of losses
+	chunks = corruptChunks(chunks, []int{0, 3})
+	decodedData, err = NewErasure(ep).Decode(chunks, len(data))
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Equal(decodedData, data), Equals, true)
+}
+
+func benchmarkEncode(b *testing.B, inPlace bool) {
+	ep, _ := ValidateParams(K, M, Cauchy)
+	e := NewErasure(ep)
+	dataLen := 4 * 1024 * 1024
+	data := make([]byte, dataLen)
+	if inPlace {
+		data = make([]byte, dataLen, GetEncodedBlocksLen(dataLen, K, M))
+	}
+	b.SetBytes(int64(dataLen))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := e.Encode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	benchmarkEncode(b, false)
+}
+
+func BenchmarkEncodeInPlace(b *testing.B) {
+	benchmarkEncode(b, true)
+}
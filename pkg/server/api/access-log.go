@@ -0,0 +1,369 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// Access log formats
+const (
+	JSONAccessLog     = "json"
+	CombinedAccessLog = "combined"
+	S3AccessLog       = "s3"
+)
+
+// access log times are formatted like this in the combined and s3 formats
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// rotated access logs are named after the log and the time they were rotated
+const rotatedTimeFormat = "2006-01-02T15-04-05.000000000"
+
+// query parameters carrying secrets, they are never logged
+var redactedQueryParameters = []string{"X-Amz-Signature", "Signature", "X-Amz-Security-Token"}
+
+// s3Operations - operation names of the s3 server access log format, by API name
+var s3Operations = map[string]string{
+	"ListBuckets":             "REST.GET.SERVICE",
+	"ListObjects":             "REST.GET.BUCKET",
+	"PutBucket":               "REST.PUT.BUCKET",
+	"HeadBucket":              "REST.HEAD.BUCKET",
+	"DeleteBucket":            "REST.DELETE.BUCKET",
+	"HeadObject":              "REST.HEAD.OBJECT",
+	"GetObject":               "REST.GET.OBJECT",
+	"PutObject":               "REST.PUT.OBJECT",
+	"PutObjectPart":           "REST.PUT.PART",
+	"ListObjectParts":         "REST.GET.UPLOAD",
+	"NewMultipartUpload":      "REST.POST.UPLOADS",
+	"CompleteMultipartUpload": "REST.POST.UPLOAD",
+	"AbortMultipartUpload":    "REST.DELETE.UPLOAD",
+	"DeleteObject":            "REST.DELETE.OBJECT",
+}
+
+// accessLogEntry - a served request, it is written in the configured format
+type accessLogEntry struct {
+	Time          time.Time     `json:"time"`
+	RemoteHost    string        `json:"remoteHost"`
+	RequestID     string        `json:"requestID,omitempty"`
+	AccessKey     string        `json:"accessKey,omitempty"`
+	API           string        `json:"api"`
+	Method        string        `json:"method"`
+	URI           string        `json:"uri"`
+	Proto         string        `json:"proto"`
+	Bucket        string        `json:"bucket,omitempty"`
+	Object        string        `json:"object,omitempty"`
+	Status        int           `json:"status"`
+	BytesReceived int64         `json:"bytesReceived"`
+	BytesSent     int64         `json:"bytesSent"`
+	Duration      time.Duration `json:"duration"`
+	Referer       string        `json:"referer,omitempty"`
+	UserAgent     string        `json:"userAgent,omitempty"`
+}
+
+// newAccessLogEntry - describe a request served with status, credentials are left out
+func newAccessLogEntry(req *http.Request, w http.ResponseWriter, start time.Time, status int, bytesReceived, bytesSent int64) accessLogEntry {
+	entry := accessLogEntry{
+		Time:          start,
+		RemoteHost:    req.RemoteAddr,
+		RequestID:     w.Header().Get("X-Amz-Request-Id"),
+		AccessKey:     getRequestAccessKey(req),
+		API:           getAPIName(req),
+		Method:        req.Method,
+		URI:           getRedactedRequestURI(req),
+		Proto:         req.Proto,
+		Status:        status,
+		BytesReceived: bytesReceived,
+		BytesSent:     bytesSent,
+		Duration:      time.Now().UTC().Sub(start),
+		Referer:       req.Referer(),
+		UserAgent:     req.UserAgent(),
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		entry.RemoteHost = host
+	}
+	entry.Bucket, entry.Object = getBucketAndObject(req)
+	return entry
+}
+
+// getBucketAndObject - bucket and object a request is for, both empty for requests about the service
+func getBucketAndObject(req *http.Request) (bucket, object string) {
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	if IsMetricsRequest(req) || path == "" {
+		return "", ""
+	}
+	if i := strings.Index(path, "/"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
+
+// getRequestAccessKey - access key a request claims to be signed with, it is logged whether or not
+// the signature matches
+func getRequestAccessKey(req *http.Request) string {
+	if authHeader := req.Header.Get("Authorization"); authHeader != "" {
+		accessKey, err := StripAccessKeyID(authHeader)
+		if err != nil {
+			return ""
+		}
+		return accessKey
+	}
+	credential := req.URL.Query().Get("X-Amz-Credential")
+	if i := strings.Index(credential, "/"); i >= 0 {
+		return credential[:i]
+	}
+	return credential
+}
+
+// getRedactedRequestURI - request URI with signatures and session tokens replaced
+func getRedactedRequestURI(req *http.Request) string {
+	query := req.URL.Query()
+	var redacted bool
+	for _, parameter := range redactedQueryParameters {
+		if _, ok := query[parameter]; ok {
+			query.Set(parameter, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return req.URL.RequestURI()
+	}
+	uri := url.URL{Path: req.URL.Path, RawQuery: query.Encode()}
+	return uri.RequestURI()
+}
+
+// orDash - empty fields of the combined and s3 formats are written as dashes
+func orDash(field string) string {
+	if field == "" {
+		return "-"
+	}
+	return field
+}
+
+// sizeOrDash - zero sizes of the combined and s3 formats are written as dashes
+func sizeOrDash(size int64) string {
+	if size == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(size, 10)
+}
+
+// format - an entry as a line of the access log
+func (entry accessLogEntry) format(format string) []byte {
+	switch format {
+	case CombinedAccessLog:
+		return []byte(fmt.Sprintf("%s - %s [%s] %q %d %s %q %q\n",
+			entry.RemoteHost, orDash(entry.AccessKey), entry.Time.Format(accessLogTimeFormat),
+			entry.Method+" "+entry.URI+" "+entry.Proto, entry.Status, sizeOrDash(entry.BytesSent),
+			orDash(entry.Referer), orDash(entry.UserAgent)))
+	case S3AccessLog:
+		operation, ok := s3Operations[entry.API]
+		if !ok {
+			operation = "REST." + entry.Method + "." + strings.ToUpper(entry.API)
+		}
+		return []byte(fmt.Sprintf("- %s [%s] %s %s %s %s %s %q %d - %s - %d - %q %q -\n",
+			orDash(entry.Bucket), entry.Time.Format(accessLogTimeFormat), entry.RemoteHost,
+			orDash(entry.AccessKey), orDash(entry.RequestID), operation, orDash(url.QueryEscape(entry.Object)),
+			entry.Method+" "+entry.URI+" "+entry.Proto, entry.Status, sizeOrDash(entry.BytesSent),
+			entry.Duration/time.Millisecond, orDash(entry.Referer), orDash(entry.UserAgent)))
+	default:
+		line, _ := json.Marshal(entry)
+		return append(line, '\n')
+	}
+}
+
+// AccessLog writes served requests to a file in one of the access log formats, rotating it once it
+// grows too large or too old
+type AccessLog struct {
+	lock    *sync.Mutex
+	format  string
+	path    string
+	file    *os.File
+	size    int64
+	opened  time.Time
+	maxSize int64
+	maxAge  time.Duration
+	backups int
+	closed  bool
+}
+
+// NewAccessLog - access log as configured, nil if it is disabled
+func NewAccessLog(conf Config) (*AccessLog, error) {
+	if conf.AccessLog == "" {
+		return nil, nil
+	}
+	format := conf.AccessLogFormat
+	switch format {
+	case "":
+		format = JSONAccessLog
+	case JSONAccessLog, CombinedAccessLog, S3AccessLog:
+	default:
+		return nil, iodine.New(fmt.Errorf("Unknown access log format: %s", format), nil)
+	}
+	accessLog := &AccessLog{
+		lock:    new(sync.Mutex),
+		format:  format,
+		path:    conf.AccessLog,
+		maxSize: conf.AccessLogMaxSize,
+		maxAge:  conf.AccessLogMaxAge,
+		backups: conf.AccessLogBackups,
+	}
+	if accessLog.path == "-" {
+		accessLog.file = os.Stdout
+		return accessLog, nil
+	}
+	if err := accessLog.open(); err != nil {
+		return nil, iodine.New(err, map[string]string{"logfile": accessLog.path})
+	}
+	return accessLog, nil
+}
+
+// open - append to the log file, its age counts from now
+func (l *AccessLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	st, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return iodine.New(err, nil)
+	}
+	l.file = file
+	l.size = st.Size()
+	l.opened = time.Now()
+	return nil
+}
+
+// log - write an entry, failures are reported on the error log but never fail the request
+func (l *AccessLog) log(entry accessLogEntry) {
+	line := entry.format(l.format)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return
+	}
+	if l.file != os.Stdout && l.needsRotation(int64(len(line))) {
+		if err := l.rotate(); err != nil {
+			log.Errorln(iodine.New(err, map[string]string{"logfile": l.path}))
+		}
+	}
+	if l.file == nil {
+		return
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		log.Errorln(iodine.New(err, map[string]string{"logfile": l.path}))
+	}
+}
+
+// needsRotation - whether the log is too old or too large to take another length bytes
+func (l *AccessLog) needsRotation(length int64) bool {
+	if l.file == nil {
+		return true
+	}
+	if l.maxAge > 0 && time.Since(l.opened) >= l.maxAge {
+		return true
+	}
+	// a log is never rotated empty, a single line larger than maxSize gets a file of its own
+	return l.maxSize > 0 && l.size > 0 && l.size+length > l.maxSize
+}
+
+// rotate - rename the log after the current time, start a new one and remove the oldest rotated
+// logs beyond backups
+func (l *AccessLog) rotate() error {
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+		rotated := l.path + "." + time.Now().UTC().Format(rotatedTimeFormat)
+		if err := os.Rename(l.path, rotated); err != nil && !os.IsNotExist(err) {
+			return iodine.New(err, nil)
+		}
+	}
+	if err := l.open(); err != nil {
+		return iodine.New(err, nil)
+	}
+	if l.backups <= 0 {
+		return nil
+	}
+	rotated, err := l.listRotated()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for len(rotated) > l.backups {
+		os.Remove(rotated[0])
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// listRotated - logs rotated from path in the order they were rotated, other files next to it are left alone
+func (l *AccessLog) listRotated() ([]string, error) {
+	dir, err := os.Open(filepath.Dir(l.path))
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	prefix := filepath.Base(l.path) + "."
+	var rotated []string
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if _, err := time.Parse(rotatedTimeFormat, strings.TrimPrefix(name, prefix)); err != nil {
+			continue
+		}
+		rotated = append(rotated, filepath.Join(filepath.Dir(l.path), name))
+	}
+	// rotation times sort in the order the logs were rotated
+	sort.Strings(rotated)
+	return rotated, nil
+}
+
+// Close - close the log file, requests served from here on are not logged
+func (l *AccessLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.closed = true
+	if l.file == nil || l.file == os.Stdout {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
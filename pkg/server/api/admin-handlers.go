@@ -0,0 +1,138 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/auth"
+	"github.com/minio/minio/pkg/server/rpc"
+)
+
+// adminServices - RPC services managing users, groups and policies, their calls need admin credentials
+var adminServices = map[string]bool{
+	"User":   true,
+	"Group":  true,
+	"Policy": true,
+}
+
+// admin requests signed further than this from now are refused, they could be replayed
+const maxAdminRequestSkew = 5 * time.Minute
+
+// authorizeAdmin - whether a request is signed with an auth header over payload, recently, by a user
+// allowed the admin action
+func authorizeAdmin(req *http.Request, payload []byte, action string) bool {
+	if req.Header.Get("Authorization") == "" {
+		return false
+	}
+	date, err := parseDate(req)
+	if err != nil {
+		return false
+	}
+	if skew := time.Since(date); skew > maxAdminRequestSkew || skew < -maxAdminRequestSkew {
+		return false
+	}
+	a := getRequestAuth(req)
+	if a.err != nil {
+		return false
+	}
+	sum := sha256.Sum256(payload)
+	ok, err := a.signature.DoesSignatureMatch(hex.EncodeToString(sum[:]))
+	if err != nil || !ok {
+		return false
+	}
+	return a.config.IsAllowed(a.signature.AccessKeyID, action, auth.ServiceResource)
+}
+
+// AdminRPCHandler - calls of the RPC services managing users, groups and policies must be signed like
+// S3 requests, over their body, by a user allowed admin:<Service.Method>. Other calls pass unchecked
+func AdminRPCHandler(h http.Handler) http.Handler {
+	return adminRPCHandler{h}
+}
+
+type adminRPCHandler struct {
+	handler http.Handler
+}
+
+func (h adminRPCHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var content []byte
+	if req.Body != nil {
+		var err error
+		// the RPC server refuses larger bodies
+		content, err = ioutil.ReadAll(io.LimitReader(req.Body, rpc.MaxRequestSize+1))
+		req.Body.Close()
+		if err != nil {
+			writeRPCError(w, nil, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(content))
+	}
+	// decoded like the RPC server does, calls it can not decode are refused by it
+	var call struct {
+		Method string           `json:"method"`
+		ID     *json.RawMessage `json:"id"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(content)).Decode(&call); err != nil {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+	service := strings.SplitN(call.Method, ".", 2)[0]
+	if adminServices[service] && !authorizeAdmin(req, content, "admin:"+call.Method) {
+		writeRPCError(w, call.ID, http.StatusForbidden, "Access Denied")
+		return
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// AdminHandler - requests must be signed like S3 requests by a user allowed the admin action, others
+// are refused with AccessDenied
+func AdminHandler(action string, h http.Handler) http.Handler {
+	return adminHandler{action, h}
+}
+
+type adminHandler struct {
+	action  string
+	handler http.Handler
+}
+
+func (h adminHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// admin requests carry no body, their signature covers the empty payload
+	if !authorizeAdmin(req, nil, h.action) {
+		writeErrorResponse(w, req, AccessDenied, getContentType(req), req.URL.Path)
+		return
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// writeRPCError - reply to a call with an error like the RPC server does
+func writeRPCError(w http.ResponseWriter, id *json.RawMessage, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Result interface{}      `json:"result"`
+		Error  string           `json:"error"`
+		ID     *json.RawMessage `json:"id"`
+	}{nil, message, id})
+}
@@ -16,17 +16,40 @@
 
 package api
 
-import "github.com/minio/minio/pkg/donut"
+import (
+	"net/http"
+
+	"github.com/minio/minio/pkg/donut"
+)
+
+// OperationType type of an operation, Ticket Master queues each type separately
+type OperationType int
+
+// Operation types
+const (
+	ReadOperation OperationType = iota
+	WriteOperation
+	ListOperation
+)
 
 // Operation container for individual operations read by Ticket Master
 type Operation struct {
-	ProceedCh chan struct{}
+	Type      OperationType
+	AccessKey string
+	// true once admitted, false if it timed out waiting in queue
+	ProceedCh chan bool
 }
 
-// Minio container for API and also carries OP (operation) channel
+// Minio container for API and also carries OP (operation) channels
 type Minio struct {
-	OP    chan Operation
-	Donut donut.Interface
+	OP      chan Operation
+	DoneOP  chan Operation
+	Donut   donut.Interface
+	Metrics *Metrics
+	// AccessLog and AuditLog are nil if disabled
+	AccessLog *AccessLog
+	AuditLog  *AuditLog
+	Tracer    *Tracer
 }
 
 // New instantiate a new minio API
@@ -37,7 +60,32 @@ func New() Minio {
 		panic(err)
 	}
 	return Minio{
-		OP:    make(chan Operation),
-		Donut: d,
+		OP:      make(chan Operation),
+		DoneOP:  make(chan Operation),
+		Donut:   d,
+		Metrics: newMetrics(),
+		Tracer:  NewTracer(),
+	}
+}
+
+// waitForTicket - queue an operation with Ticket Master and block until it is admitted, replies
+// with SlowDown if the operation timed out waiting in queue
+func (api Minio) waitForTicket(w http.ResponseWriter, req *http.Request, opType OperationType) (Operation, bool) {
+	op := Operation{}
+	op.Type = opType
+	// anonymous requests and requests with forged signatures share a single queue
+	op.AccessKey = getVerifiedAccessKey(req)
+	op.ProceedCh = make(chan bool)
+	api.OP <- op
+	// block until ticket master gives us a go
+	if proceed := <-op.ProceedCh; !proceed {
+		writeErrorResponse(w, req, SlowDown, getContentType(req), req.URL.Path)
+		return op, false
 	}
+	return op, true
+}
+
+// releaseTicket - let Ticket Master know an admitted operation is done
+func (api Minio) releaseTicket(op Operation) {
+	api.DoneOP <- op
 }
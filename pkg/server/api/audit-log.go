@@ -0,0 +1,342 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/server/rpc"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// the first record of an audit log chains to this hash
+var auditGenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// auditRecord - who did what and how it ended, every record carries the hash of the record before it
+type auditRecord struct {
+	Time          time.Time `json:"time"`
+	AccessKey     string    `json:"accessKey,omitempty"`
+	SourceIP      string    `json:"sourceIP"`
+	RequestID     string    `json:"requestID,omitempty"`
+	API           string    `json:"api"`
+	Bucket        string    `json:"bucket,omitempty"`
+	Object        string    `json:"object,omitempty"`
+	Status        int       `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	BytesReceived int64     `json:"bytesReceived"`
+	BytesSent     int64     `json:"bytesSent"`
+	PrevHash      string    `json:"prevHash"`
+	Hash          string    `json:"hash,omitempty"`
+}
+
+// hash - hash of a record, covering all its fields and through PrevHash all records before it
+func (record auditRecord) hash() (string, error) {
+	record.Hash = ""
+	content, err := json.Marshal(record)
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditLog keeps a record of every mutating and administrative operation in a file. Records are
+// chained by their hashes, changing or removing any of them breaks the chain from there on
+type AuditLog struct {
+	lock     *sync.Mutex
+	file     *os.File
+	lastHash string
+}
+
+// NewAuditLog - audit log as configured, nil if it is disabled. Records are appended to an existing log
+func NewAuditLog(conf Config) (*AuditLog, error) {
+	if conf.AuditLog == "" {
+		return nil, nil
+	}
+	lastHash, err := verifyAuditLog(conf.AuditLog)
+	if err != nil && !os.IsNotExist(iodine.ToError(err)) {
+		return nil, iodine.New(err, map[string]string{"auditlog": conf.AuditLog})
+	}
+	file, err := os.OpenFile(conf.AuditLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, iodine.New(err, map[string]string{"auditlog": conf.AuditLog})
+	}
+	return &AuditLog{
+		lock:     new(sync.Mutex),
+		file:     file,
+		lastHash: lastHash,
+	}, nil
+}
+
+// VerifyAuditLog - check no record of an audit log was changed, removed or inserted
+func VerifyAuditLog(path string) error {
+	_, err := verifyAuditLog(path)
+	return err
+}
+
+// verifyAuditLog - check the chain of records, returns the hash of the last one
+func verifyAuditLog(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return auditGenesisHash, iodine.New(err, nil)
+	}
+	defer file.Close()
+	lastHash := auditGenesisHash
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var record auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return "", iodine.New(fmt.Errorf("Damaged audit record on line %d", line), nil)
+		}
+		hash, err := record.hash()
+		if err != nil {
+			return "", iodine.New(err, nil)
+		}
+		if record.PrevHash != lastHash || record.Hash != hash {
+			return "", iodine.New(fmt.Errorf("Audit chain broken on line %d", line), nil)
+		}
+		lastHash = record.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	return lastHash, nil
+}
+
+// log - chain a record to the log and sync it to disk
+func (l *AuditLog) log(record auditRecord) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file == nil {
+		return iodine.New(fmt.Errorf("Audit log is closed"), nil)
+	}
+	record.PrevHash = l.lastHash
+	hash, err := record.hash()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	record.Hash = hash
+	line, err := json.Marshal(record)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := l.file.Sync(); err != nil {
+		return iodine.New(err, nil)
+	}
+	l.lastHash = hash
+	return nil
+}
+
+// Close - close the log file, audited requests fail from here on
+func (l *AuditLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// auditWriter holds back a response until it is audited
+type auditWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+// auditHandler - record a request once it is served, its response is held back until the record is
+// written. A change the request made is not undone if the record can not be written, the client is told
+// the request failed and the change goes unaudited but logged as an error
+type auditHandler struct {
+	handler  http.Handler
+	auditLog *AuditLog
+	// describe starts the record of a request with what it calls and on what, false if it is not audited
+	describe func(req *http.Request) (auditRecord, bool)
+	// result - error a response reports, empty on success
+	result func(status int, body []byte) string
+	// fail - respond to a request which could not be audited
+	fail func(w http.ResponseWriter, req *http.Request)
+}
+
+func (h auditHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if h.auditLog == nil {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+	record, audited := h.describe(req)
+	if !audited {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+	var body *countingReader
+	if req.Body != nil {
+		body = &countingReader{ReadCloser: req.Body}
+		req.Body = body
+	}
+	// handlers see the headers set so far, the request ID among them
+	auditWriter := &auditWriter{header: make(http.Header)}
+	for key, values := range w.Header() {
+		auditWriter.header[key] = values
+	}
+	h.handler.ServeHTTP(auditWriter, req)
+	if auditWriter.status == 0 {
+		auditWriter.status = http.StatusOK
+	}
+
+	record.Time = time.Now().UTC()
+	record.SourceIP = req.RemoteAddr
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		record.SourceIP = host
+	}
+	record.RequestID = getRequestID(w)
+	record.Status = auditWriter.status
+	record.Error = h.result(auditWriter.status, auditWriter.body.Bytes())
+	record.BytesSent = int64(auditWriter.body.Len())
+	if body != nil {
+		record.BytesReceived = body.count
+	}
+	// nothing is acknowledged unless it is audited, though what the request changed stands
+	if err := h.auditLog.log(record); err != nil {
+		// the error log is all that is left of the change
+		errorState := requestErrorState(w)
+		errorState["api"] = record.API
+		errorState["bucket"] = record.Bucket
+		errorState["object"] = record.Object
+		log.Error.Println(iodine.New(err, errorState))
+		h.fail(w, req)
+		return
+	}
+	for key, values := range auditWriter.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(auditWriter.status)
+	w.Write(auditWriter.body.Bytes())
+}
+
+// AuditHandler - audit every request which changes buckets or objects, reads are not audited
+func (api Minio) AuditHandler(h http.Handler) http.Handler {
+	return auditHandler{
+		handler:  h,
+		auditLog: api.AuditLog,
+		describe: func(req *http.Request) (auditRecord, bool) {
+			if !isWriteRequest(req) {
+				return auditRecord{}, false
+			}
+			record := auditRecord{API: getAPIName(req), AccessKey: getVerifiedAccessKey(req)}
+			record.Bucket, record.Object = getBucketAndObject(req)
+			return record, true
+		},
+		result: func(status int, body []byte) string {
+			if status < http.StatusMultipleChoices {
+				return ""
+			}
+			var errorResponse ErrorResponse
+			if err := xml.Unmarshal(body, &errorResponse); err == nil && errorResponse.Code != "" {
+				return errorResponse.Code
+			}
+			if err := json.Unmarshal(body, &errorResponse); err == nil && errorResponse.Code != "" {
+				return errorResponse.Code
+			}
+			return http.StatusText(status)
+		},
+		fail: func(w http.ResponseWriter, req *http.Request) {
+			writeErrorResponse(w, req, InternalError, getContentType(req), req.URL.Path)
+		},
+	}
+}
+
+// AuditRPCHandler - audit every RPC call, they are recorded by the method they call. Like requests,
+// calls which change something are not undone when their record can not be written
+func AuditRPCHandler(auditLog *AuditLog, h http.Handler) http.Handler {
+	return auditHandler{
+		handler:  h,
+		auditLog: auditLog,
+		describe: func(req *http.Request) (auditRecord, bool) {
+			// RPC calls are told apart by the method they call, admin calls carry the key they are signed with
+			record := auditRecord{API: "RPC", AccessKey: getVerifiedAccessKey(req)}
+			if req.Body == nil {
+				return record, true
+			}
+			// the RPC server refuses larger bodies, reading past the limit tells them apart
+			content, err := ioutil.ReadAll(io.LimitReader(req.Body, rpc.MaxRequestSize+1))
+			req.Body.Close()
+			req.Body = ioutil.NopCloser(bytes.NewReader(content))
+			var call struct {
+				Method string `json:"method"`
+			}
+			if err == nil && json.Unmarshal(content, &call) == nil && call.Method != "" {
+				record.API = call.Method
+			}
+			return record, true
+		},
+		result: func(status int, body []byte) string {
+			var reply struct {
+				Error interface{} `json:"error"`
+			}
+			if err := json.Unmarshal(body, &reply); err == nil && reply.Error != nil {
+				return fmt.Sprint(reply.Error)
+			}
+			if status >= http.StatusMultipleChoices {
+				return http.StatusText(status)
+			}
+			return ""
+		},
+		fail: func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+	}
+}
@@ -58,7 +58,7 @@ func (api Minio) isValidOp(w http.ResponseWriter, req *http.Request, acceptsCont
 		}
 	default:
 		{
-			log.Error.Println(iodine.New(err, nil))
+			log.Error.Println(iodine.New(err, requestErrorState(w)))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 		}
 	}
@@ -73,14 +73,12 @@ func (api Minio) isValidOp(w http.ResponseWriter, req *http.Request, acceptsCont
 // This operation returns at most 1,000 multipart uploads in the response.
 //
 func (api Minio) ListMultipartUploadsHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, ListOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	if !api.isValidOp(w, req, acceptsContentType) {
@@ -123,7 +121,7 @@ func (api Minio) ListMultipartUploadsHandler(w http.ResponseWriter, req *http.Re
 	case donut.BucketNotFound:
 		writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
@@ -132,17 +130,16 @@ func (api Minio) ListMultipartUploadsHandler(w http.ResponseWriter, req *http.Re
 // -------------------------
 // This implementation of the GET operation returns some or all (up to 1000)
 // of the objects in a bucket. You can use the request parameters as selection
-// criteria to return a subset of the objects in a bucket.
+// criteria to return a subset of the objects in a bucket. Requests carrying
+// list-type=2 are served as version 2 of the API.
 //
 func (api Minio) ListObjectsHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, ListOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	if !api.isValidOp(w, req, acceptsContentType) {
@@ -158,6 +155,24 @@ func (api Minio) ListObjectsHandler(w http.ResponseWriter, req *http.Request) {
 	if resources.Maxkeys == 0 {
 		resources.Maxkeys = maxObjectList
 	}
+	// url is the only encoding type defined
+	if resources.EncodingType != "" && resources.EncodingType != "url" {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	// list objects version 2 continues after an opaque continuation token, start-after is
+	// honored only on the first request
+	if resources.ListType == 2 {
+		resources.Marker = resources.StartAfter
+		if resources.ContinuationToken != "" {
+			marker, err := getContinuationMarker(resources.ContinuationToken)
+			if err != nil {
+				writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+				return
+			}
+			resources.Marker = marker
+		}
+	}
 
 	vars := mux.Vars(req)
 	bucket := vars["bucket"]
@@ -177,8 +192,15 @@ func (api Minio) ListObjectsHandler(w http.ResponseWriter, req *http.Request) {
 	switch iodine.ToError(err).(type) {
 	case nil:
 		// generate response
-		response := generateListObjectsResponse(bucket, objects, resources)
-		encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+		var encodedSuccessResponse []byte
+		switch resources.ListType {
+		case 2:
+			response := generateListObjectsV2Response(bucket, objects, resources)
+			encodedSuccessResponse = encodeSuccessResponse(response, acceptsContentType)
+		default:
+			response := generateListObjectsResponse(bucket, objects, resources)
+			encodedSuccessResponse = encodeSuccessResponse(response, acceptsContentType)
+		}
 		// write headers
 		setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
 		// write body
@@ -194,7 +216,7 @@ func (api Minio) ListObjectsHandler(w http.ResponseWriter, req *http.Request) {
 	case donut.ObjectNameInvalid:
 		writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
@@ -204,14 +226,12 @@ func (api Minio) ListObjectsHandler(w http.ResponseWriter, req *http.Request) {
 // This implementation of the GET operation returns a list of all buckets
 // owned by the authenticated sender of the request.
 func (api Minio) ListBucketsHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, ListOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	// uncomment this when we have webcli
@@ -245,7 +265,7 @@ func (api Minio) ListBucketsHandler(w http.ResponseWriter, req *http.Request) {
 	case donut.SignatureDoesNotMatch:
 		writeErrorResponse(w, req, SignatureDoesNotMatch, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
@@ -254,14 +274,12 @@ func (api Minio) ListBucketsHandler(w http.ResponseWriter, req *http.Request) {
 // ----------
 // This implementation of the PUT operation creates a new bucket for authenticated request
 func (api Minio) PutBucketHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, WriteOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	// uncomment this when we have webcli
@@ -311,7 +329,7 @@ func (api Minio) PutBucketHandler(w http.ResponseWriter, req *http.Request) {
 	case donut.BucketExists:
 		writeErrorResponse(w, req, BucketAlreadyExists, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
@@ -320,14 +338,12 @@ func (api Minio) PutBucketHandler(w http.ResponseWriter, req *http.Request) {
 // ----------
 // This implementation of the PUT operation modifies the bucketACL for authenticated request
 func (api Minio) PutBucketACLHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, WriteOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 
@@ -363,7 +379,7 @@ func (api Minio) PutBucketACLHandler(w http.ResponseWriter, req *http.Request) {
 	case donut.BucketNotFound:
 		writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
@@ -375,14 +391,12 @@ func (api Minio) PutBucketACLHandler(w http.ResponseWriter, req *http.Request) {
 // have permission to access it. Otherwise, the operation might
 // return responses such as 404 Not Found and 403 Forbidden.
 func (api Minio) HeadBucketHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, ReadOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 
@@ -411,7 +425,7 @@ func (api Minio) HeadBucketHandler(w http.ResponseWriter, req *http.Request) {
 	case donut.BucketNameInvalid:
 		writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
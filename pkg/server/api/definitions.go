@@ -16,7 +16,10 @@
 
 package api
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"time"
+)
 
 // Config - http server config
 type Config struct {
@@ -25,6 +28,23 @@ type Config struct {
 	CertFile  string
 	KeyFile   string
 	RateLimit int
+
+	// Ticket Master admission control, zero values disable the limit and the queue timeout
+	MaxOperations int
+	QueueTimeout  time.Duration
+
+	// Access log file, empty disables it and "-" is the standard output. Its format is one of "json",
+	// "combined" or "s3". It is rotated once larger than AccessLogMaxSize bytes or older than
+	// AccessLogMaxAge, keeping AccessLogBackups rotated logs, zero values never rotate and keep all
+	AccessLog        string
+	AccessLogFormat  string
+	AccessLogMaxSize int64
+	AccessLogMaxAge  time.Duration
+	AccessLogBackups int
+
+	// Audit log file, empty disables it. Mutating requests and RPC calls are answered once their record
+	// is synced to it, a log whose hash chain is broken is refused on start
+	AuditLog string
 }
 
 // Limit number of objects in a given response
@@ -62,6 +82,37 @@ type ListObjectsResponse struct {
 	Prefix     string
 }
 
+// ListObjectsV2Response - format for list objects version 2 response
+type ListObjectsV2Response struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult" json:"-"`
+
+	CommonPrefixes []*CommonPrefix
+	Contents       []*Object
+
+	Delimiter string
+
+	// Encoding type used to encode object keys in the response.
+	EncodingType string
+
+	// A flag that indicates whether or not ListObjects returned all of the results
+	// that satisfied the search criteria.
+	IsTruncated bool
+	MaxKeys     int
+	Name        string
+	Prefix      string
+
+	// Number of keys and common prefixes returned in the response.
+	KeyCount int
+
+	// Opaque token sent in the request, when the response is truncated (the IsTruncated
+	// element value in the response is true), NextContinuationToken is sent as
+	// continuation-token in the subsequent request to get the next set of object keys.
+	ContinuationToken     string `xml:",omitempty" json:",omitempty"`
+	NextContinuationToken string `xml:",omitempty" json:",omitempty"`
+
+	StartAfter string `xml:",omitempty" json:",omitempty"`
+}
+
 // Part container for part metadata
 type Part struct {
 	PartNumber   int
@@ -149,7 +200,8 @@ type Object struct {
 	LastModified string
 	Size         int64
 
-	Owner Owner
+	// Not returned by list objects version 2 unless fetch-owner is requested.
+	Owner *Owner `xml:",omitempty" json:",omitempty"`
 
 	// The class of storage used to store the object.
 	StorageClass string
@@ -173,6 +225,39 @@ type InitiateMultipartUploadResponse struct {
 	UploadID string `xml:"UploadId"`
 }
 
+// AssumeRoleResponse container for temporary credentials minted by AssumeRole
+type AssumeRoleResponse struct {
+	XMLName xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ AssumeRoleResponse" json:"-"`
+
+	AssumeRoleResult AssumeRoleResult
+	ResponseMetadata ResponseMetadata
+}
+
+// AssumeRoleResult container for AssumeRole result
+type AssumeRoleResult struct {
+	Credentials     Credentials
+	AssumedRoleUser AssumedRoleUser
+}
+
+// Credentials container for temporary credentials
+type Credentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      string
+}
+
+// AssumedRoleUser container for the user a role was assumed by
+type AssumedRoleUser struct {
+	Arn           string
+	AssumedRoleID string `xml:"AssumedRoleId"`
+}
+
+// ResponseMetadata container for request metadata of STS responses
+type ResponseMetadata struct {
+	RequestID string `xml:"RequestId"`
+}
+
 // CompleteMultipartUploadResponse container for completed multipart upload response
 type CompleteMultipartUploadResponse struct {
 	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult" json:"-"`
@@ -65,11 +65,19 @@ const (
 	MethodNotAllowed
 	InvalidPart
 	InvalidPartOrder
+	PreconditionFailed
+	MetadataTooLarge
+	InvalidArgument
+	SlowDown
+	ExpiredToken
+	InvalidToken
+	InvalidParameterValue
+	MalformedPolicyDocument
 )
 
 // Error codes, non exhaustive list - standard HTTP errors
 const (
-	NotAcceptable = iota + 25
+	NotAcceptable = iota + 33
 )
 
 // Error code to Error structure map
@@ -129,6 +137,11 @@ var errorCodeResponse = map[int]Error{
 		Description:    "The requested range cannot be satisfied.",
 		HTTPStatusCode: http.StatusRequestedRangeNotSatisfiable,
 	},
+	InvalidRequest: {
+		Code:           "InvalidRequest",
+		Description:    "The request is not valid, please verify your request parameters.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 	MalformedXML: {
 		Code:           "MalformedXML",
 		Description:    "The XML you provided was not well-formed or did not validate against our published schema.",
@@ -199,6 +212,46 @@ var errorCodeResponse = map[int]Error{
 		Description:    "The list of parts was not in ascending order. The parts list must be specified in order by part number.",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	PreconditionFailed: {
+		Code:           "PreconditionFailed",
+		Description:    "At least one of the preconditions you specified did not hold.",
+		HTTPStatusCode: http.StatusPreconditionFailed,
+	},
+	MetadataTooLarge: {
+		Code:           "MetadataTooLarge",
+		Description:    "Your metadata headers exceed the maximum allowed metadata size.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	InvalidArgument: {
+		Code:           "InvalidArgument",
+		Description:    "One or more of the specified arguments are not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	SlowDown: {
+		Code:           "SlowDown",
+		Description:    "Please reduce your request rate.",
+		HTTPStatusCode: http.StatusServiceUnavailable,
+	},
+	ExpiredToken: {
+		Code:           "ExpiredToken",
+		Description:    "The provided token has expired.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	InvalidToken: {
+		Code:           "InvalidToken",
+		Description:    "The provided token is malformed or otherwise invalid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	InvalidParameterValue: {
+		Code:           "InvalidParameterValue",
+		Description:    "An invalid or out-of-range value was supplied for the input parameter.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	MalformedPolicyDocument: {
+		Code:           "MalformedPolicyDocument",
+		Description:    "The policy document is malformed.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 }
 
 // errorCodeError provides errorCode to Error. It returns empty if the code provided is unknown
@@ -206,18 +259,17 @@ func getErrorCode(code int) Error {
 	return errorCodeResponse[code]
 }
 
-// getErrorResponse gets in standard error and resource value and
+// getErrorResponse gets in standard error, resource and request ID value and
 // provides a encodable populated response values
-func getErrorResponse(err Error, resource string) ErrorResponse {
+func getErrorResponse(err Error, resource, requestID string) ErrorResponse {
 	var data = ErrorResponse{}
 	data.Code = err.Code
 	data.Message = err.Description
 	if resource != "" {
 		data.Resource = resource
 	}
-	// TODO implement this in future
-	data.RequestID = "3L137"
-	data.HostID = "3L137"
+	data.RequestID = requestID
+	data.HostID = hostID
 
 	return data
 }
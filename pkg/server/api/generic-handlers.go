@@ -17,11 +17,15 @@
 package api
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"net/http"
+	"os"
+	"strings"
 	"time"
-
-	"github.com/minio/minio/pkg/auth"
 )
 
 type contentTypeHandler struct {
@@ -40,6 +44,10 @@ type resourceHandler struct {
 	handler http.Handler
 }
 
+type requestIDHandler struct {
+	handler http.Handler
+}
+
 const (
 	iso8601Format = "20060102T150405Z"
 )
@@ -119,8 +127,10 @@ func (h timeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // ValidateAuthHeaderHandler -
-// validate auth header handler is wrapper handler used for API request validation with authorization header.
-// Current authorization layer supports S3's standard HMAC based signature request.
+// validate auth header handler is wrapper handler used for API request validation with authorization header
+// or presigned query. Current authorization layer supports S3's standard HMAC based signature request,
+// requests of disabled users, of sessions without their valid token and requests their policies do not
+// allow are refused here.
 func ValidateAuthHeaderHandler(h http.Handler) http.Handler {
 	return validateAuthHandler{h}
 }
@@ -128,26 +138,46 @@ func ValidateAuthHeaderHandler(h http.Handler) http.Handler {
 // validate auth header handler ServeHTTP() wrapper
 func (h validateAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	acceptsContentType := getContentType(r)
-	accessKeyID, err := StripAccessKeyID(r.Header.Get("Authorization"))
-	switch err.(type) {
-	case nil:
-		// load auth config
-		authConfig, err := auth.LoadConfig()
-		if err != nil {
-			writeErrorResponse(w, r, InternalError, acceptsContentType, r.URL.Path)
-			return
-		}
-		// Access key not found
-		if _, ok := authConfig.Users[accessKeyID]; !ok {
-			writeErrorResponse(w, r, InvalidAccessKeyID, acceptsContentType, r.URL.Path)
-			return
-		}
-		h.handler.ServeHTTP(w, r)
-	default:
+	accessKeyID := getRequestAccessKey(r)
+	if accessKeyID == "" {
 		// control reaches here, we should just send the request up the stack - internally
 		// individual calls will validate themselves against un-authenticated requests
 		h.handler.ServeHTTP(w, r)
+		return
+	}
+	// auth config is loaded once along with the request, its signature is verified by then
+	authConfig := getRequestAuth(r).config
+	if authConfig == nil {
+		writeErrorResponse(w, r, InternalError, acceptsContentType, r.URL.Path)
+		return
+	}
+	// Access key not found or disabled, sessions go with their parent
+	user, ok := authConfig.Users[accessKeyID]
+	if !ok || !authConfig.IsEnabled(user) {
+		writeErrorResponse(w, r, InvalidAccessKeyID, acceptsContentType, r.URL.Path)
+		return
 	}
+	switch checkSessionToken(user, r) {
+	case nil:
+	case errExpiredToken:
+		writeErrorResponse(w, r, ExpiredToken, acceptsContentType, r.URL.Path)
+		return
+	default:
+		writeErrorResponse(w, r, InvalidToken, acceptsContentType, r.URL.Path)
+		return
+	}
+	// every user may assume a role, its sessions are never allowed more than the user
+	action, resource := getRequestAction(r)
+	if action != assumeRoleAction && !authConfig.IsAllowed(accessKeyID, action, resource) {
+		writeErrorResponse(w, r, AccessDenied, acceptsContentType, r.URL.Path)
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// isWriteRequest - whether a request changes buckets or objects, everything but GET and HEAD does
+func isWriteRequest(r *http.Request) bool {
+	return r.Method != "GET" && r.Method != "HEAD"
 }
 
 // IgnoreResourcesHandler -
@@ -191,3 +221,42 @@ func ignoreNotImplementedObjectResources(req *http.Request) bool {
 	}
 	return false
 }
+
+// hostID identifies this server in the x-amz-id-2 header and in error responses
+var hostID = getHostID()
+
+func getHostID() string {
+	hostname, _ := os.Hostname()
+	sum := sha256.Sum256([]byte(hostname))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// generateRequestID - 16 random upper case hex digits, like the request IDs of S3
+func generateRequestID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return strings.ToUpper(hex.EncodeToString(id))
+}
+
+// getRequestID - ID of the request a response is written for
+func getRequestID(w http.ResponseWriter) string {
+	return w.Header().Get("X-Amz-Request-Id")
+}
+
+// requestErrorState - iodine state tying an error to the request which failed with it
+func requestErrorState(w http.ResponseWriter) map[string]string {
+	return map[string]string{"requestID": getRequestID(w)}
+}
+
+// RequestIDHandler -
+// request id handler gives every request an ID, returned to the client in the x-amz-request-id header
+// and in error responses, it is written to the access log and the error log
+func RequestIDHandler(h http.Handler) http.Handler {
+	return requestIDHandler{h}
+}
+
+func (h requestIDHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Amz-Request-Id", generateRequestID())
+	w.Header().Set("X-Amz-Id-2", hostID)
+	h.handler.ServeHTTP(w, r)
+}
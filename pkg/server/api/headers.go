@@ -22,10 +22,36 @@ import (
 	"encoding/xml"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/minio/minio/pkg/donut"
 )
 
+// Standard http object headers persisted along with an object, mapped to their metadata keys
+var objectMetadataHeaders = map[string]string{
+	"Content-Type":        "contentType",
+	"Content-Encoding":    "contentEncoding",
+	"Content-Disposition": "contentDisposition",
+	"Cache-Control":       "cacheControl",
+	"Content-Language":    "contentLanguage",
+	"Expires":             "expires",
+}
+
+// Query parameters overriding object headers on a GET response, mapped to the headers they override
+var responseHeaderOverrides = map[string]string{
+	"response-content-type":        "Content-Type",
+	"response-content-language":    "Content-Language",
+	"response-expires":             "Expires",
+	"response-cache-control":       "Cache-Control",
+	"response-content-disposition": "Content-Disposition",
+	"response-content-encoding":    "Content-Encoding",
+}
+
+// User metadata header prefix
+const (
+	userMetadataPrefix = "x-amz-meta-"
+)
+
 // No encoder interface exists, so we create one.
 type encoder interface {
 	Encode(v interface{}) error
@@ -61,26 +87,58 @@ func encodeErrorResponse(response interface{}, acceptsType contentType) []byte {
 	return bytesBuffer.Bytes()
 }
 
-// Write object header
-func setObjectHeaders(w http.ResponseWriter, metadata donut.ObjectMetadata) {
+// Write object header, overrides replace the stored values of standard headers
+func setObjectHeaders(w http.ResponseWriter, metadata donut.ObjectMetadata, overrides map[string]string) {
 	lastModified := metadata.Created.Format(http.TimeFormat)
 	// common headers
 	setCommonHeaders(w, metadata.Metadata["contentType"], int(metadata.Size))
 	// object related headers
 	w.Header().Set("ETag", "\""+metadata.MD5Sum+"\"")
 	w.Header().Set("Last-Modified", lastModified)
+	// persisted standard headers and user metadata
+	for header, key := range objectMetadataHeaders {
+		if value, ok := metadata.Metadata[key]; ok && header != "Content-Type" {
+			w.Header().Set(header, value)
+		}
+	}
+	for key, value := range metadata.Metadata {
+		if strings.HasPrefix(key, userMetadataPrefix) {
+			w.Header().Set(key, value)
+		}
+	}
+	for header, value := range overrides {
+		w.Header().Set(header, value)
+	}
 }
 
 // Write range object header
-func setRangeObjectHeaders(w http.ResponseWriter, metadata donut.ObjectMetadata, contentRange *httpRange) {
+func setRangeObjectHeaders(w http.ResponseWriter, metadata donut.ObjectMetadata, overrides map[string]string, contentRange *httpRange) {
 	// set common headers
 	setCommonHeaders(w, metadata.Metadata["contentType"], int(metadata.Size))
 	// set object headers
-	setObjectHeaders(w, metadata)
+	setObjectHeaders(w, metadata, overrides)
 	// set content range
 	w.Header().Set("Content-Range", contentRange.getContentRange())
 }
 
+// Write multiple range object header
+func setMultipleRangeObjectHeaders(w http.ResponseWriter, metadata donut.ObjectMetadata, overrides map[string]string, ranges []*httpRange, boundary string) {
+	// set object headers
+	setObjectHeaders(w, metadata, overrides)
+	// each range carries its own content type and range in the multipart body
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+	contentLength := getMultipleRangesLength(ranges, getResponseContentType(metadata, overrides), boundary)
+	w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
+}
+
+// Content type of an object as sent in a response
+func getResponseContentType(metadata donut.ObjectMetadata, overrides map[string]string) string {
+	if contentType, ok := overrides["Content-Type"]; ok {
+		return contentType
+	}
+	return metadata.Metadata["contentType"]
+}
+
 func encodeSuccessResponse(response interface{}, acceptsType contentType) []byte {
 	var encoder encoder
 	var bytesBuffer bytes.Buffer
@@ -0,0 +1,39 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// LiveHandler - the server is up, it answers as long as the process serves requests at all
+func (api Minio) LiveHandler(w http.ResponseWriter, req *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// ReadyHandler - the server can serve requests, load balancers send traffic only while it answers 200 OK
+func (api Minio) ReadyHandler(w http.ResponseWriter, req *http.Request) {
+	if err := api.Donut.Ready(); err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
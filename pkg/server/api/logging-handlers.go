@@ -17,44 +17,27 @@
 package api
 
 import (
-	"bytes"
-	"encoding/json"
-	"io"
 	"net/http"
-	"os"
 	"time"
-
-	"github.com/minio/minio/pkg/iodine"
-	"github.com/minio/minio/pkg/utils/log"
 )
 
 type logHandler struct {
-	handler http.Handler
-	logger  chan<- []byte
-}
-
-// logMessage is a serializable json log message
-type logMessage struct {
-	StartTime     time.Time
-	Duration      time.Duration
-	StatusMessage string // human readable http status message
-
-	// HTTP detailed message
-	HTTP struct {
-		ResponseHeaders http.Header
-		Request         *http.Request
-	}
+	handler   http.Handler
+	accessLog *AccessLog
+	metrics   *Metrics
+	tracer    *Tracer
 }
 
-// logWriter is used to capture status for log messages
+// logWriter is used to capture status and response size for access logs and metrics
 type logWriter struct {
 	responseWriter http.ResponseWriter
-	logMessage     *logMessage
+	status         int
+	written        int64
 }
 
-// WriteHeader writes headers and stores status in LogMessage
+// WriteHeader writes headers and stores status
 func (w *logWriter) WriteHeader(status int) {
-	w.logMessage.StatusMessage = http.StatusText(status)
+	w.status = status
 	w.responseWriter.WriteHeader(status)
 }
 
@@ -65,48 +48,44 @@ func (w *logWriter) Header() http.Header {
 
 // Write Dummy wrapper for LogWriter
 func (w *logWriter) Write(data []byte) (int, error) {
-	return w.responseWriter.Write(data)
+	n, err := w.responseWriter.Write(data)
+	w.written += int64(n)
+	return n, err
 }
 
 func (h *logHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	logMessage := &logMessage{
-		StartTime: time.Now().UTC(),
+	start := time.Now().UTC()
+	// a response without an explicit header is sent with 200 OK
+	logWriter := &logWriter{responseWriter: w, status: http.StatusOK}
+	var body *countingReader
+	if req.Body != nil {
+		body = &countingReader{ReadCloser: req.Body}
+		req.Body = body
 	}
-	logWriter := &logWriter{responseWriter: w, logMessage: logMessage}
 	h.handler.ServeHTTP(logWriter, req)
-	h.logger <- getLogMessage(logMessage, w, req)
-}
-
-func getLogMessage(logMessage *logMessage, w http.ResponseWriter, req *http.Request) []byte {
-	// store lower level details
-	logMessage.HTTP.ResponseHeaders = w.Header()
-	logMessage.HTTP.Request = req
-
-	logMessage.Duration = time.Now().UTC().Sub(logMessage.StartTime)
-	js, _ := json.Marshal(logMessage)
-	js = append(js, byte('\n')) // append a new line
-	return js
-}
-
-// LoggingHandler logs requests
-func LoggingHandler(h http.Handler) http.Handler {
-	logger, _ := fileLogger("access.log")
-	return &logHandler{handler: h, logger: logger}
+	var received int64
+	if body != nil {
+		received = body.count
+	}
+	if h.metrics != nil {
+		h.metrics.observe(getAPIName(req), logWriter.status, time.Now().UTC().Sub(start), received, logWriter.written)
+	}
+	// entries are only made for an access log or an attached tracer
+	tracing := h.tracer.isAttached()
+	if h.accessLog == nil && !tracing {
+		return
+	}
+	entry := newAccessLogEntry(req, w, start, logWriter.status, received, logWriter.written)
+	if h.accessLog != nil {
+		h.accessLog.log(entry)
+	}
+	if tracing {
+		h.tracer.publish(newTraceEntry(entry, req, w, h.tracer.wantsHeaders()))
+	}
 }
 
-// fileLogger returns a channel that is used to write to the logger
-func fileLogger(filename string) (chan<- []byte, error) {
-	ch := make(chan []byte)
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
-	if err != nil {
-		return nil, iodine.New(err, map[string]string{"logfile": filename})
-	}
-	go func() {
-		for message := range ch {
-			if _, err := io.Copy(file, bytes.NewBuffer(message)); err != nil {
-				log.Errorln(iodine.New(err, nil))
-			}
-		}
-	}()
-	return ch, nil
+// LoggingHandler writes requests to the access log, accounts for them in the request metrics and
+// streams them to attached tracers
+func (api Minio) LoggingHandler(h http.Handler) http.Handler {
+	return &logHandler{handler: h, accessLog: api.AccessLog, metrics: api.Metrics, tracer: api.Tracer}
 }
@@ -0,0 +1,293 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio/pkg/donut"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// latencyBuckets - upper bounds of the request latency histogram, in seconds
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// requestLabels - requests are counted by API and response status
+type requestLabels struct {
+	api    string
+	status int
+}
+
+// requestMetric - count and latency histogram of the requests with the same labels
+type requestMetric struct {
+	count   uint64
+	seconds float64
+	// buckets[i] counts the requests which took at most latencyBuckets[i], but longer than latencyBuckets[i-1]
+	buckets []uint64
+}
+
+// Metrics collects request metrics in LoggingHandler and the queue depth of Ticket Master, they are
+// exposed along with donut statistics by MetricsHandler
+type Metrics struct {
+	lock     *sync.Mutex
+	requests map[requestLabels]*requestMetric
+	bytesIn  uint64
+	bytesOut uint64
+	queued   int64
+}
+
+// newMetrics - empty metrics
+func newMetrics() *Metrics {
+	return &Metrics{
+		lock:     new(sync.Mutex),
+		requests: make(map[requestLabels]*requestMetric),
+	}
+}
+
+// observe - account for a served request
+func (m *Metrics) observe(api string, status int, duration time.Duration, bytesIn, bytesOut int64) {
+	atomic.AddUint64(&m.bytesIn, uint64(bytesIn))
+	atomic.AddUint64(&m.bytesOut, uint64(bytesOut))
+
+	labels := requestLabels{api: api, status: status}
+	seconds := duration.Seconds()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	metric, ok := m.requests[labels]
+	if !ok {
+		metric = &requestMetric{buckets: make([]uint64, len(latencyBuckets))}
+		m.requests[labels] = metric
+	}
+	metric.count++
+	metric.seconds += seconds
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			metric.buckets[i]++
+			break
+		}
+	}
+}
+
+// SetQueued - number of operations waiting in Ticket Master
+func (m *Metrics) SetQueued(queued int) {
+	atomic.StoreInt64(&m.queued, int64(queued))
+}
+
+// getAPIName - name of the API a request is routed to, following the routes of the server
+func getAPIName(req *http.Request) string {
+	if IsMetricsRequest(req) {
+		return "Metrics"
+	}
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	query := req.URL.Query()
+	_, uploadID := query["uploadId"]
+	switch {
+	case path == "":
+		switch req.Method {
+		case "GET":
+			return "ListBuckets"
+		case "POST":
+			return "AssumeRole"
+		}
+	case !strings.Contains(path, "/"):
+		switch req.Method {
+		case "GET":
+			return "ListObjects"
+		case "PUT":
+			return "PutBucket"
+		case "HEAD":
+			return "HeadBucket"
+		case "DELETE":
+			return "DeleteBucket"
+		}
+	default:
+		switch req.Method {
+		case "HEAD":
+			return "HeadObject"
+		case "GET":
+			if uploadID {
+				return "ListObjectParts"
+			}
+			return "GetObject"
+		case "PUT":
+			if _, partNumber := query["partNumber"]; partNumber && uploadID {
+				return "PutObjectPart"
+			}
+			return "PutObject"
+		case "POST":
+			if uploadID {
+				return "CompleteMultipartUpload"
+			}
+			return "NewMultipartUpload"
+		case "DELETE":
+			if uploadID {
+				return "AbortMultipartUpload"
+			}
+			return "DeleteObject"
+		}
+	}
+	return "Unknown"
+}
+
+// IsMetricsRequest - metrics are scraped with unsigned GET /metrics, signed requests for it list the
+// bucket named metrics
+func IsMetricsRequest(req *http.Request) bool {
+	return req.Method == "GET" && req.URL.Path == "/metrics" &&
+		req.Header.Get("Authorization") == "" && req.URL.Query().Get("X-Amz-Credential") == ""
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	count int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// MetricsHandler - expose request metrics and donut statistics in the Prometheus text format
+func (api Minio) MetricsHandler(w http.ResponseWriter, req *http.Request) {
+	var buffer bytes.Buffer
+	api.Metrics.write(&buffer)
+	stats, err := api.Donut.Stats()
+	if err != nil {
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeDonutStats(&buffer, stats)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Header().Set("Content-Length", strconv.Itoa(buffer.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buffer.Bytes())
+}
+
+// write - request metrics in the Prometheus text format
+func (m *Metrics) write(w io.Writer) {
+	m.lock.Lock()
+	var labels []requestLabels
+	for label := range m.requests {
+		labels = append(labels, label)
+	}
+	sort.Sort(byAPIAndStatus(labels))
+	writeHeader(w, "minio_http_requests_total", "counter", "Requests served by API and response status.")
+	for _, label := range labels {
+		fmt.Fprintf(w, "minio_http_requests_total{api=%q,status=\"%d\"} %d\n", label.api, label.status, m.requests[label].count)
+	}
+	writeHeader(w, "minio_http_request_duration_seconds", "histogram", "Time taken to serve requests by API and response status.")
+	for _, label := range labels {
+		metric := m.requests[label]
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += metric.buckets[i]
+			fmt.Fprintf(w, "minio_http_request_duration_seconds_bucket{api=%q,status=\"%d\",le=\"%s\"} %d\n",
+				label.api, label.status, formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(w, "minio_http_request_duration_seconds_bucket{api=%q,status=\"%d\",le=\"+Inf\"} %d\n", label.api, label.status, metric.count)
+		fmt.Fprintf(w, "minio_http_request_duration_seconds_sum{api=%q,status=\"%d\"} %s\n", label.api, label.status, formatFloat(metric.seconds))
+		fmt.Fprintf(w, "minio_http_request_duration_seconds_count{api=%q,status=\"%d\"} %d\n", label.api, label.status, metric.count)
+	}
+	m.lock.Unlock()
+
+	writeHeader(w, "minio_http_received_bytes_total", "counter", "Bytes received in request bodies.")
+	fmt.Fprintf(w, "minio_http_received_bytes_total %d\n", atomic.LoadUint64(&m.bytesIn))
+	writeHeader(w, "minio_http_sent_bytes_total", "counter", "Bytes sent in response bodies.")
+	fmt.Fprintf(w, "minio_http_sent_bytes_total %d\n", atomic.LoadUint64(&m.bytesOut))
+	writeHeader(w, "minio_ticket_master_queued_operations", "gauge", "Operations waiting to be admitted.")
+	fmt.Fprintf(w, "minio_ticket_master_queued_operations %d\n", atomic.LoadInt64(&m.queued))
+}
+
+// writeDonutStats - donut statistics in the Prometheus text format
+func writeDonutStats(w io.Writer, stats donut.Stats) {
+	writeCacheStats(w, "minio_cache", "memory cache", stats.Cache.Bytes, stats.Cache.Items, stats.Cache.Evicted,
+		stats.Cache.Hits, stats.Cache.Misses)
+	writeHeader(w, "minio_cache_expirations_total", "counter", "Items expired from the memory cache.")
+	fmt.Fprintf(w, "minio_cache_expirations_total %d\n", stats.Cache.Expired)
+	if stats.DiskCache != nil {
+		writeCacheStats(w, "minio_disk_cache", "disk cache", stats.DiskCache.Bytes, stats.DiskCache.Items,
+			stats.DiskCache.Evicted, stats.DiskCache.Hits, stats.DiskCache.Misses)
+		writeHeader(w, "minio_disk_cache_corrupted_total", "counter", "Items of the disk cache failing their checksum.")
+		fmt.Fprintf(w, "minio_disk_cache_corrupted_total %d\n", stats.DiskCache.Corrupted)
+	}
+	if len(stats.Disks) > 0 {
+		writeHeader(w, "minio_disk_free_bytes", "gauge", "Free space of the disks.")
+		for _, disk := range stats.Disks {
+			fmt.Fprintf(w, "minio_disk_free_bytes{node=%q,disk=%q} %d\n", disk.Node, disk.Path, disk.Free)
+		}
+		writeHeader(w, "minio_disk_total_bytes", "gauge", "Total space of the disks.")
+		for _, disk := range stats.Disks {
+			fmt.Fprintf(w, "minio_disk_total_bytes{node=%q,disk=%q} %d\n", disk.Node, disk.Path, disk.Total)
+		}
+	}
+	writeHeader(w, "minio_multipart_sessions", "gauge", "Multipart uploads in progress.")
+	fmt.Fprintf(w, "minio_multipart_sessions %d\n", stats.MultipartSessions)
+	writeHeader(w, "minio_write_back_pending_objects", "gauge", "Objects acknowledged but not yet written to the disks.")
+	fmt.Fprintf(w, "minio_write_back_pending_objects %d\n", stats.WriteBackPending)
+}
+
+// writeCacheStats - usage and hit ratio of a cache
+func writeCacheStats(w io.Writer, prefix, name string, bytes uint64, items, evicted int, hits, misses uint64) {
+	writeHeader(w, prefix+"_bytes", "gauge", "Bytes held in the "+name+".")
+	fmt.Fprintf(w, "%s_bytes %d\n", prefix, bytes)
+	writeHeader(w, prefix+"_items", "gauge", "Items held in the "+name+".")
+	fmt.Fprintf(w, "%s_items %d\n", prefix, items)
+	writeHeader(w, prefix+"_evictions_total", "counter", "Items evicted from the "+name+".")
+	fmt.Fprintf(w, "%s_evictions_total %d\n", prefix, evicted)
+	writeHeader(w, prefix+"_hits_total", "counter", "Lookups served by the "+name+".")
+	fmt.Fprintf(w, "%s_hits_total %d\n", prefix, hits)
+	writeHeader(w, prefix+"_misses_total", "counter", "Lookups missed by the "+name+".")
+	fmt.Fprintf(w, "%s_misses_total %d\n", prefix, misses)
+	var ratio float64
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+	writeHeader(w, prefix+"_hit_ratio", "gauge", "Share of lookups served by the "+name+".")
+	fmt.Fprintf(w, "%s_hit_ratio %s\n", prefix, formatFloat(ratio))
+}
+
+func writeHeader(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// byAPIAndStatus sorts request labels
+type byAPIAndStatus []requestLabels
+
+func (l byAPIAndStatus) Len() int      { return len(l) }
+func (l byAPIAndStatus) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l byAPIAndStatus) Less(i, j int) bool {
+	if l[i].api != l[j].api {
+		return l[i].api < l[j].api
+	}
+	return l[i].status < l[j].status
+}
@@ -17,6 +17,8 @@
 package api
 
 import (
+	"io"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 
@@ -36,13 +38,11 @@ const (
 // you must have READ access to the object.
 func (api Minio) GetObjectHandler(w http.ResponseWriter, req *http.Request) {
 	// ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	op, ok := api.waitForTicket(w, req, ReadOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	if !api.isValidOp(w, req, acceptsContentType) {
@@ -65,29 +65,69 @@ func (api Minio) GetObjectHandler(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	metadata, err := api.Donut.GetObjectMetadata(bucket, object, signature)
+	// presigned urls carry their signature in the query string, it covers no payload
+	// so it is verified here rather than by donut
+	var presigned bool
+	if _, ok := req.URL.Query()["X-Amz-Credential"]; ok && signature == nil {
+		presignedSignature, err := InitPresignedSignatureV4(req)
+		if err != nil {
+			writeErrorResponse(w, req, InvalidAccessKeyID, acceptsContentType, req.URL.Path)
+			return
+		}
+		ok, err := presignedSignature.DoesPresignedSignatureMatch()
+		if err != nil || !ok {
+			writeErrorResponse(w, req, SignatureDoesNotMatch, acceptsContentType, req.URL.Path)
+			return
+		}
+		presigned = true
+	}
+
+	// response header overrides are only honored on authenticated or presigned requests
+	overrides := getResponseHeaderOverrides(req)
+	if len(overrides) > 0 && signature == nil && !presigned {
+		writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	// metadata and data are read from one version, an overwrite waits until it is served
+	version, err := api.Donut.OpenObject(bucket, object, signature)
 	switch iodine.ToError(err).(type) {
 	case nil: // success
 		{
-			httpRange, err := getRequestedRange(req, metadata.Size)
+			defer version.Close()
+			metadata := version.Metadata
+			ranges, err := getRequestedRanges(req, metadata.Size)
 			if err != nil {
 				writeErrorResponse(w, req, InvalidRange, acceptsContentType, req.URL.Path)
 				return
 			}
-			switch httpRange.start == 0 && httpRange.length == 0 {
-			case true:
-				setObjectHeaders(w, metadata)
-				if _, err := api.Donut.GetObject(w, bucket, object); err != nil {
+			switch len(ranges) {
+			case 0:
+				setObjectHeaders(w, metadata, overrides)
+				if _, err := version.Read(w, 0, metadata.Size); err != nil {
 					// unable to write headers, we've already printed data. Just close the connection.
-					log.Error.Println(iodine.New(err, nil))
+					log.Error.Println(iodine.New(err, requestErrorState(w)))
 				}
-			case false:
+			case 1:
+				httpRange := ranges[0]
 				metadata.Size = httpRange.length
-				setRangeObjectHeaders(w, metadata, httpRange)
+				setRangeObjectHeaders(w, metadata, overrides, httpRange)
+				w.WriteHeader(http.StatusPartialContent)
+				if _, err := version.Read(w, httpRange.start, httpRange.length); err != nil {
+					// unable to write headers, we've already printed data. Just close the connection.
+					log.Error.Println(iodine.New(err, requestErrorState(w)))
+				}
+			default:
+				boundary := multipart.NewWriter(nil).Boundary()
+				setMultipleRangeObjectHeaders(w, metadata, overrides, ranges, boundary)
 				w.WriteHeader(http.StatusPartialContent)
-				if _, err := api.Donut.GetPartialObject(w, bucket, object, httpRange.start, httpRange.length); err != nil {
+				writeRange := func(part io.Writer, httpRange *httpRange) error {
+					_, err := version.Read(part, httpRange.start, httpRange.length)
+					return err
+				}
+				if err := writeMultipleRanges(w, ranges, getResponseContentType(metadata, overrides), boundary, writeRange); err != nil {
 					// unable to write headers, we've already printed data. Just close the connection.
-					log.Error.Println(iodine.New(err, nil))
+					log.Error.Println(iodine.New(err, requestErrorState(w)))
 				}
 			}
 		}
@@ -102,7 +142,7 @@ func (api Minio) GetObjectHandler(w http.ResponseWriter, req *http.Request) {
 	case donut.ObjectNameInvalid:
 		writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
@@ -112,13 +152,11 @@ func (api Minio) GetObjectHandler(w http.ResponseWriter, req *http.Request) {
 // The HEAD operation retrieves metadata from an object without returning the object itself.
 func (api Minio) HeadObjectHandler(w http.ResponseWriter, req *http.Request) {
 	// ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	op, ok := api.waitForTicket(w, req, ReadOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	if !api.isValidOp(w, req, acceptsContentType) {
@@ -144,7 +182,7 @@ func (api Minio) HeadObjectHandler(w http.ResponseWriter, req *http.Request) {
 	metadata, err := api.Donut.GetObjectMetadata(bucket, object, signature)
 	switch iodine.ToError(err).(type) {
 	case nil:
-		setObjectHeaders(w, metadata)
+		setObjectHeaders(w, metadata, nil)
 		w.WriteHeader(http.StatusOK)
 	case donut.SignatureDoesNotMatch:
 		writeErrorResponse(w, req, SignatureDoesNotMatch, acceptsContentType, req.URL.Path)
@@ -157,7 +195,7 @@ func (api Minio) HeadObjectHandler(w http.ResponseWriter, req *http.Request) {
 	case donut.ObjectNameInvalid:
 		writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
@@ -166,14 +204,12 @@ func (api Minio) HeadObjectHandler(w http.ResponseWriter, req *http.Request) {
 // ----------
 // This implementation of the PUT operation adds an object to a bucket.
 func (api Minio) PutObjectHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, WriteOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	if !api.isValidOp(w, req, acceptsContentType) {
@@ -202,6 +238,12 @@ func (api Minio) PutObjectHandler(w http.ResponseWriter, req *http.Request) {
 		writeErrorResponse(w, req, EntityTooLarge, acceptsContentType, req.URL.Path)
 		return
 	}
+	/// maximum size of user metadata for objects
+	metadata := getObjectMetadataFromRequest(req)
+	if isMaxUserMetadataSize(metadata) {
+		writeErrorResponse(w, req, MetadataTooLarge, acceptsContentType, req.URL.Path)
+		return
+	}
 	/// minimum Upload size for objects in a single operation
 	//
 	// Surprisingly while Amazon in their document states that S3 objects have 1byte
@@ -229,10 +271,13 @@ func (api Minio) PutObjectHandler(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	metadata, err := api.Donut.CreateObject(bucket, object, md5, sizeInt64, req.Body, nil, signature)
+	for k, v := range getWritePreconditions(req) {
+		metadata[k] = v
+	}
+	objectMetadata, err := api.Donut.CreateObject(bucket, object, md5, sizeInt64, req.Body, metadata, signature)
 	switch iodine.ToError(err).(type) {
 	case nil:
-		w.Header().Set("ETag", metadata.MD5Sum)
+		w.Header().Set("ETag", objectMetadata.MD5Sum)
 		writeSuccessResponse(w, acceptsContentType)
 	case donut.BucketNotFound:
 		writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
@@ -240,6 +285,10 @@ func (api Minio) PutObjectHandler(w http.ResponseWriter, req *http.Request) {
 		writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
 	case donut.ObjectExists:
 		writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
+	case donut.ObjectNotFound:
+		writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+	case donut.PreconditionFailed:
+		writeErrorResponse(w, req, PreconditionFailed, acceptsContentType, req.URL.Path)
 	case donut.BadDigest:
 		writeErrorResponse(w, req, BadDigest, acceptsContentType, req.URL.Path)
 	case donut.MissingDateHeader:
@@ -253,7 +302,7 @@ func (api Minio) PutObjectHandler(w http.ResponseWriter, req *http.Request) {
 	case donut.InvalidDigest:
 		writeErrorResponse(w, req, InvalidDigest, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
@@ -262,14 +311,12 @@ func (api Minio) PutObjectHandler(w http.ResponseWriter, req *http.Request) {
 
 // NewMultipartUploadHandler - New multipart upload
 func (api Minio) NewMultipartUploadHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, WriteOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	if !api.isValidOp(w, req, acceptsContentType) {
@@ -281,6 +328,12 @@ func (api Minio) NewMultipartUploadHandler(w http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	metadata := getObjectMetadataFromRequest(req)
+	if isMaxUserMetadataSize(metadata) {
+		writeErrorResponse(w, req, MetadataTooLarge, acceptsContentType, req.URL.Path)
+		return
+	}
+
 	var object, bucket string
 	vars := mux.Vars(req)
 	bucket = vars["bucket"]
@@ -297,7 +350,7 @@ func (api Minio) NewMultipartUploadHandler(w http.ResponseWriter, req *http.Requ
 		}
 	}
 
-	uploadID, err := api.Donut.NewMultipartUpload(bucket, object, req.Header.Get("Content-Type"), signature)
+	uploadID, err := api.Donut.NewMultipartUpload(bucket, object, metadata, signature)
 	switch iodine.ToError(err).(type) {
 	case nil:
 		{
@@ -313,21 +366,19 @@ func (api Minio) NewMultipartUploadHandler(w http.ResponseWriter, req *http.Requ
 	case donut.ObjectExists:
 		writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
 
 // PutObjectPartHandler - Upload part
 func (api Minio) PutObjectPartHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, WriteOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	if !api.isValidOp(w, req, acceptsContentType) {
@@ -403,21 +454,19 @@ func (api Minio) PutObjectPartHandler(w http.ResponseWriter, req *http.Request)
 	case donut.InvalidDigest:
 		writeErrorResponse(w, req, InvalidDigest, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
 
 // AbortMultipartUploadHandler - Abort multipart upload
 func (api Minio) AbortMultipartUploadHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, WriteOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	if !api.isValidOp(w, req, acceptsContentType) {
@@ -451,21 +500,19 @@ func (api Minio) AbortMultipartUploadHandler(w http.ResponseWriter, req *http.Re
 	case donut.InvalidUploadID:
 		writeErrorResponse(w, req, NoSuchUpload, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
 
 // ListObjectPartsHandler - List object parts
 func (api Minio) ListObjectPartsHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, ListOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	if !api.isValidOp(w, req, acceptsContentType) {
@@ -508,21 +555,19 @@ func (api Minio) ListObjectPartsHandler(w http.ResponseWriter, req *http.Request
 	case donut.InvalidUploadID:
 		writeErrorResponse(w, req, NoSuchUpload, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
 
 // CompleteMultipartUploadHandler - Complete multipart upload
 func (api Minio) CompleteMultipartUploadHandler(w http.ResponseWriter, req *http.Request) {
-	// Ticket master block
-	{
-		op := Operation{}
-		op.ProceedCh = make(chan struct{})
-		api.OP <- op
-		// block until Ticket master gives us a go
-		<-op.ProceedCh
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, WriteOperation)
+	if !ok {
+		return
 	}
+	defer api.releaseTicket(op)
 
 	acceptsContentType := getContentType(req)
 	if !api.isValidOp(w, req, acceptsContentType) {
@@ -545,7 +590,7 @@ func (api Minio) CompleteMultipartUploadHandler(w http.ResponseWriter, req *http
 			return
 		}
 	}
-	metadata, err := api.Donut.CompleteMultipartUpload(bucket, object, objectResourcesMetadata.UploadID, req.Body, signature)
+	metadata, err := api.Donut.CompleteMultipartUpload(bucket, object, objectResourcesMetadata.UploadID, req.Body, getWritePreconditions(req), signature)
 	switch iodine.ToError(err).(type) {
 	case nil:
 		{
@@ -562,6 +607,12 @@ func (api Minio) CompleteMultipartUploadHandler(w http.ResponseWriter, req *http
 		writeErrorResponse(w, req, InvalidPart, acceptsContentType, req.URL.Path)
 	case donut.InvalidPartOrder:
 		writeErrorResponse(w, req, InvalidPartOrder, acceptsContentType, req.URL.Path)
+	case donut.ObjectExists:
+		writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
+	case donut.ObjectNotFound:
+		writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+	case donut.PreconditionFailed:
+		writeErrorResponse(w, req, PreconditionFailed, acceptsContentType, req.URL.Path)
 	case donut.MissingDateHeader:
 		writeErrorResponse(w, req, RequestTimeTooSkewed, acceptsContentType, req.URL.Path)
 	case donut.SignatureDoesNotMatch:
@@ -571,7 +622,7 @@ func (api Minio) CompleteMultipartUploadHandler(w http.ResponseWriter, req *http
 	case donut.MalformedXML:
 		writeErrorResponse(w, req, MalformedXML, acceptsContentType, req.URL.Path)
 	default:
-		log.Error.Println(iodine.New(err, nil))
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 	}
 }
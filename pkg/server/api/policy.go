@@ -0,0 +1,67 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/minio/minio/pkg/auth"
+)
+
+// s3Actions - IAM actions policies allow or deny, by API name
+var s3Actions = map[string]string{
+	"AssumeRole":              assumeRoleAction,
+	"ListBuckets":             "s3:ListAllMyBuckets",
+	"ListObjects":             "s3:ListBucket",
+	"PutBucket":               "s3:CreateBucket",
+	"HeadBucket":              "s3:ListBucket",
+	"DeleteBucket":            "s3:DeleteBucket",
+	"HeadObject":              "s3:GetObject",
+	"GetObject":               "s3:GetObject",
+	"PutObject":               "s3:PutObject",
+	"PutObjectPart":           "s3:PutObject",
+	"ListObjectParts":         "s3:ListMultipartUploadParts",
+	"NewMultipartUpload":      "s3:PutObject",
+	"CompleteMultipartUpload": "s3:PutObject",
+	"AbortMultipartUpload":    "s3:AbortMultipartUpload",
+	"DeleteObject":            "s3:DeleteObject",
+}
+
+// getRequestAction - IAM action a request needs to be allowed and the ARN of the resource it is on
+func getRequestAction(req *http.Request) (action, resource string) {
+	bucket, object := getBucketAndObject(req)
+	resource = auth.ResourceARN(bucket, object)
+	query := req.URL.Query()
+	if bucket != "" && object == "" {
+		// bucket sub-resources share their API names with the bucket itself
+		_, acl := query["acl"]
+		_, uploads := query["uploads"]
+		switch {
+		case acl && req.Method == "PUT":
+			return "s3:PutBucketAcl", resource
+		case acl:
+			return "s3:GetBucketAcl", resource
+		case uploads && req.Method == "GET":
+			return "s3:ListBucketMultipartUploads", resource
+		}
+	}
+	apiName := getAPIName(req)
+	if action, ok := s3Actions[apiName]; ok {
+		return action, resource
+	}
+	return "s3:" + apiName, resource
+}
@@ -19,15 +19,24 @@ package api
 import (
 	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 const (
 	b = "bytes="
+	// maximum number of ranges accepted in a single request
+	maxRanges = 100
 )
 
+// errUnsatisfiableRange is returned for a range starting past the end of the object
+var errUnsatisfiableRange = errors.New("unsatisfiable range")
+
 // HttpRange specifies the byte range to be sent to the client.
 type httpRange struct {
 	start, length, size int64
@@ -38,21 +47,104 @@ func (r *httpRange) getContentRange() string {
 	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, r.size)
 }
 
-// Grab new range from request header
-func getRequestedRange(req *http.Request, size int64) (*httpRange, error) {
-	r := &httpRange{
-		start:  0,
-		length: 0,
-		size:   0,
+// getMIMEHeader populate part header of a range in a multipart/byteranges response
+func (r *httpRange) getMIMEHeader(contentType string) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Range": {r.getContentRange()},
+		"Content-Type":  {contentType},
 	}
-	r.size = size
-	if s := req.Header.Get("Range"); s != "" {
-		err := r.parseRange(s)
+}
+
+// byStart sorts ranges by their start offset
+type byStart []*httpRange
+
+func (s byStart) Len() int           { return len(s) }
+func (s byStart) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byStart) Less(i, j int) bool { return s[i].start < s[j].start }
+
+// Grab new ranges from request header, overlapping and adjacent ranges are coalesced
+//
+// No ranges are returned when the request does not carry a Range header. As per RFC 7233 section 4.4
+// ranges which cannot be satisfied are dropped, an error is only returned if none of them can be
+func getRequestedRanges(req *http.Request, size int64) ([]*httpRange, error) {
+	s := req.Header.Get("Range")
+	if s == "" {
+		return nil, nil
+	}
+	ras, err := splitRange(s)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []*httpRange
+	for _, ra := range ras {
+		r := &httpRange{size: size}
+		err := r.parse(ra)
+		// zero length ranges cannot be satisfied either
+		if err == errUnsatisfiableRange || (err == nil && r.length == 0) {
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New("invalid range")
+	}
+	sort.Sort(byStart(ranges))
+	coalesced := []*httpRange{ranges[0]}
+	for _, r := range ranges[1:] {
+		last := coalesced[len(coalesced)-1]
+		if r.start > last.start+last.length {
+			coalesced = append(coalesced, r)
+			continue
+		}
+		if end := r.start + r.length; end > last.start+last.length {
+			last.length = end - last.start
+		}
+	}
+	return coalesced, nil
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
+// getMultipleRangesLength calculate the length of a multipart/byteranges body
+func getMultipleRangesLength(ranges []*httpRange, contentType, boundary string) int64 {
+	var length int64
+	var w countingWriter
+	mw := multipart.NewWriter(&w)
+	mw.SetBoundary(boundary)
+	for _, r := range ranges {
+		mw.CreatePart(r.getMIMEHeader(contentType))
+		length += r.length
+	}
+	mw.Close()
+	return int64(w) + length
+}
+
+// writeMultipleRanges write ranges as parts of a multipart/byteranges body, data for each range is
+// written by the given function
+func writeMultipleRanges(w io.Writer, ranges []*httpRange, contentType, boundary string, writeRange func(io.Writer, *httpRange) error) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for _, r := range ranges {
+		part, err := mw.CreatePart(r.getMIMEHeader(contentType))
+		if err != nil {
+			return err
+		}
+		if err := writeRange(part, r); err != nil {
+			return err
+		}
 	}
-	return r, nil
+	return mw.Close()
 }
 
 func (r *httpRange) parse(ra string) error {
@@ -75,9 +167,12 @@ func (r *httpRange) parse(ra string) error {
 		r.length = r.size - r.start
 	} else {
 		i, err := strconv.ParseInt(start, 10, 64)
-		if err != nil || i > r.size || i < 0 {
+		if err != nil || i < 0 {
 			return errors.New("invalid range")
 		}
+		if i >= r.size {
+			return errUnsatisfiableRange
+		}
 		r.start = i
 		if end == "" {
 			// If no end is specified, range extends to end of the file.
@@ -96,27 +191,27 @@ func (r *httpRange) parse(ra string) error {
 	return nil
 }
 
-// parseRange parses a Range header string as per RFC 2616.
-func (r *httpRange) parseRange(s string) error {
+// splitRange splits a Range header string into its comma separated ranges as per RFC 2616.
+func splitRange(s string) ([]string, error) {
 	if s == "" {
-		return errors.New("header not present")
+		return nil, errors.New("header not present")
 	}
 	if !strings.HasPrefix(s, b) {
-		return errors.New("invalid range")
+		return nil, errors.New("invalid range")
 	}
-
-	ras := strings.Split(s[len(b):], ",")
-	if len(ras) == 0 {
-		return errors.New("invalid request")
+	var ras []string
+	for _, ra := range strings.Split(s[len(b):], ",") {
+		ra = strings.TrimSpace(ra)
+		if ra == "" {
+			continue
+		}
+		ras = append(ras, ra)
 	}
-	// Just pick the first one and ignore the rest, we only support one range per object
-	if len(ras) > 1 {
-		return errors.New("multiple ranges specified")
+	if len(ras) == 0 {
+		return nil, errors.New("invalid range")
 	}
-
-	ra := strings.TrimSpace(ras[0])
-	if ra == "" {
-		return errors.New("invalid range")
+	if len(ras) > maxRanges {
+		return nil, errors.New("too many ranges specified")
 	}
-	return r.parse(ra)
+	return ras, nil
 }
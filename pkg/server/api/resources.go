@@ -17,6 +17,7 @@
 package api
 
 import (
+	"encoding/base64"
 	"net/url"
 	"strconv"
 
@@ -30,9 +31,27 @@ func getBucketResources(values url.Values) (v donut.BucketResourcesMetadata) {
 	v.Maxkeys, _ = strconv.Atoi(values.Get("max-keys"))
 	v.Delimiter = values.Get("delimiter")
 	v.EncodingType = values.Get("encoding-type")
+	v.ListType, _ = strconv.Atoi(values.Get("list-type"))
+	v.ContinuationToken = values.Get("continuation-token")
+	v.StartAfter = values.Get("start-after")
+	v.FetchOwner, _ = strconv.ParseBool(values.Get("fetch-owner"))
 	return
 }
 
+// generate an opaque continuation token for list objects version 2 from a marker
+func getContinuationToken(marker string) string {
+	return base64.URLEncoding.EncodeToString([]byte(marker))
+}
+
+// get marker from an opaque continuation token for list objects version 2
+func getContinuationMarker(token string) (string, error) {
+	marker, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+	return string(marker), nil
+}
+
 // part bucket url queries for ?uploads
 func getBucketMultipartResources(values url.Values) (v donut.BucketMultipartResourcesMetadata) {
 	v.Prefix = values.Get("prefix")
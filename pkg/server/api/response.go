@@ -18,8 +18,10 @@ package api
 
 import (
 	"net/http"
+	"net/url"
 	"sort"
 
+	"github.com/minio/minio/pkg/auth"
 	"github.com/minio/minio/pkg/donut"
 )
 
@@ -62,6 +64,15 @@ func (b itemKey) Len() int           { return len(b) }
 func (b itemKey) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
 func (b itemKey) Less(i, j int) bool { return b[i].Key < b[j].Key }
 
+// encodeKey - url encode object keys and prefixes if the client asked for encoding-type=url, so that
+// keys with characters not allowed in xml can be listed
+func encodeKey(key, encodingType string) string {
+	if encodingType == "url" {
+		return url.QueryEscape(key)
+	}
+	return key
+}
+
 // takes a set of objects and prepares the objects for serialization
 // input:
 // bucket name
@@ -84,33 +95,89 @@ func generateListObjectsResponse(bucket string, objects []donut.ObjectMetadata,
 		if object.Object == "" {
 			continue
 		}
-		content.Key = object.Object
+		content.Key = encodeKey(object.Object, bucketResources.EncodingType)
 		content.LastModified = object.Created.Format(rfcFormat)
 		content.ETag = "\"" + object.MD5Sum + "\""
 		content.Size = object.Size
 		content.StorageClass = "STANDARD"
-		content.Owner = owner
+		content.Owner = &owner
 		contents = append(contents, content)
 	}
 	sort.Sort(itemKey(contents))
-	// TODO - support EncodingType in xml decoding
 	data.Name = bucket
 	data.Contents = contents
+	data.EncodingType = bucketResources.EncodingType
 	data.MaxKeys = bucketResources.Maxkeys
-	data.Prefix = bucketResources.Prefix
-	data.Delimiter = bucketResources.Delimiter
-	data.Marker = bucketResources.Marker
-	data.NextMarker = bucketResources.NextMarker
+	data.Prefix = encodeKey(bucketResources.Prefix, bucketResources.EncodingType)
+	data.Delimiter = encodeKey(bucketResources.Delimiter, bucketResources.EncodingType)
+	data.Marker = encodeKey(bucketResources.Marker, bucketResources.EncodingType)
+	data.NextMarker = encodeKey(bucketResources.NextMarker, bucketResources.EncodingType)
 	data.IsTruncated = bucketResources.IsTruncated
 	for _, prefix := range bucketResources.CommonPrefixes {
 		var prefixItem = &CommonPrefix{}
-		prefixItem.Prefix = prefix
+		prefixItem.Prefix = encodeKey(prefix, bucketResources.EncodingType)
 		prefixes = append(prefixes, prefixItem)
 	}
 	data.CommonPrefixes = prefixes
 	return data
 }
 
+// takes a set of objects and prepares the objects for serialization as list objects version 2 response
+// input:
+// bucket name
+// array of object metadata
+// bucket resources, carrying the continuation token and start-after of the request
+//
+// output:
+// populated struct that can be serialized to match xml and json api spec output, unlike version 1
+// it reports the number of returned keys as KeyCount, replaces markers by an opaque continuation
+// token and lists owners only if asked to with fetch-owner
+func generateListObjectsV2Response(bucket string, objects []donut.ObjectMetadata, bucketResources donut.BucketResourcesMetadata) ListObjectsV2Response {
+	var contents []*Object
+	var prefixes []*CommonPrefix
+	var data = ListObjectsV2Response{}
+
+	for _, object := range objects {
+		var content = &Object{}
+		if object.Object == "" {
+			continue
+		}
+		content.Key = encodeKey(object.Object, bucketResources.EncodingType)
+		content.LastModified = object.Created.Format(rfcFormat)
+		content.ETag = "\"" + object.MD5Sum + "\""
+		content.Size = object.Size
+		content.StorageClass = "STANDARD"
+		if bucketResources.FetchOwner {
+			content.Owner = &Owner{
+				ID:          "minio",
+				DisplayName: "minio",
+			}
+		}
+		contents = append(contents, content)
+	}
+	sort.Sort(itemKey(contents))
+	data.Name = bucket
+	data.Contents = contents
+	data.EncodingType = bucketResources.EncodingType
+	data.MaxKeys = bucketResources.Maxkeys
+	data.Prefix = encodeKey(bucketResources.Prefix, bucketResources.EncodingType)
+	data.Delimiter = encodeKey(bucketResources.Delimiter, bucketResources.EncodingType)
+	data.ContinuationToken = bucketResources.ContinuationToken
+	data.StartAfter = encodeKey(bucketResources.StartAfter, bucketResources.EncodingType)
+	data.IsTruncated = bucketResources.IsTruncated
+	if bucketResources.IsTruncated {
+		data.NextContinuationToken = getContinuationToken(bucketResources.NextMarker)
+	}
+	for _, prefix := range bucketResources.CommonPrefixes {
+		var prefixItem = &CommonPrefix{}
+		prefixItem.Prefix = encodeKey(prefix, bucketResources.EncodingType)
+		prefixes = append(prefixes, prefixItem)
+	}
+	data.CommonPrefixes = prefixes
+	data.KeyCount = len(contents) + len(prefixes)
+	return data
+}
+
 // generateInitiateMultipartUploadResponse
 func generateInitiateMultipartUploadResponse(bucket, key, uploadID string) InitiateMultipartUploadResponse {
 	return InitiateMultipartUploadResponse{
@@ -120,6 +187,25 @@ func generateInitiateMultipartUploadResponse(bucket, key, uploadID string) Initi
 	}
 }
 
+// generateAssumeRoleResponse
+func generateAssumeRoleResponse(session *auth.User, requestID string) AssumeRoleResponse {
+	return AssumeRoleResponse{
+		AssumeRoleResult: AssumeRoleResult{
+			Credentials: Credentials{
+				AccessKeyID:     session.AccessKeyID,
+				SecretAccessKey: session.SecretAccessKey,
+				SessionToken:    session.Session.Token,
+				Expiration:      session.Session.Expiration.Format(rfcFormat),
+			},
+			AssumedRoleUser: AssumedRoleUser{
+				Arn:           "arn:aws:sts:::assumed-role/" + session.Session.Parent + "/" + session.AccessKeyID,
+				AssumedRoleID: session.AccessKeyID + ":" + session.Session.Parent,
+			},
+		},
+		ResponseMetadata: ResponseMetadata{RequestID: requestID},
+	}
+}
+
 // generateCompleteMultipartUploadResponse
 func generateCompleteMultpartUploadResponse(bucket, key, location, etag string) CompleteMultipartUploadResponse {
 	return CompleteMultipartUploadResponse{
@@ -195,7 +281,7 @@ func writeSuccessResponse(w http.ResponseWriter, acceptsContentType contentType)
 func writeErrorResponse(w http.ResponseWriter, req *http.Request, errorType int, acceptsContentType contentType, resource string) {
 	error := getErrorCode(errorType)
 	// generate error response
-	errorResponse := getErrorResponse(error, resource)
+	errorResponse := getErrorResponse(error, resource, getRequestID(w))
 	encodedErrorResponse := encodeErrorResponse(errorResponse, acceptsContentType)
 	// set common headers
 	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedErrorResponse))
@@ -17,10 +17,12 @@
 package api
 
 import (
+	"crypto/subtle"
 	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/gorilla/context"
 	"github.com/minio/minio/pkg/auth"
 	"github.com/minio/minio/pkg/donut"
 	"github.com/minio/minio/pkg/iodine"
@@ -30,6 +32,49 @@ const (
 	authHeaderPrefix = "AWS4-HMAC-SHA256"
 )
 
+// temporary credentials are refused with these errors
+var (
+	errExpiredToken = errors.New("Session token expired")
+	errInvalidToken = errors.New("Invalid session token")
+)
+
+// credentials of disabled users and of sessions of disabled or deleted users are refused with this error
+var errDisabledUser = errors.New("User is disabled")
+
+// checkSessionToken - temporary credentials are only valid along with their session token and until
+// they expire, credentials of users need no token
+func checkSessionToken(user *auth.User, req *http.Request) error {
+	if user.Session == nil {
+		return nil
+	}
+	token := req.Header.Get("X-Amz-Security-Token")
+	if token == "" {
+		token = req.URL.Query().Get("X-Amz-Security-Token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(user.Session.Token)) != 1 {
+		return errInvalidToken
+	}
+	if user.Session.IsExpired() {
+		return errExpiredToken
+	}
+	return nil
+}
+
+// getCredentialService - service of the credential scope of an auth header, empty if it has none
+func getCredentialService(ah string) string {
+	authFields := strings.Split(strings.TrimSpace(ah), ",")
+	credential := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(authFields[0], authHeaderPrefix)), "=", 2)
+	if len(credential) != 2 {
+		return ""
+	}
+	// credential is of the form <access key id>/<date>/<region>/<service>/aws4_request
+	scope := strings.Split(credential[1], "/")
+	if len(scope) != 5 {
+		return ""
+	}
+	return scope[3]
+}
+
 // StripAccessKeyID - strip only access key id from auth header
 func StripAccessKeyID(ah string) (string, error) {
 	if ah == "" {
@@ -63,23 +108,151 @@ func StripAccessKeyID(ah string) (string, error) {
 	return accessKeyID, nil
 }
 
+// requestAuth - credentials of a request, they are looked up and verified once per request and kept
+// along with it for the scheduler, the audit log and the handlers
+type requestAuth struct {
+	config    *auth.Config
+	signature *donut.Signature
+	err       error
+	// set if the signature matches, the payload is verified against its claimed checksum later on
+	accessKey string
+}
+
+// contextKey - keys of values kept along with a request
+type contextKey int
+
+const requestAuthKey contextKey = 0
+
+// getRequestAuth - credentials of a request, verified on first use. Requests with an auth header are
+// verified by it, others by their presigned query if they have one
+func getRequestAuth(req *http.Request) *requestAuth {
+	if value, ok := context.GetOk(req, requestAuthKey); ok {
+		return value.(*requestAuth)
+	}
+	a := &requestAuth{}
+	defer context.Set(req, requestAuthKey, a)
+
+	authHeader := req.Header.Get("Authorization")
+	presigned := authHeader == "" && req.URL.Query().Get("X-Amz-Credential") != ""
+	if authHeader == "" && !presigned {
+		return a
+	}
+	if a.config, a.err = auth.LoadConfig(); a.err != nil {
+		return a
+	}
+	var ok bool
+	var err error
+	if presigned {
+		if a.signature, a.err = initPresignedSignatureV4(req, a.config); a.err != nil {
+			return a
+		}
+		ok, err = a.signature.DoesPresignedSignatureMatch()
+	} else {
+		if a.signature, a.err = initSignatureV4(req, a.config); a.err != nil {
+			return a
+		}
+		ok, err = a.signature.DoesSignatureMatch(req.Header.Get("X-Amz-Content-Sha256"))
+	}
+	if err == nil && ok {
+		a.accessKey = a.signature.AccessKeyID
+	}
+	return a
+}
+
 // InitSignatureV4 initializing signature verification
 func InitSignatureV4(req *http.Request) (*donut.Signature, error) {
+	if req.Header.Get("Authorization") == "" {
+		return nil, iodine.New(errors.New("Missing auth header"), nil)
+	}
+	a := getRequestAuth(req)
+	if a.err != nil {
+		return nil, iodine.New(a.err, nil)
+	}
+	return a.signature, nil
+}
+
+// initSignatureV4 - signature of the auth header of a request, by a user of authConfig
+func initSignatureV4(req *http.Request, authConfig *auth.Config) (*donut.Signature, error) {
 	// strip auth from authorization header
 	ah := req.Header.Get("Authorization")
 	accessKeyID, err := StripAccessKeyID(ah)
 	if err != nil {
 		return nil, iodine.New(err, nil)
 	}
-	authConfig, err := auth.LoadConfig()
-	if _, ok := authConfig.Users[accessKeyID]; !ok {
+	user, ok := authConfig.Users[accessKeyID]
+	if !ok {
 		return nil, errors.New("Access ID not found")
 	}
+	if !authConfig.IsEnabled(user) {
+		return nil, iodine.New(errDisabledUser, nil)
+	}
+	if err := checkSessionToken(user, req); err != nil {
+		return nil, iodine.New(err, nil)
+	}
 	signature := &donut.Signature{
-		AccessKeyID:     authConfig.Users[accessKeyID].AccessKeyID,
-		SecretAccessKey: authConfig.Users[accessKeyID].SecretAccessKey,
+		AccessKeyID:     user.AccessKeyID,
+		SecretAccessKey: user.SecretAccessKey,
 		AuthHeader:      ah,
 		Request:         req,
 	}
+	// STS clients sign AssumeRole for sts, everything else is signed for s3
+	if getAPIName(req) == "AssumeRole" && getCredentialService(ah) == "sts" {
+		signature.Service = "sts"
+	}
 	return signature, nil
 }
+
+// InitPresignedSignatureV4 initializing query string signature verification of a presigned url
+func InitPresignedSignatureV4(req *http.Request) (*donut.Signature, error) {
+	// requests with an auth header are verified by it, their presigned query is looked at only here
+	if req.Header.Get("Authorization") != "" {
+		authConfig, err := auth.LoadConfig()
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		return initPresignedSignatureV4(req, authConfig)
+	}
+	a := getRequestAuth(req)
+	if a.err != nil {
+		return nil, iodine.New(a.err, nil)
+	}
+	if a.signature == nil {
+		return nil, iodine.New(errors.New("Missing fields in credential"), nil)
+	}
+	return a.signature, nil
+}
+
+// initPresignedSignatureV4 - signature of the presigned query of a request, by a user of authConfig
+func initPresignedSignatureV4(req *http.Request, authConfig *auth.Config) (*donut.Signature, error) {
+	// credential is of the form <access key id>/<date>/<region>/s3/aws4_request
+	credential := strings.Split(req.URL.Query().Get("X-Amz-Credential"), "/")
+	if len(credential) != 5 {
+		return nil, errors.New("Missing fields in credential")
+	}
+	accessKeyID := credential[0]
+	if !auth.IsValidAccessKey(accessKeyID) {
+		return nil, errors.New("Invalid access key")
+	}
+	user, ok := authConfig.Users[accessKeyID]
+	if !ok {
+		return nil, errors.New("Access ID not found")
+	}
+	if !authConfig.IsEnabled(user) {
+		return nil, iodine.New(errDisabledUser, nil)
+	}
+	if err := checkSessionToken(user, req); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	signature := &donut.Signature{
+		AccessKeyID:     user.AccessKeyID,
+		SecretAccessKey: user.SecretAccessKey,
+		Request:         req,
+	}
+	return signature, nil
+}
+
+// getVerifiedAccessKey - access key of a request whose signature matches, the payload is verified
+// against its claimed checksum later on. Empty for anonymous requests and requests failing verification
+func getVerifiedAccessKey(req *http.Request) string {
+	return getRequestAuth(req).accessKey
+}
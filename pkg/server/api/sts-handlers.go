@@ -0,0 +1,139 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio/pkg/auth"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// IAM action of AssumeRole, it is open to every user
+const assumeRoleAction = "sts:AssumeRole"
+
+// AssumeRole request parameters are read from bodies of at most this many bytes
+const maxAssumeRoleBody = 64 * 1024
+
+// AssumeRoleHandler - POST / with Action=AssumeRole
+// -------------------------
+// This operation mints temporary credentials for the user signing the request, an access key,
+// secret access key and session token lasting DurationSeconds. An inline Policy limits them
+// further, they are never allowed more than the user. Sessions cannot assume roles themselves.
+//
+func (api Minio) AssumeRoleHandler(w http.ResponseWriter, req *http.Request) {
+	// ticket master block
+	op, ok := api.waitForTicket(w, req, WriteOperation)
+	if !ok {
+		return
+	}
+	defer api.releaseTicket(op)
+
+	acceptsContentType := getContentType(req)
+	// temporary credentials are only ever minted for signed requests
+	if _, ok := req.Header["Authorization"]; !ok {
+		writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+		return
+	}
+	var content []byte
+	if req.Body != nil {
+		var err error
+		content, err = ioutil.ReadAll(io.LimitReader(req.Body, maxAssumeRoleBody+1))
+		if err != nil {
+			writeErrorResponse(w, req, IncompleteBody, acceptsContentType, req.URL.Path)
+			return
+		}
+		if len(content) > maxAssumeRoleBody {
+			writeErrorResponse(w, req, EntityTooLarge, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	signature, err := InitSignatureV4(req)
+	if err != nil {
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	// the signature covers the parameters, they are in the body
+	sum := sha256.Sum256(content)
+	ok, err = signature.DoesSignatureMatch(hex.EncodeToString(sum[:]))
+	if err != nil || !ok {
+		writeErrorResponse(w, req, SignatureDoesNotMatch, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	params, err := url.ParseQuery(string(content))
+	if err != nil {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	for key, values := range req.URL.Query() {
+		if _, ok := params[key]; !ok {
+			params[key] = values
+		}
+	}
+	if params.Get("Action") != "AssumeRole" {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	duration := auth.DefaultSessionDuration
+	if durationSeconds := params.Get("DurationSeconds"); durationSeconds != "" {
+		seconds, err := strconv.Atoi(durationSeconds)
+		duration = time.Duration(seconds) * time.Second
+		if err != nil || duration < auth.MinSessionDuration || duration > auth.MaxSessionDuration {
+			writeErrorResponse(w, req, InvalidParameterValue, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	var policy *auth.PolicyDocument
+	if document := params.Get("Policy"); document != "" {
+		if policy, err = auth.ParsePolicyDocument([]byte(document)); err != nil {
+			writeErrorResponse(w, req, MalformedPolicyDocument, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+
+	authConfig, err := auth.LoadConfig()
+	if err != nil {
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	if user := authConfig.Users[signature.AccessKeyID]; user == nil || user.Session != nil {
+		writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+		return
+	}
+	session, err := auth.AssumeRole(signature.AccessKeyID, policy, duration)
+	if err != nil {
+		log.Error.Println(iodine.New(err, requestErrorState(w)))
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	response := generateAssumeRoleResponse(session, getRequestID(w))
+	encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+	// write headers
+	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+	// write body
+	w.Write(encodedSuccessResponse)
+}
@@ -0,0 +1,232 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// entries a tracer has not read yet are queued up to this many, later ones are dropped
+const traceQueueSize = 1000
+
+// request and response headers carrying secrets, their values are never traced
+var redactedHeaders = []string{"Authorization", "X-Amz-Security-Token"}
+
+// TraceEntry - an API call as streamed to tracers, one json object per line
+type TraceEntry struct {
+	Time            time.Time     `json:"time"`
+	RequestID       string        `json:"requestID,omitempty"`
+	AccessKey       string        `json:"accessKey,omitempty"`
+	API             string        `json:"api"`
+	Method          string        `json:"method"`
+	URI             string        `json:"uri"`
+	Bucket          string        `json:"bucket,omitempty"`
+	Status          int           `json:"status"`
+	Duration        time.Duration `json:"duration"`
+	BytesReceived   int64         `json:"bytesReceived"`
+	BytesSent       int64         `json:"bytesSent"`
+	RequestHeaders  http.Header   `json:"requestHeaders,omitempty"`
+	ResponseHeaders http.Header   `json:"responseHeaders,omitempty"`
+	// Dropped counts the entries dropped before this one, the tracer did not keep up with them
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// newTraceEntry - trace entry of a logged request, with its headers if asked for. Credentials in its
+// query and headers are redacted
+func newTraceEntry(entry accessLogEntry, req *http.Request, w http.ResponseWriter, headers bool) TraceEntry {
+	traceEntry := TraceEntry{
+		Time:          entry.Time,
+		RequestID:     entry.RequestID,
+		AccessKey:     entry.AccessKey,
+		API:           entry.API,
+		Method:        entry.Method,
+		URI:           getRedactedRequestURI(req),
+		Bucket:        entry.Bucket,
+		Status:        entry.Status,
+		Duration:      entry.Duration,
+		BytesReceived: entry.BytesReceived,
+		BytesSent:     entry.BytesSent,
+	}
+	if headers {
+		traceEntry.RequestHeaders = getRedactedHeaders(req.Header)
+		traceEntry.ResponseHeaders = getRedactedHeaders(w.Header())
+	}
+	return traceEntry
+}
+
+// getRedactedHeaders - copy of headers with credentials replaced
+func getRedactedHeaders(headers http.Header) http.Header {
+	redacted := make(http.Header)
+	for key, values := range headers {
+		redacted[key] = values
+	}
+	for _, key := range redactedHeaders {
+		if _, ok := redacted[key]; ok {
+			redacted.Set(key, "REDACTED")
+		}
+	}
+	return redacted
+}
+
+// traceSubscriber - an attached tracer and the calls it asked for
+type traceSubscriber struct {
+	entries chan TraceEntry
+	dropped uint64
+
+	bucket    string
+	status    string
+	accessKey string
+	headers   bool
+}
+
+// matches - whether a tracer asked for an entry, status is a code like "404" or a class like "5xx"
+func (s *traceSubscriber) matches(entry TraceEntry) bool {
+	if s.bucket != "" && s.bucket != entry.Bucket {
+		return false
+	}
+	if s.accessKey != "" && s.accessKey != entry.AccessKey {
+		return false
+	}
+	if s.status != "" {
+		status := strconv.Itoa(entry.Status)
+		if strings.HasSuffix(s.status, "xx") {
+			return strings.HasPrefix(status, strings.TrimSuffix(s.status, "xx"))
+		}
+		return status == s.status
+	}
+	return true
+}
+
+// Tracer streams API calls to attached tracers as they are served. Nothing is traced while no tracer
+// is attached
+type Tracer struct {
+	lock        *sync.Mutex
+	subscribers map[*traceSubscriber]bool
+	// attached mirrors len(subscribers), read without the lock on every request
+	attached int32
+	headers  int32
+}
+
+// NewTracer - tracer without any tracers attached
+func NewTracer() *Tracer {
+	return &Tracer{
+		lock:        new(sync.Mutex),
+		subscribers: make(map[*traceSubscriber]bool),
+	}
+}
+
+// isAttached - whether any tracer is attached, a nil Tracer never is
+func (t *Tracer) isAttached() bool {
+	return t != nil && atomic.LoadInt32(&t.attached) > 0
+}
+
+// wantsHeaders - whether any attached tracer asked for headers
+func (t *Tracer) wantsHeaders() bool {
+	return atomic.LoadInt32(&t.headers) > 0
+}
+
+func (t *Tracer) subscribe(subscriber *traceSubscriber) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.subscribers[subscriber] = true
+	atomic.AddInt32(&t.attached, 1)
+	if subscriber.headers {
+		atomic.AddInt32(&t.headers, 1)
+	}
+}
+
+func (t *Tracer) unsubscribe(subscriber *traceSubscriber) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.subscribers, subscriber)
+	atomic.AddInt32(&t.attached, -1)
+	if subscriber.headers {
+		atomic.AddInt32(&t.headers, -1)
+	}
+}
+
+// publish - queue an entry to every tracer which asked for it, a tracer falling behind never slows
+// requests down, entries it has no room for are dropped
+func (t *Tracer) publish(entry TraceEntry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for subscriber := range t.subscribers {
+		if !subscriber.matches(entry) {
+			continue
+		}
+		subscriberEntry := entry
+		if !subscriber.headers {
+			subscriberEntry.RequestHeaders = nil
+			subscriberEntry.ResponseHeaders = nil
+		}
+		subscriberEntry.Dropped = subscriber.dropped
+		select {
+		case subscriber.entries <- subscriberEntry:
+			subscriber.dropped = 0
+		default:
+			subscriber.dropped++
+		}
+	}
+}
+
+// ServeHTTP - stream API calls to a tracer until it goes away. Calls are filtered by the query
+// parameters bucket, status and accesskey, headers=true adds the request and response headers
+func (t *Tracer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	headers, _ := strconv.ParseBool(query.Get("headers"))
+	subscriber := &traceSubscriber{
+		entries:   make(chan TraceEntry, traceQueueSize),
+		bucket:    query.Get("bucket"),
+		status:    query.Get("status"),
+		accessKey: query.Get("accesskey"),
+		headers:   headers,
+	}
+	t.subscribe(subscriber)
+	defer t.unsubscribe(subscriber)
+
+	var closed <-chan bool
+	if closeNotifier, ok := w.(http.CloseNotifier); ok {
+		closed = closeNotifier.CloseNotify()
+	}
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	// the tracer knows it is attached once the headers reach it
+	if flusher != nil {
+		flusher.Flush()
+	}
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case entry := <-subscriber.entries:
+			if err := encoder.Encode(entry); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -18,6 +18,7 @@ package api
 
 import (
 	"encoding/base64"
+	"net/http"
 	"strconv"
 	"strings"
 )
@@ -34,6 +35,59 @@ func isValidMD5(md5 string) bool {
 	return true
 }
 
+// getWritePreconditions - read conditional write headers 'If-Match' and 'If-None-Match'
+func getWritePreconditions(req *http.Request) map[string]string {
+	metadata := make(map[string]string)
+	if ifMatch := req.Header.Get("If-Match"); ifMatch != "" {
+		metadata["ifMatch"] = ifMatch
+	}
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		metadata["ifNoneMatch"] = ifNoneMatch
+	}
+	return metadata
+}
+
+// getResponseHeaderOverrides - read 'response-*' query parameters overriding headers of a GET response
+func getResponseHeaderOverrides(req *http.Request) map[string]string {
+	overrides := make(map[string]string)
+	values := req.URL.Query()
+	for param, header := range responseHeaderOverrides {
+		if value := values.Get(param); value != "" {
+			overrides[header] = value
+		}
+	}
+	return overrides
+}
+
+// getObjectMetadataFromRequest - read standard object headers and 'x-amz-meta-' user metadata to be persisted
+func getObjectMetadataFromRequest(req *http.Request) map[string]string {
+	metadata := make(map[string]string)
+	for header, key := range objectMetadataHeaders {
+		if value := req.Header.Get(header); value != "" {
+			metadata[key] = value
+		}
+	}
+	for header, values := range req.Header {
+		header = strings.ToLower(header)
+		if strings.HasPrefix(header, userMetadataPrefix) {
+			metadata[header] = strings.Join(values, ",")
+		}
+	}
+	return metadata
+}
+
+// isMaxUserMetadataSize - verify if user metadata exceeds the maximum allowed size,
+// size is the sum of bytes of all 'x-amz-meta-' keys and their values
+func isMaxUserMetadataSize(metadata map[string]string) bool {
+	size := 0
+	for key, value := range metadata {
+		if strings.HasPrefix(key, userMetadataPrefix) {
+			size += len(key) + len(value)
+		}
+	}
+	return size > maxUserMetadataSize
+}
+
 /// http://docs.aws.amazon.com/AmazonS3/latest/dev/UploadingObjects.html
 const (
 	// maximum object size per PUT request is 5GB
@@ -42,6 +96,8 @@ const (
 	minMultiPartObjectSize = 1024 * 1024 * 5
 	// minimum object size per PUT request is 1B
 	minObjectSize = 1
+	// maximum size of user metadata per object is 2KB
+	maxUserMetadataSize = 2 * 1024
 )
 
 // isMaxObjectSize - verify if max object size
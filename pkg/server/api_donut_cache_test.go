@@ -18,9 +18,13 @@ package server
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -55,8 +59,14 @@ func (s *MyAPIDonutCacheSuite) SetUpSuite(c *C) {
 	err = donut.SaveConfig(conf)
 	c.Assert(err, IsNil)
 
-	httpHandler, minioAPI := getAPIHandler(api.Config{RateLimit: 16})
-	go startTM(minioAPI)
+	apiConf := api.Config{
+		RateLimit: 16,
+		AccessLog: filepath.Join(root, "access.log"),
+		AuditLog:  filepath.Join(root, "audit.log"),
+	}
+	httpHandler, minioAPI, err := getAPIHandler(apiConf)
+	c.Assert(err, IsNil)
+	go startTM(minioAPI, apiConf)
 	testAPIDonutCacheServer = httptest.NewServer(httpHandler)
 }
 
@@ -106,6 +116,138 @@ func (s *MyAPIDonutCacheSuite) TestEmptyObject(c *C) {
 	c.Assert(true, Equals, bytes.Equal(responseBody, buffer.Bytes()))
 }
 
+func (s *MyAPIDonutCacheSuite) TestAccessLog(c *C) {
+	request, err := http.NewRequest("GET", testAPIDonutCacheServer.URL+"/accesslog/object?X-Amz-Credential=minio/20150101/us-east-1/s3/aws4_request&X-Amz-Signature=secret", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	response.Body.Close()
+
+	accessLog, err := ioutil.ReadFile(filepath.Join(s.root, "access.log"))
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(accessLog), "secret"), Equals, false)
+	var found bool
+	for _, line := range strings.Split(string(accessLog), "\n") {
+		if !strings.Contains(line, "/accesslog/object") {
+			continue
+		}
+		found = true
+		c.Assert(strings.Contains(line, `"accessKey":"minio"`), Equals, true)
+		c.Assert(strings.Contains(line, `"bucket":"accesslog"`), Equals, true)
+		c.Assert(strings.Contains(line, `"object":"object"`), Equals, true)
+		c.Assert(strings.Contains(line, "X-Amz-Signature=REDACTED"), Equals, true)
+		c.Assert(strings.Contains(line, `"status":`+strconv.Itoa(response.StatusCode)), Equals, true)
+		c.Assert(strings.Contains(line, `"requestID":"`+response.Header.Get("X-Amz-Request-Id")+`"`), Equals, true)
+	}
+	c.Assert(found, Equals, true)
+}
+
+func (s *MyAPIDonutCacheSuite) TestRequestID(c *C) {
+	client := http.Client{}
+	var requestIDs []string
+	for i := 0; i < 2; i++ {
+		response, err := client.Get(testAPIDonutCacheServer.URL + "/")
+		c.Assert(err, IsNil)
+		response.Body.Close()
+		c.Assert(len(response.Header.Get("X-Amz-Request-Id")), Equals, 16)
+		c.Assert(response.Header.Get("X-Amz-Id-2"), Not(Equals), "")
+		requestIDs = append(requestIDs, response.Header.Get("X-Amz-Request-Id"))
+	}
+	c.Assert(requestIDs[0], Not(Equals), requestIDs[1])
+}
+
+func (s *MyAPIDonutCacheSuite) TestAccessLogRotation(c *C) {
+	apiConf := api.Config{
+		AccessLog:        filepath.Join(s.root, "rotated", "access.log"),
+		AccessLogFormat:  api.CombinedAccessLog,
+		AccessLogMaxSize: 1,
+		AccessLogBackups: 2,
+	}
+	c.Assert(os.MkdirAll(filepath.Dir(apiConf.AccessLog), 0700), IsNil)
+	// files which merely share the name of the log are not rotated logs
+	unrelated := apiConf.AccessLog + ".old"
+	c.Assert(ioutil.WriteFile(unrelated, []byte("kept"), 0600), IsNil)
+	httpHandler, minioAPI, err := getAPIHandler(apiConf)
+	c.Assert(err, IsNil)
+	go startTM(minioAPI, apiConf)
+	server := httptest.NewServer(httpHandler)
+	defer server.Close()
+
+	// every line is larger than the limit and gets a log of its own
+	client := http.Client{}
+	for i := 0; i < 4; i++ {
+		response, err := client.Get(server.URL + "/")
+		c.Assert(err, IsNil)
+		response.Body.Close()
+	}
+	c.Assert(minioAPI.AccessLog.Close(), IsNil)
+	files, err := ioutil.ReadDir(filepath.Dir(apiConf.AccessLog))
+	c.Assert(err, IsNil)
+	c.Assert(len(files), Equals, 4)
+	_, err = os.Stat(unrelated)
+	c.Assert(err, IsNil)
+
+	accessLog, err := ioutil.ReadFile(apiConf.AccessLog)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Count(string(accessLog), "\n"), Equals, 1)
+	c.Assert(strings.Contains(string(accessLog), `] "GET / HTTP/1.1" 200 `), Equals, true)
+
+	_, _, err = getAPIHandler(api.Config{AccessLog: apiConf.AccessLog, AccessLogFormat: "unknown"})
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *MyAPIDonutCacheSuite) TestAuditLog(c *C) {
+	client := http.Client{}
+	request, err := http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/auditlog", nil)
+	c.Assert(err, IsNil)
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/auditlog/object", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	c.Assert(response.Header.Get("ETag"), Not(Equals), "")
+
+	// reads are not audited
+	response, err = client.Get(testAPIDonutCacheServer.URL + "/auditlog/object")
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	auditLogPath := filepath.Join(s.root, "audit.log")
+	auditLog, err := ioutil.ReadFile(auditLogPath)
+	c.Assert(err, IsNil)
+	c.Assert(api.VerifyAuditLog(auditLogPath), IsNil)
+	var records []string
+	for _, line := range strings.Split(string(auditLog), "\n") {
+		if strings.Contains(line, `"bucket":"auditlog"`) {
+			records = append(records, line)
+		}
+	}
+	c.Assert(len(records), Equals, 2)
+	c.Assert(strings.Contains(records[0], `"api":"PutBucket","bucket":"auditlog","status":200`), Equals, true)
+	c.Assert(strings.Contains(records[1], `"api":"PutObject","bucket":"auditlog","object":"object","status":200`), Equals, true)
+	c.Assert(strings.Contains(records[1], `"bytesReceived":11`), Equals, true)
+
+	// nothing is acknowledged which is not audited
+	apiConf := api.Config{AuditLog: filepath.Join(s.root, "closed-audit.log")}
+	httpHandler, minioAPI, err := getAPIHandler(apiConf)
+	c.Assert(err, IsNil)
+	go startTM(minioAPI, apiConf)
+	server := httptest.NewServer(httpHandler)
+	defer server.Close()
+	c.Assert(minioAPI.AuditLog.Close(), IsNil)
+	request, err = http.NewRequest("PUT", server.URL+"/auditlog-closed", nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InternalError", "We encountered an internal error, please try again.", http.StatusInternalServerError)
+}
+
 func (s *MyAPIDonutCacheSuite) TestBucket(c *C) {
 	request, err := http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/bucket", nil)
 	c.Assert(err, IsNil)
@@ -294,6 +436,75 @@ func (s *MyAPIDonutCacheSuite) TestPutObject(c *C) {
 	c.Assert(response.StatusCode, Equals, http.StatusOK)
 }
 
+func (s *MyAPIDonutCacheSuite) TestPutObjectConditional(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/put-object-conditional", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/put-object-conditional/object", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	request.Header.Add("If-None-Match", "*")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	etag := response.Header.Get("ETag")
+
+	request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/put-object-conditional/object", bytes.NewBufferString("hello again"))
+	c.Assert(err, IsNil)
+	request.Header.Add("If-None-Match", "*")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "PreconditionFailed", "At least one of the preconditions you specified did not hold.", http.StatusPreconditionFailed)
+
+	request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/put-object-conditional/object", bytes.NewBufferString("hello again"))
+	c.Assert(err, IsNil)
+	request.Header.Add("If-Match", "\"d41d8cd98f00b204e9800998ecf8427e\"")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "PreconditionFailed", "At least one of the preconditions you specified did not hold.", http.StatusPreconditionFailed)
+
+	request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/put-object-conditional/object", bytes.NewBufferString("hello again"))
+	c.Assert(err, IsNil)
+	request.Header.Add("If-Match", "\""+etag+"\"")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	c.Assert(response.Header.Get("ETag"), Not(Equals), etag)
+
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/put-object-conditional/object", nil)
+	c.Assert(err, IsNil)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	object, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(object), Equals, "hello again")
+
+	request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/put-object-conditional/nonexistent", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	request.Header.Add("If-Match", "\""+etag+"\"")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "PreconditionFailed", "At least one of the preconditions you specified did not hold.", http.StatusPreconditionFailed)
+
+	request, err = http.NewRequest("HEAD", testAPIDonutCacheServer.URL+"/put-object-conditional/nonexistent", nil)
+	c.Assert(err, IsNil)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusNotFound)
+}
+
 func (s *MyAPIDonutCacheSuite) TestListBuckets(c *C) {
 	request, err := http.NewRequest("GET", testAPIDonutCacheServer.URL+"/", nil)
 	c.Assert(err, IsNil)
@@ -309,6 +520,32 @@ func (s *MyAPIDonutCacheSuite) TestListBuckets(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *MyAPIDonutCacheSuite) TestMetrics(c *C) {
+	request, err := http.NewRequest("GET", testAPIDonutCacheServer.URL+"/", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/metrics", nil)
+	c.Assert(err, IsNil)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	c.Assert(strings.HasPrefix(response.Header.Get("Content-Type"), "text/plain"), Equals, true)
+
+	metrics, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(metrics), `minio_http_requests_total{api="ListBuckets",status="200"}`), Equals, true)
+	c.Assert(strings.Contains(string(metrics), `minio_http_request_duration_seconds_bucket{api="ListBuckets",status="200",le="+Inf"}`), Equals, true)
+	c.Assert(strings.Contains(string(metrics), "\nminio_cache_hit_ratio "), Equals, true)
+	c.Assert(strings.Contains(string(metrics), "\nminio_multipart_sessions "), Equals, true)
+	c.Assert(strings.Contains(string(metrics), "\nminio_ticket_master_queued_operations 0\n"), Equals, true)
+}
+
 func (s *MyAPIDonutCacheSuite) TestNotBeAbleToCreateObjectInNonexistantBucket(c *C) {
 	request, err := http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/innonexistantbucket/object", bytes.NewBufferString("hello world"))
 	c.Assert(err, IsNil)
@@ -470,14 +707,117 @@ func (s *MyAPIDonutCacheSuite) TestContentTypePersists(c *C) {
 
 	response, err = client.Do(request)
 	c.Assert(err, IsNil)
-	c.Assert(response.Header.Get("Content-Type"), Equals, "application/octet-stream")
+	c.Assert(response.Header.Get("Content-Type"), Equals, "application/json")
 
 	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/contenttype-persists/two", nil)
 	c.Assert(err, IsNil)
 
 	response, err = client.Do(request)
 	c.Assert(err, IsNil)
-	c.Assert(response.Header.Get("Content-Type"), Equals, "application/octet-stream")
+	c.Assert(response.Header.Get("Content-Type"), Equals, "application/json")
+}
+
+func (s *MyAPIDonutCacheSuite) TestObjectMetadataPersists(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/metadata-persists", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/metadata-persists/one", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	request.Header.Add("x-amz-meta-provenance", "pipeline-42")
+	request.Header.Add("Content-Disposition", "attachment; filename=\"one.txt\"")
+	request.Header.Add("Cache-Control", "no-cache")
+	request.Header.Add("Content-Encoding", "identity")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	for _, method := range []string{"HEAD", "GET"} {
+		request, err = http.NewRequest(method, testAPIDonutCacheServer.URL+"/metadata-persists/one", nil)
+		c.Assert(err, IsNil)
+
+		response, err = client.Do(request)
+		c.Assert(err, IsNil)
+		c.Assert(response.StatusCode, Equals, http.StatusOK)
+		c.Assert(response.Header.Get("x-amz-meta-provenance"), Equals, "pipeline-42")
+		c.Assert(response.Header.Get("Content-Disposition"), Equals, "attachment; filename=\"one.txt\"")
+		c.Assert(response.Header.Get("Cache-Control"), Equals, "no-cache")
+		c.Assert(response.Header.Get("Content-Encoding"), Equals, "identity")
+	}
+
+	request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/metadata-persists/two", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	request.Header.Add("x-amz-meta-provenance", strings.Repeat("a", 2*1024))
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "MetadataTooLarge", "Your metadata headers exceed the maximum allowed metadata size.", http.StatusBadRequest)
+}
+
+func (s *MyAPIDonutCacheSuite) TestMultipartMetadataPersists(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/multipart-metadata", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	// metadata is only sent when the upload is initiated
+	request, err = http.NewRequest("POST", testAPIDonutCacheServer.URL+"/multipart-metadata/object?uploads", nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Content-Type", "text/plain")
+	request.Header.Add("x-amz-meta-provenance", "pipeline-42")
+	request.Header.Add("Content-Disposition", "attachment; filename=\"object.txt\"")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	newResponse := &api.InitiateMultipartUploadResponse{}
+	c.Assert(xml.NewDecoder(response.Body).Decode(newResponse), IsNil)
+	uploadID := newResponse.UploadID
+
+	request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/multipart-metadata/object?uploadId="+uploadID+"&partNumber=1", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	completeUploads := &donut.CompleteMultipartUpload{
+		Part: []donut.CompletePart{
+			{
+				PartNumber: 1,
+				ETag:       response.Header.Get("ETag"),
+			},
+		},
+	}
+	var completeBuffer bytes.Buffer
+	c.Assert(xml.NewEncoder(&completeBuffer).Encode(completeUploads), IsNil)
+
+	request, err = http.NewRequest("POST", testAPIDonutCacheServer.URL+"/multipart-metadata/object?uploadId="+uploadID, &completeBuffer)
+	c.Assert(err, IsNil)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	for _, method := range []string{"HEAD", "GET"} {
+		request, err = http.NewRequest(method, testAPIDonutCacheServer.URL+"/multipart-metadata/object", nil)
+		c.Assert(err, IsNil)
+
+		response, err = client.Do(request)
+		c.Assert(err, IsNil)
+		c.Assert(response.StatusCode, Equals, http.StatusOK)
+		c.Assert(response.Header.Get("Content-Type"), Equals, "text/plain")
+		c.Assert(response.Header.Get("x-amz-meta-provenance"), Equals, "pipeline-42")
+		c.Assert(response.Header.Get("Content-Disposition"), Equals, "attachment; filename=\"object.txt\"")
+	}
 }
 
 func (s *MyAPIDonutCacheSuite) TestPartialContent(c *C) {
@@ -513,6 +853,242 @@ func (s *MyAPIDonutCacheSuite) TestPartialContent(c *C) {
 	c.Assert(string(partialObject), Equals, "Wo")
 }
 
+func (s *MyAPIDonutCacheSuite) TestMultipleRanges(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/multiple-ranges", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/multiple-ranges/bar", bytes.NewBufferString("Hello World"))
+	c.Assert(err, IsNil)
+	request.Header.Add("Content-Type", "text/plain")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	// overlapping ranges are coalesced
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/multiple-ranges/bar", nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Range", "bytes=7-8,0-1,6-7")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusPartialContent)
+	mediaType, params, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	c.Assert(err, IsNil)
+	c.Assert(mediaType, Equals, "multipart/byteranges")
+
+	body, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Assert(response.Header.Get("Content-Length"), Equals, strconv.Itoa(len(body)))
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	part, err := reader.NextPart()
+	c.Assert(err, IsNil)
+	c.Assert(part.Header.Get("Content-Range"), Equals, "bytes 0-1/11")
+	c.Assert(part.Header.Get("Content-Type"), Equals, "text/plain")
+	partData, err := ioutil.ReadAll(part)
+	c.Assert(err, IsNil)
+	c.Assert(string(partData), Equals, "He")
+
+	part, err = reader.NextPart()
+	c.Assert(err, IsNil)
+	c.Assert(part.Header.Get("Content-Range"), Equals, "bytes 6-8/11")
+	partData, err = ioutil.ReadAll(part)
+	c.Assert(err, IsNil)
+	c.Assert(string(partData), Equals, "Wor")
+
+	_, err = reader.NextPart()
+	c.Assert(err, Equals, io.EOF)
+
+	// ranges coalescing into one are sent as a single range
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/multiple-ranges/bar", nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Range", "bytes=0-3,2-5")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusPartialContent)
+	c.Assert(response.Header.Get("Content-Range"), Equals, "bytes 0-5/11")
+	partialObject, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(partialObject), Equals, "Hello ")
+
+	// unsatisfiable ranges are dropped as long as one of the ranges can be satisfied
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/multiple-ranges/bar", nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Range", "bytes=20-30,0-1,11-")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusPartialContent)
+	c.Assert(response.Header.Get("Content-Range"), Equals, "bytes 0-1/11")
+	partialObject, err = ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(partialObject), Equals, "He")
+
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/multiple-ranges/bar", nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Range", "bytes=20-30,11-")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidRange", "The requested range cannot be satisfied.", http.StatusRequestedRangeNotSatisfiable)
+}
+
+func (s *MyAPIDonutCacheSuite) TestResponseHeaderOverridesAnonymous(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/response-overrides-anonymous", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/response-overrides-anonymous/bar", bytes.NewBufferString("Hello World"))
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/response-overrides-anonymous/bar?response-content-type=text%2Fplain", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidRequest", "The request is not valid, please verify your request parameters.", http.StatusBadRequest)
+}
+
+func (s *MyAPIDonutCacheSuite) TestListObjectsV2(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/list-objects-v2", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	for _, object := range []string{"a", "b", "c"} {
+		request, err = http.NewRequest("PUT", testAPIDonutCacheServer.URL+"/list-objects-v2/"+object, bytes.NewBufferString("hello world"))
+		c.Assert(err, IsNil)
+
+		client = http.Client{}
+		response, err = client.Do(request)
+		c.Assert(err, IsNil)
+		c.Assert(response.StatusCode, Equals, http.StatusOK)
+	}
+
+	// version 1 always returns NextMarker on truncated results
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/list-objects-v2?max-keys=2", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	var v1Results api.ListObjectsResponse
+	err = xml.NewDecoder(response.Body).Decode(&v1Results)
+	c.Assert(err, IsNil)
+	c.Assert(v1Results.IsTruncated, Equals, true)
+	c.Assert(v1Results.NextMarker, Equals, "b")
+
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/list-objects-v2?list-type=2&max-keys=2", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	var results api.ListObjectsV2Response
+	err = xml.NewDecoder(response.Body).Decode(&results)
+	c.Assert(err, IsNil)
+	c.Assert(results.IsTruncated, Equals, true)
+	c.Assert(results.KeyCount, Equals, 2)
+	c.Assert(len(results.Contents), Equals, 2)
+	c.Assert(results.Contents[0].Key, Equals, "a")
+	c.Assert(results.Contents[0].Owner, IsNil)
+	c.Assert(results.NextContinuationToken, Not(Equals), "")
+
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/list-objects-v2?list-type=2&max-keys=2&fetch-owner=true&continuation-token="+results.NextContinuationToken, nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	results = api.ListObjectsV2Response{}
+	err = xml.NewDecoder(response.Body).Decode(&results)
+	c.Assert(err, IsNil)
+	c.Assert(results.IsTruncated, Equals, false)
+	c.Assert(results.KeyCount, Equals, 1)
+	c.Assert(results.Contents[0].Key, Equals, "c")
+	c.Assert(results.Contents[0].Owner, Not(IsNil))
+	c.Assert(results.NextContinuationToken, Equals, "")
+
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/list-objects-v2?list-type=2&start-after=a", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	results = api.ListObjectsV2Response{}
+	err = xml.NewDecoder(response.Body).Decode(&results)
+	c.Assert(err, IsNil)
+	c.Assert(results.StartAfter, Equals, "a")
+	c.Assert(results.KeyCount, Equals, 2)
+	c.Assert(results.Contents[0].Key, Equals, "b")
+
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/list-objects-v2?list-type=2&continuation-token=%21%21", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidArgument", "One or more of the specified arguments are not valid.", http.StatusBadRequest)
+
+	// keys are url encoded on request
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/list-objects-v2?list-type=2&encoding-type=url&start-after=a%20b", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	results = api.ListObjectsV2Response{}
+	err = xml.NewDecoder(response.Body).Decode(&results)
+	c.Assert(err, IsNil)
+	c.Assert(results.EncodingType, Equals, "url")
+	c.Assert(results.StartAfter, Equals, "a+b")
+	c.Assert(results.KeyCount, Equals, 2)
+	c.Assert(results.Contents[0].Key, Equals, "b")
+
+	request, err = http.NewRequest("GET", testAPIDonutCacheServer.URL+"/list-objects-v2?list-type=2&encoding-type=base64", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidArgument", "One or more of the specified arguments are not valid.", http.StatusBadRequest)
+}
+
 func (s *MyAPIDonutCacheSuite) TestListObjectsHandlerErrors(c *C) {
 	request, err := http.NewRequest("GET", testAPIDonutCacheServer.URL+"/objecthandlererrors-.", nil)
 	c.Assert(err, IsNil)
@@ -856,4 +1432,8 @@ func verifyError(c *C, response *http.Response, code, description string, status
 	c.Assert(errorResponse.Code, Equals, code)
 	c.Assert(errorResponse.Message, Equals, description)
 	c.Assert(response.StatusCode, Equals, statusCode)
+	// errors name the request they failed, clients report it along with the failure
+	c.Assert(errorResponse.RequestID, Not(Equals), "")
+	c.Assert(errorResponse.RequestID, Equals, response.Header.Get("X-Amz-Request-Id"))
+	c.Assert(errorResponse.HostID, Equals, response.Header.Get("X-Amz-Id-2"))
 }
@@ -18,7 +18,10 @@ package server
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -74,8 +77,10 @@ func (s *MyAPIDonutSuite) SetUpSuite(c *C) {
 	err = donut.SaveConfig(conf)
 	c.Assert(err, IsNil)
 
-	httpHandler, minioAPI := getAPIHandler(api.Config{RateLimit: 16})
-	go startTM(minioAPI)
+	apiConf := api.Config{RateLimit: 16}
+	httpHandler, minioAPI, err := getAPIHandler(apiConf)
+	c.Assert(err, IsNil)
+	go startTM(minioAPI, apiConf)
 	testAPIDonutServer = httptest.NewServer(httpHandler)
 }
 
@@ -284,6 +289,25 @@ func (s *MyAPIDonutSuite) TestHeader(c *C) {
 	verifyError(c, response, "NoSuchKey", "The specified key does not exist.", http.StatusNotFound)
 }
 
+func (s *MyAPIDonutSuite) TestHealth(c *C) {
+	client := http.Client{}
+	for _, path := range []string{"/minio/health/live", "/minio/health/ready"} {
+		request, err := http.NewRequest("GET", testAPIDonutServer.URL+path, nil)
+		c.Assert(err, IsNil)
+		response, err := client.Do(request)
+		c.Assert(err, IsNil)
+		c.Assert(response.StatusCode, Equals, http.StatusOK)
+	}
+
+	// never reaches the auth layer, which would reject it
+	request, err := http.NewRequest("GET", testAPIDonutServer.URL+"/minio/health/ready", nil)
+	c.Assert(err, IsNil)
+	request.Header.Set("Authorization", "garbage")
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+}
+
 func (s *MyAPIDonutSuite) TestPutBucket(c *C) {
 	request, err := http.NewRequest("PUT", testAPIDonutServer.URL+"/put-bucket", nil)
 	c.Assert(err, IsNil)
@@ -313,6 +337,75 @@ func (s *MyAPIDonutSuite) TestPutObject(c *C) {
 	c.Assert(response.StatusCode, Equals, http.StatusOK)
 }
 
+func (s *MyAPIDonutSuite) TestPutObjectConditional(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutServer.URL+"/put-object-conditional", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("PUT", testAPIDonutServer.URL+"/put-object-conditional/object", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	request.Header.Add("If-None-Match", "*")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	etag := response.Header.Get("ETag")
+
+	request, err = http.NewRequest("PUT", testAPIDonutServer.URL+"/put-object-conditional/object", bytes.NewBufferString("hello again"))
+	c.Assert(err, IsNil)
+	request.Header.Add("If-None-Match", "*")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "PreconditionFailed", "At least one of the preconditions you specified did not hold.", http.StatusPreconditionFailed)
+
+	request, err = http.NewRequest("PUT", testAPIDonutServer.URL+"/put-object-conditional/object", bytes.NewBufferString("hello again"))
+	c.Assert(err, IsNil)
+	request.Header.Add("If-Match", "\"d41d8cd98f00b204e9800998ecf8427e\"")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "PreconditionFailed", "At least one of the preconditions you specified did not hold.", http.StatusPreconditionFailed)
+
+	request, err = http.NewRequest("PUT", testAPIDonutServer.URL+"/put-object-conditional/object", bytes.NewBufferString("hello again"))
+	c.Assert(err, IsNil)
+	request.Header.Add("If-Match", "\""+etag+"\"")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	c.Assert(response.Header.Get("ETag"), Not(Equals), etag)
+
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/put-object-conditional/object", nil)
+	c.Assert(err, IsNil)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	object, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(object), Equals, "hello again")
+
+	request, err = http.NewRequest("PUT", testAPIDonutServer.URL+"/put-object-conditional/nonexistent", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	request.Header.Add("If-Match", "\""+etag+"\"")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "PreconditionFailed", "At least one of the preconditions you specified did not hold.", http.StatusPreconditionFailed)
+
+	request, err = http.NewRequest("HEAD", testAPIDonutServer.URL+"/put-object-conditional/nonexistent", nil)
+	c.Assert(err, IsNil)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusNotFound)
+}
+
 func (s *MyAPIDonutSuite) TestListBuckets(c *C) {
 	request, err := http.NewRequest("GET", testAPIDonutServer.URL+"/", nil)
 	c.Assert(err, IsNil)
@@ -490,14 +583,117 @@ func (s *MyAPIDonutSuite) TestContentTypePersists(c *C) {
 
 	response, err = client.Do(request)
 	c.Assert(err, IsNil)
-	c.Assert(response.Header.Get("Content-Type"), Equals, "application/octet-stream")
+	c.Assert(response.Header.Get("Content-Type"), Equals, "application/json")
 
 	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/contenttype-persists/two", nil)
 	c.Assert(err, IsNil)
 
 	response, err = client.Do(request)
 	c.Assert(err, IsNil)
-	c.Assert(response.Header.Get("Content-Type"), Equals, "application/octet-stream")
+	c.Assert(response.Header.Get("Content-Type"), Equals, "application/json")
+}
+
+func (s *MyAPIDonutSuite) TestObjectMetadataPersists(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutServer.URL+"/metadata-persists", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("PUT", testAPIDonutServer.URL+"/metadata-persists/one", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	request.Header.Add("x-amz-meta-provenance", "pipeline-42")
+	request.Header.Add("Content-Disposition", "attachment; filename=\"one.txt\"")
+	request.Header.Add("Cache-Control", "no-cache")
+	request.Header.Add("Content-Encoding", "identity")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	for _, method := range []string{"HEAD", "GET"} {
+		request, err = http.NewRequest(method, testAPIDonutServer.URL+"/metadata-persists/one", nil)
+		c.Assert(err, IsNil)
+
+		response, err = client.Do(request)
+		c.Assert(err, IsNil)
+		c.Assert(response.StatusCode, Equals, http.StatusOK)
+		c.Assert(response.Header.Get("x-amz-meta-provenance"), Equals, "pipeline-42")
+		c.Assert(response.Header.Get("Content-Disposition"), Equals, "attachment; filename=\"one.txt\"")
+		c.Assert(response.Header.Get("Cache-Control"), Equals, "no-cache")
+		c.Assert(response.Header.Get("Content-Encoding"), Equals, "identity")
+	}
+
+	request, err = http.NewRequest("PUT", testAPIDonutServer.URL+"/metadata-persists/two", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	request.Header.Add("x-amz-meta-provenance", strings.Repeat("a", 2*1024))
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "MetadataTooLarge", "Your metadata headers exceed the maximum allowed metadata size.", http.StatusBadRequest)
+}
+
+func (s *MyAPIDonutSuite) TestMultipartMetadataPersists(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutServer.URL+"/multipart-metadata", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	// metadata is only sent when the upload is initiated
+	request, err = http.NewRequest("POST", testAPIDonutServer.URL+"/multipart-metadata/object?uploads", nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Content-Type", "text/plain")
+	request.Header.Add("x-amz-meta-provenance", "pipeline-42")
+	request.Header.Add("Content-Disposition", "attachment; filename=\"object.txt\"")
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	newResponse := &api.InitiateMultipartUploadResponse{}
+	c.Assert(xml.NewDecoder(response.Body).Decode(newResponse), IsNil)
+	uploadID := newResponse.UploadID
+
+	request, err = http.NewRequest("PUT", testAPIDonutServer.URL+"/multipart-metadata/object?uploadId="+uploadID+"&partNumber=1", bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	completeUploads := &donut.CompleteMultipartUpload{
+		Part: []donut.CompletePart{
+			{
+				PartNumber: 1,
+				ETag:       response.Header.Get("ETag"),
+			},
+		},
+	}
+	var completeBuffer bytes.Buffer
+	c.Assert(xml.NewEncoder(&completeBuffer).Encode(completeUploads), IsNil)
+
+	request, err = http.NewRequest("POST", testAPIDonutServer.URL+"/multipart-metadata/object?uploadId="+uploadID, &completeBuffer)
+	c.Assert(err, IsNil)
+
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	for _, method := range []string{"HEAD", "GET"} {
+		request, err = http.NewRequest(method, testAPIDonutServer.URL+"/multipart-metadata/object", nil)
+		c.Assert(err, IsNil)
+
+		response, err = client.Do(request)
+		c.Assert(err, IsNil)
+		c.Assert(response.StatusCode, Equals, http.StatusOK)
+		c.Assert(response.Header.Get("Content-Type"), Equals, "text/plain")
+		c.Assert(response.Header.Get("x-amz-meta-provenance"), Equals, "pipeline-42")
+		c.Assert(response.Header.Get("Content-Disposition"), Equals, "attachment; filename=\"object.txt\"")
+	}
 }
 
 func (s *MyAPIDonutSuite) TestPartialContent(c *C) {
@@ -533,6 +729,242 @@ func (s *MyAPIDonutSuite) TestPartialContent(c *C) {
 	c.Assert(string(partialObject), Equals, "Wo")
 }
 
+func (s *MyAPIDonutSuite) TestMultipleRanges(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutServer.URL+"/multiple-ranges", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("PUT", testAPIDonutServer.URL+"/multiple-ranges/bar", bytes.NewBufferString("Hello World"))
+	c.Assert(err, IsNil)
+	request.Header.Add("Content-Type", "text/plain")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	// overlapping ranges are coalesced
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/multiple-ranges/bar", nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Range", "bytes=7-8,0-1,6-7")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusPartialContent)
+	mediaType, params, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	c.Assert(err, IsNil)
+	c.Assert(mediaType, Equals, "multipart/byteranges")
+
+	body, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Assert(response.Header.Get("Content-Length"), Equals, strconv.Itoa(len(body)))
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	part, err := reader.NextPart()
+	c.Assert(err, IsNil)
+	c.Assert(part.Header.Get("Content-Range"), Equals, "bytes 0-1/11")
+	c.Assert(part.Header.Get("Content-Type"), Equals, "text/plain")
+	partData, err := ioutil.ReadAll(part)
+	c.Assert(err, IsNil)
+	c.Assert(string(partData), Equals, "He")
+
+	part, err = reader.NextPart()
+	c.Assert(err, IsNil)
+	c.Assert(part.Header.Get("Content-Range"), Equals, "bytes 6-8/11")
+	partData, err = ioutil.ReadAll(part)
+	c.Assert(err, IsNil)
+	c.Assert(string(partData), Equals, "Wor")
+
+	_, err = reader.NextPart()
+	c.Assert(err, Equals, io.EOF)
+
+	// ranges coalescing into one are sent as a single range
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/multiple-ranges/bar", nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Range", "bytes=0-3,2-5")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusPartialContent)
+	c.Assert(response.Header.Get("Content-Range"), Equals, "bytes 0-5/11")
+	partialObject, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(partialObject), Equals, "Hello ")
+
+	// unsatisfiable ranges are dropped as long as one of the ranges can be satisfied
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/multiple-ranges/bar", nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Range", "bytes=20-30,0-1,11-")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusPartialContent)
+	c.Assert(response.Header.Get("Content-Range"), Equals, "bytes 0-1/11")
+	partialObject, err = ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(partialObject), Equals, "He")
+
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/multiple-ranges/bar", nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Range", "bytes=20-30,11-")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidRange", "The requested range cannot be satisfied.", http.StatusRequestedRangeNotSatisfiable)
+}
+
+func (s *MyAPIDonutSuite) TestResponseHeaderOverridesAnonymous(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutServer.URL+"/response-overrides-anonymous", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("PUT", testAPIDonutServer.URL+"/response-overrides-anonymous/bar", bytes.NewBufferString("Hello World"))
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/response-overrides-anonymous/bar?response-content-type=text%2Fplain", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidRequest", "The request is not valid, please verify your request parameters.", http.StatusBadRequest)
+}
+
+func (s *MyAPIDonutSuite) TestListObjectsV2(c *C) {
+	request, err := http.NewRequest("PUT", testAPIDonutServer.URL+"/list-objects-v2", nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	for _, object := range []string{"a", "b", "c"} {
+		request, err = http.NewRequest("PUT", testAPIDonutServer.URL+"/list-objects-v2/"+object, bytes.NewBufferString("hello world"))
+		c.Assert(err, IsNil)
+
+		client = http.Client{}
+		response, err = client.Do(request)
+		c.Assert(err, IsNil)
+		c.Assert(response.StatusCode, Equals, http.StatusOK)
+	}
+
+	// version 1 always returns NextMarker on truncated results
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/list-objects-v2?max-keys=2", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	var v1Results api.ListObjectsResponse
+	err = xml.NewDecoder(response.Body).Decode(&v1Results)
+	c.Assert(err, IsNil)
+	c.Assert(v1Results.IsTruncated, Equals, true)
+	c.Assert(v1Results.NextMarker, Equals, "b")
+
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/list-objects-v2?list-type=2&max-keys=2", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	var results api.ListObjectsV2Response
+	err = xml.NewDecoder(response.Body).Decode(&results)
+	c.Assert(err, IsNil)
+	c.Assert(results.IsTruncated, Equals, true)
+	c.Assert(results.KeyCount, Equals, 2)
+	c.Assert(len(results.Contents), Equals, 2)
+	c.Assert(results.Contents[0].Key, Equals, "a")
+	c.Assert(results.Contents[0].Owner, IsNil)
+	c.Assert(results.NextContinuationToken, Not(Equals), "")
+
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/list-objects-v2?list-type=2&max-keys=2&fetch-owner=true&continuation-token="+results.NextContinuationToken, nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	results = api.ListObjectsV2Response{}
+	err = xml.NewDecoder(response.Body).Decode(&results)
+	c.Assert(err, IsNil)
+	c.Assert(results.IsTruncated, Equals, false)
+	c.Assert(results.KeyCount, Equals, 1)
+	c.Assert(results.Contents[0].Key, Equals, "c")
+	c.Assert(results.Contents[0].Owner, Not(IsNil))
+	c.Assert(results.NextContinuationToken, Equals, "")
+
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/list-objects-v2?list-type=2&start-after=a", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	results = api.ListObjectsV2Response{}
+	err = xml.NewDecoder(response.Body).Decode(&results)
+	c.Assert(err, IsNil)
+	c.Assert(results.StartAfter, Equals, "a")
+	c.Assert(results.KeyCount, Equals, 2)
+	c.Assert(results.Contents[0].Key, Equals, "b")
+
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/list-objects-v2?list-type=2&continuation-token=%21%21", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidArgument", "One or more of the specified arguments are not valid.", http.StatusBadRequest)
+
+	// keys are url encoded on request
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/list-objects-v2?list-type=2&encoding-type=url&start-after=a%20b", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	results = api.ListObjectsV2Response{}
+	err = xml.NewDecoder(response.Body).Decode(&results)
+	c.Assert(err, IsNil)
+	c.Assert(results.EncodingType, Equals, "url")
+	c.Assert(results.StartAfter, Equals, "a+b")
+	c.Assert(results.KeyCount, Equals, 2)
+	c.Assert(results.Contents[0].Key, Equals, "b")
+
+	request, err = http.NewRequest("GET", testAPIDonutServer.URL+"/list-objects-v2?list-type=2&encoding-type=base64", nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidArgument", "One or more of the specified arguments are not valid.", http.StatusBadRequest)
+}
+
 func (s *MyAPIDonutSuite) TestListObjectsHandlerErrors(c *C) {
 	request, err := http.NewRequest("GET", testAPIDonutServer.URL+"/objecthandlererrors-.", nil)
 	c.Assert(err, IsNil)
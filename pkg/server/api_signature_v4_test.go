@@ -24,15 +24,22 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"encoding/hex"
+	"encoding/json"
 	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 
 	. "github.com/minio/check"
 	"github.com/minio/minio/pkg/auth"
+	"github.com/minio/minio/pkg/controller"
 	"github.com/minio/minio/pkg/donut"
+	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/server/api"
+	"github.com/minio/minio/pkg/server/rpc"
 )
 
 func TestAPISignatureV4(t *testing.T) { TestingT(t) }
@@ -43,6 +50,11 @@ type MyAPISignatureV4Suite struct {
 	body            io.ReadSeeker
 	accessKeyID     string
 	secretAccessKey string
+	// sessionToken is sent along by suites signing with temporary credentials
+	sessionToken string
+	// admin keys manage users, groups and policies
+	adminAccessKeyID     string
+	adminSecretAccessKey string
 }
 
 var _ = Suite(&MyAPISignatureV4Suite{})
@@ -77,13 +89,27 @@ func (s *MyAPISignatureV4Suite) SetUpSuite(c *C) {
 	}
 	s.accessKeyID = string(accessKeyID)
 	s.secretAccessKey = string(secretAccessKey)
+	adminAccessKeyID, err := auth.GenerateAccessKeyID()
+	c.Assert(err, IsNil)
+	adminSecretAccessKey, err := auth.GenerateSecretAccessKey()
+	c.Assert(err, IsNil)
+	authConf.Users[string(adminAccessKeyID)] = &auth.User{
+		Name:            "testadmin",
+		AccessKeyID:     string(adminAccessKeyID),
+		SecretAccessKey: string(adminSecretAccessKey),
+		Policies:        []string{auth.AdminPolicy},
+	}
+	s.adminAccessKeyID = string(adminAccessKeyID)
+	s.adminSecretAccessKey = string(adminSecretAccessKey)
 
 	auth.CustomConfigPath = filepath.Join(root, "users.json")
 	err = auth.SaveConfig(authConf)
 	c.Assert(err, IsNil)
 
-	httpHandler, minioAPI := getAPIHandler(api.Config{RateLimit: 16})
-	go startTM(minioAPI)
+	apiConf := api.Config{RateLimit: 16}
+	httpHandler, minioAPI, err := getAPIHandler(apiConf)
+	c.Assert(err, IsNil)
+	go startTM(minioAPI, apiConf)
 	testSignatureV4Server = httptest.NewServer(httpHandler)
 }
 
@@ -481,14 +507,14 @@ func (s *MyAPISignatureV4Suite) TestContentTypePersists(c *C) {
 
 	response, err = client.Do(request)
 	c.Assert(err, IsNil)
-	c.Assert(response.Header.Get("Content-Type"), Equals, "application/octet-stream")
+	c.Assert(response.Header.Get("Content-Type"), Equals, "application/json")
 
 	request, err = s.newRequest("GET", testSignatureV4Server.URL+"/contenttype-persists/two", 0, nil)
 	c.Assert(err, IsNil)
 
 	response, err = client.Do(request)
 	c.Assert(err, IsNil)
-	c.Assert(response.Header.Get("Content-Type"), Equals, "application/octet-stream")
+	c.Assert(response.Header.Get("Content-Type"), Equals, "application/json")
 }
 
 func (s *MyAPISignatureV4Suite) TestPartialContent(c *C) {
@@ -525,6 +551,101 @@ func (s *MyAPISignatureV4Suite) TestPartialContent(c *C) {
 	c.Assert(string(partialObject), Equals, "Wo")
 }
 
+func (s *MyAPISignatureV4Suite) TestResponseHeaderOverrides(c *C) {
+	request, err := s.newRequest("PUT", testSignatureV4Server.URL+"/response-overrides", 0, nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	buffer1 := bytes.NewReader([]byte("Hello World"))
+	request, err = s.newRequest("PUT", testSignatureV4Server.URL+"/response-overrides/bar", int64(buffer1.Len()), buffer1)
+	c.Assert(err, IsNil)
+	request.Header.Add("Content-Type", "text/plain")
+	request.Header.Add("Cache-Control", "no-cache")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = s.newRequest("GET", testSignatureV4Server.URL+"/response-overrides/bar?response-content-type=application%2Foctet-stream&response-content-disposition=attachment%3B%20filename%3D%22hello.txt%22", 0, nil)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	c.Assert(response.Header.Get("Content-Type"), Equals, "application/octet-stream")
+	c.Assert(response.Header.Get("Content-Disposition"), Equals, "attachment; filename=\"hello.txt\"")
+	c.Assert(response.Header.Get("Cache-Control"), Equals, "no-cache")
+
+	request, err = s.newRequest("GET", testSignatureV4Server.URL+"/response-overrides/bar?response-cache-control=max-age%3D60", 0, nil)
+	c.Assert(err, IsNil)
+	request.Header.Add("Range", "bytes=6-7")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusPartialContent)
+	c.Assert(response.Header.Get("Content-Type"), Equals, "text/plain")
+	c.Assert(response.Header.Get("Cache-Control"), Equals, "max-age=60")
+}
+
+func (s *MyAPISignatureV4Suite) TestPresignedResponseHeaderOverrides(c *C) {
+	request, err := s.newRequest("PUT", testSignatureV4Server.URL+"/presigned-overrides", 0, nil)
+	c.Assert(err, IsNil)
+
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	buffer1 := bytes.NewReader([]byte("Hello World"))
+	request, err = s.newRequest("PUT", testSignatureV4Server.URL+"/presigned-overrides/bar", int64(buffer1.Len()), buffer1)
+	c.Assert(err, IsNil)
+	request.Header.Add("Content-Type", "text/plain")
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = s.newPresignedRequest("GET", testSignatureV4Server.URL+"/presigned-overrides/bar?response-content-disposition=attachment%3B%20filename%3D%22hello.txt%22", time.Minute)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	c.Assert(response.Header.Get("Content-Type"), Equals, "text/plain")
+	c.Assert(response.Header.Get("Content-Disposition"), Equals, "attachment; filename=\"hello.txt\"")
+	object, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(object), Equals, "Hello World")
+
+	// tampering with the signed query string invalidates the url
+	request, err = s.newPresignedRequest("GET", testSignatureV4Server.URL+"/presigned-overrides/bar?response-content-type=text%2Fhtml", time.Minute)
+	c.Assert(err, IsNil)
+	request.URL.RawQuery = strings.Replace(request.URL.RawQuery, "text%2Fhtml", "text%2Fxml", 1)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden)
+
+	// expired urls are refused
+	request, err = s.newPresignedRequest("GET", testSignatureV4Server.URL+"/presigned-overrides/bar?response-content-type=text%2Fhtml", -time.Minute)
+	c.Assert(err, IsNil)
+
+	client = http.Client{}
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusForbidden)
+}
+
 func (s *MyAPISignatureV4Suite) TestListObjectsHandlerErrors(c *C) {
 	request, err := s.newRequest("GET", testSignatureV4Server.URL+"/objecthandlererrors-.", 0, nil)
 	c.Assert(err, IsNil)
@@ -866,3 +987,450 @@ func (s *MyAPISignatureV4Suite) TestObjectMultipart(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(string(object), Equals, ("hello worldhello world"))
 }
+
+func (s *MyAPISignatureV4Suite) TestUserPolicies(c *C) {
+	rpcServer := httptest.NewServer(getRPCHandler(nil, api.NewTracer()))
+	defer rpcServer.Close()
+	rpcURL := rpcServer.URL + "/rpc"
+	controller.SetCredentials(s.adminAccessKeyID, s.adminSecretAccessKey)
+	defer controller.SetCredentials("", "")
+
+	created, err := controller.CreateUser(rpcURL, "reader", []string{"readonly:userpolicies"})
+	c.Assert(err, IsNil)
+	var user rpc.UserReply
+	c.Assert(json.Unmarshal(created, &user), IsNil)
+	c.Assert(user.Policies, DeepEquals, []string{"readonly:userpolicies"})
+	reader := &MyAPISignatureV4Suite{accessKeyID: user.AccessKeyID, secretAccessKey: user.SecretAccessKey}
+	_, err = controller.CreateUser(rpcURL, "reader", nil)
+	c.Assert(err, Not(IsNil))
+
+	client := http.Client{}
+	request, err := s.newRequest("PUT", testSignatureV4Server.URL+"/userpolicies", 0, nil)
+	c.Assert(err, IsNil)
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	request, err = s.newRequest("PUT", testSignatureV4Server.URL+"/userpolicies/object", int64(len("hello world")), bytes.NewReader([]byte("hello world")))
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	request, err = reader.newRequest("GET", testSignatureV4Server.URL+"/userpolicies/object", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	// writes and other buckets are not covered by the policy
+	request, err = reader.newRequest("PUT", testSignatureV4Server.URL+"/userpolicies/other", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "AccessDenied", "Access Denied", http.StatusForbidden)
+	request, err = reader.newRequest("GET", testSignatureV4Server.URL+"/", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "AccessDenied", "Access Denied", http.StatusForbidden)
+
+	c.Assert(controller.AttachUserPolicies(rpcURL, "reader", []string{"writeonly"}), IsNil)
+	request, err = reader.newRequest("PUT", testSignatureV4Server.URL+"/userpolicies/other", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	c.Assert(controller.DetachUserPolicies(rpcURL, "reader", []string{"writeonly"}), IsNil)
+	c.Assert(controller.DetachUserPolicies(rpcURL, "reader", []string{"writeonly"}), Not(IsNil))
+	c.Assert(controller.AttachUserPolicies(rpcURL, "reader", []string{"unknown"}), Not(IsNil))
+
+	// a user without policies is allowed nothing
+	c.Assert(controller.DetachUserPolicies(rpcURL, "reader", []string{"readonly:userpolicies"}), IsNil)
+	request, err = reader.newRequest("GET", testSignatureV4Server.URL+"/userpolicies/object", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "AccessDenied", "Access Denied", http.StatusForbidden)
+	c.Assert(controller.AttachUserPolicies(rpcURL, "reader", []string{"readonly:userpolicies"}), IsNil)
+
+	c.Assert(controller.DisableUser(rpcURL, "reader"), IsNil)
+	request, err = reader.newRequest("GET", testSignatureV4Server.URL+"/userpolicies/object", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidAccessKeyID", "The access key ID you provided does not exist in our records.", http.StatusForbidden)
+	c.Assert(controller.EnableUser(rpcURL, "reader"), IsNil)
+
+	// old keys stop working once rotated
+	rotated, err := controller.RotateUserKeys(rpcURL, "reader")
+	c.Assert(err, IsNil)
+	c.Assert(json.Unmarshal(rotated, &user), IsNil)
+	request, err = reader.newRequest("GET", testSignatureV4Server.URL+"/userpolicies/object", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidAccessKeyID", "The access key ID you provided does not exist in our records.", http.StatusForbidden)
+	reader = &MyAPISignatureV4Suite{accessKeyID: user.AccessKeyID, secretAccessKey: user.SecretAccessKey}
+	request, err = reader.newRequest("GET", testSignatureV4Server.URL+"/userpolicies/object", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	listed, err := controller.ListUsers(rpcURL)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(listed), `"name": "reader"`), Equals, true)
+	c.Assert(strings.Contains(string(listed), user.SecretAccessKey), Equals, false)
+
+	c.Assert(controller.DeleteUser(rpcURL, "reader"), IsNil)
+	request, err = reader.newRequest("GET", testSignatureV4Server.URL+"/userpolicies/object", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidAccessKeyID", "The access key ID you provided does not exist in our records.", http.StatusForbidden)
+}
+
+func (s *MyAPISignatureV4Suite) TestAdminCalls(c *C) {
+	rpcServer := httptest.NewServer(getRPCHandler(nil, api.NewTracer()))
+	defer rpcServer.Close()
+	rpcURL := rpcServer.URL + "/rpc"
+	defer controller.SetCredentials("", "")
+
+	// unsigned calls and calls of users who are not admins are refused
+	_, err := controller.CreateUser(rpcURL, "intruder", []string{auth.AdminPolicy})
+	c.Assert(err, Not(IsNil))
+	c.Assert(strings.Contains(iodine.ToError(err).Error(), "Access Denied"), Equals, true)
+	controller.SetCredentials(s.accessKeyID, s.secretAccessKey)
+	_, err = controller.CreateUser(rpcURL, "intruder", []string{auth.AdminPolicy})
+	c.Assert(err, Not(IsNil))
+	controller.SetCredentials(s.accessKeyID, "wrong"+s.secretAccessKey[5:])
+	_, err = controller.ListUsers(rpcURL)
+	c.Assert(err, Not(IsNil))
+	// other services are open
+	_, err = controller.GetMemStats(rpcURL)
+	c.Assert(err, IsNil)
+
+	// a signature covers the call, it can not be reused for another one
+	controller.SetCredentials(s.adminAccessKeyID, s.adminSecretAccessKey)
+	listed, err := controller.NewRequest(rpcURL, controller.RPCOps{Method: "User.List", Request: rpc.Args{}}, nil)
+	c.Assert(err, IsNil)
+	create, err := controller.NewRequest(rpcURL, controller.RPCOps{Method: "User.Create", Request: rpc.UserArgs{Name: "intruder"}}, nil)
+	c.Assert(err, IsNil)
+	create.Set("Authorization", listed.Get("Authorization"))
+	response, err := create.Do()
+	c.Assert(err, IsNil)
+	response.Body.Close()
+	c.Assert(response.StatusCode, Equals, http.StatusForbidden)
+
+	_, err = controller.CreateUser(rpcURL, "intruder", nil)
+	c.Assert(err, IsNil)
+	c.Assert(controller.DeleteUser(rpcURL, "intruder"), IsNil)
+}
+
+func (s *MyAPISignatureV4Suite) TestTrace(c *C) {
+	apiConf := api.Config{}
+	httpHandler, minioAPI, err := getAPIHandler(apiConf)
+	c.Assert(err, IsNil)
+	go startTM(minioAPI, apiConf)
+	server := httptest.NewServer(httpHandler)
+	defer server.Close()
+	traceServer := httptest.NewServer(getRPCHandler(nil, minioAPI.Tracer))
+	defer traceServer.Close()
+
+	// traces are streamed to admins only
+	client := http.Client{}
+	newTraceRequest := func(query, accessKeyID, secretAccessKey string) *http.Request {
+		request, err := http.NewRequest("GET", traceServer.URL+"/trace?"+query, nil)
+		c.Assert(err, IsNil)
+		signature := &donut.Signature{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			Request:         request,
+		}
+		signature.Sign(hex.EncodeToString(sum256(nil)), time.Now().UTC())
+		return request
+	}
+	response, err := client.Get(traceServer.URL + "/trace")
+	c.Assert(err, IsNil)
+	response.Body.Close()
+	c.Assert(response.StatusCode, Equals, http.StatusForbidden)
+	response, err = client.Do(newTraceRequest("", s.accessKeyID, s.secretAccessKey))
+	c.Assert(err, IsNil)
+	response.Body.Close()
+	c.Assert(response.StatusCode, Equals, http.StatusForbidden)
+
+	bucketTrace, err := client.Do(newTraceRequest("bucket=trace&headers=true", s.adminAccessKeyID, s.adminSecretAccessKey))
+	c.Assert(err, IsNil)
+	defer bucketTrace.Body.Close()
+	c.Assert(bucketTrace.StatusCode, Equals, http.StatusOK)
+	errorTrace, err := client.Do(newTraceRequest("status=4xx", s.adminAccessKeyID, s.adminSecretAccessKey))
+	c.Assert(err, IsNil)
+	defer errorTrace.Body.Close()
+	c.Assert(errorTrace.StatusCode, Equals, http.StatusOK)
+
+	for _, bucket := range []string{"untraced", "trace", "trace"} {
+		request, err := s.newRequest("PUT", server.URL+"/"+bucket, 0, nil)
+		c.Assert(err, IsNil)
+		response, err := client.Do(request)
+		c.Assert(err, IsNil)
+		response.Body.Close()
+	}
+	request, err := s.newPresignedRequest("GET", server.URL+"/trace/missing", time.Minute)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	response.Body.Close()
+	c.Assert(response.StatusCode, Equals, http.StatusNotFound)
+
+	decoder := json.NewDecoder(bucketTrace.Body)
+	var entry api.TraceEntry
+	c.Assert(decoder.Decode(&entry), IsNil)
+	c.Assert(entry.API, Equals, "PutBucket")
+	c.Assert(entry.Method, Equals, "PUT")
+	c.Assert(entry.URI, Equals, "/trace")
+	c.Assert(entry.AccessKey, Equals, s.accessKeyID)
+	c.Assert(entry.Status, Equals, http.StatusOK)
+	c.Assert(entry.RequestHeaders.Get("Authorization"), Equals, "REDACTED")
+	c.Assert(entry.ResponseHeaders.Get("X-Amz-Request-Id"), Equals, entry.RequestID)
+	c.Assert(decoder.Decode(&entry), IsNil)
+	c.Assert(entry.Status, Equals, http.StatusConflict)
+	// the signature of a presigned request is not given away
+	c.Assert(decoder.Decode(&entry), IsNil)
+	c.Assert(entry.Status, Equals, http.StatusNotFound)
+	c.Assert(strings.Contains(entry.URI, "X-Amz-Signature=REDACTED"), Equals, true)
+	c.Assert(strings.Contains(entry.URI, request.URL.Query().Get("X-Amz-Signature")), Equals, false)
+
+	// only the second bucket put and the read failed, headers were not asked for
+	decoder = json.NewDecoder(errorTrace.Body)
+	entry = api.TraceEntry{}
+	c.Assert(decoder.Decode(&entry), IsNil)
+	c.Assert(entry.URI, Equals, "/trace")
+	c.Assert(entry.Status, Equals, http.StatusConflict)
+	c.Assert(entry.RequestHeaders, IsNil)
+	c.Assert(decoder.Decode(&entry), IsNil)
+	c.Assert(entry.Status, Equals, http.StatusNotFound)
+}
+
+func (s *MyAPISignatureV4Suite) TestGroupPolicies(c *C) {
+	rpcServer := httptest.NewServer(getRPCHandler(nil, api.NewTracer()))
+	defer rpcServer.Close()
+	rpcURL := rpcServer.URL + "/rpc"
+	controller.SetCredentials(s.adminAccessKeyID, s.adminSecretAccessKey)
+	defer controller.SetCredentials("", "")
+
+	request, err := s.newRequest("PUT", testSignatureV4Server.URL+"/grouppolicies", 0, nil)
+	c.Assert(err, IsNil)
+	client := http.Client{}
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	// editors may put and read anything but objects under private/
+	document := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Effect": "Allow", "Action": ["s3:PutObject", "s3:Get*"], "Resource": "arn:aws:s3:::grouppolicies/*"},
+			{"Effect": "Deny", "Action": "s3:*", "Resource": "arn:aws:s3:::grouppolicies/private/*"}
+		]
+	}`
+	c.Assert(controller.PutPolicy(rpcURL, "readonly", []byte(document)), Not(IsNil))
+	c.Assert(controller.PutPolicy(rpcURL, "invalid", []byte(`{"Statement": [{"Effect": "Maybe"}]}`)), Not(IsNil))
+	c.Assert(controller.PutPolicy(rpcURL, "editor", []byte(document)), IsNil)
+	policy, err := controller.GetPolicy(rpcURL, "editor")
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(policy), "arn:aws:s3:::grouppolicies/private/*"), Equals, true)
+
+	c.Assert(controller.CreateGroup(rpcURL, "editors", []string{"editor"}), IsNil)
+	created, err := controller.CreateUser(rpcURL, "editor", nil)
+	c.Assert(err, IsNil)
+	var user rpc.UserReply
+	c.Assert(json.Unmarshal(created, &user), IsNil)
+	editor := &MyAPISignatureV4Suite{accessKeyID: user.AccessKeyID, secretAccessKey: user.SecretAccessKey}
+
+	put := func(object string) *http.Response {
+		request, err := editor.newRequest("PUT", testSignatureV4Server.URL+"/grouppolicies/"+object, int64(len("hello")), bytes.NewReader([]byte("hello")))
+		c.Assert(err, IsNil)
+		response, err := client.Do(request)
+		c.Assert(err, IsNil)
+		return response
+	}
+	// without groups or policies of its own a new user is allowed nothing
+	verifyError(c, put("public/object"), "AccessDenied", "Access Denied", http.StatusForbidden)
+
+	c.Assert(controller.AddGroupMembers(rpcURL, "editors", []string{"editor"}), IsNil)
+	c.Assert(put("public/object").StatusCode, Equals, http.StatusOK)
+	verifyError(c, put("private/object"), "AccessDenied", "Access Denied", http.StatusForbidden)
+	request, err = editor.newRequest("DELETE", testSignatureV4Server.URL+"/grouppolicies/public/object", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "AccessDenied", "Access Denied", http.StatusForbidden)
+
+	// policies of the user and its groups add up, a Deny wins over every Allow
+	c.Assert(controller.AttachUserPolicies(rpcURL, "editor", []string{"readwrite:grouppolicies"}), IsNil)
+	verifyError(c, put("private/object"), "AccessDenied", "Access Denied", http.StatusForbidden)
+	c.Assert(controller.DetachUserPolicies(rpcURL, "editor", []string{"readwrite:grouppolicies"}), IsNil)
+
+	c.Assert(controller.DeletePolicy(rpcURL, "editor"), Not(IsNil))
+	listed, err := controller.ListGroups(rpcURL)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(listed), `"editor"`), Equals, true)
+	c.Assert(controller.RemoveGroupMembers(rpcURL, "editors", []string{"editor"}), IsNil)
+	verifyError(c, put("public/object"), "AccessDenied", "Access Denied", http.StatusForbidden)
+
+	c.Assert(controller.DeleteGroup(rpcURL, "editors"), IsNil)
+	c.Assert(controller.DeletePolicy(rpcURL, "editor"), IsNil)
+	c.Assert(controller.DeleteUser(rpcURL, "editor"), IsNil)
+}
+
+func (s *MyAPISignatureV4Suite) TestAssumeRole(c *C) {
+	client := http.Client{}
+	request, err := s.newRequest("PUT", testSignatureV4Server.URL+"/assumerole", 0, nil)
+	c.Assert(err, IsNil)
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	request, err = s.newRequest("PUT", testSignatureV4Server.URL+"/assumerole/object", int64(len("hello")), bytes.NewReader([]byte("hello")))
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	assumeRole := func(signer *MyAPISignatureV4Suite, params url.Values) *http.Response {
+		body := params.Encode()
+		request, err := signer.newRequest("POST", testSignatureV4Server.URL+"/", int64(len(body)), strings.NewReader(body))
+		c.Assert(err, IsNil)
+		response, err := client.Do(request)
+		c.Assert(err, IsNil)
+		return response
+	}
+	policy := `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::assumerole/*"}]}`
+	response = assumeRole(s, url.Values{"Action": {"AssumeRole"}, "DurationSeconds": {"900"}, "Policy": {policy}})
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	var assumed api.AssumeRoleResponse
+	c.Assert(xml.NewDecoder(response.Body).Decode(&assumed), IsNil)
+	credentials := assumed.AssumeRoleResult.Credentials
+	c.Assert(credentials.SessionToken, Not(Equals), "")
+	c.Assert(assumed.ResponseMetadata.RequestID, Equals, response.Header.Get("X-Amz-Request-Id"))
+	session := &MyAPISignatureV4Suite{
+		accessKeyID:     credentials.AccessKeyID,
+		secretAccessKey: credentials.SecretAccessKey,
+		sessionToken:    credentials.SessionToken,
+	}
+
+	request, err = session.newRequest("GET", testSignatureV4Server.URL+"/assumerole/object", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+
+	// the inline policy limits the session
+	request, err = session.newRequest("PUT", testSignatureV4Server.URL+"/assumerole/other", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "AccessDenied", "Access Denied", http.StatusForbidden)
+
+	// sessions are only valid with their token
+	for _, token := range []string{"", "forged"} {
+		forged := *session
+		forged.sessionToken = token
+		request, err = forged.newRequest("GET", testSignatureV4Server.URL+"/assumerole/object", 0, nil)
+		c.Assert(err, IsNil)
+		response, err = client.Do(request)
+		c.Assert(err, IsNil)
+		verifyError(c, response, "InvalidToken", "The provided token is malformed or otherwise invalid.", http.StatusBadRequest)
+	}
+
+	// sessions cannot assume roles themselves, and parameters are checked
+	verifyError(c, assumeRole(session, url.Values{"Action": {"AssumeRole"}}), "AccessDenied", "Access Denied", http.StatusForbidden)
+	verifyError(c, assumeRole(s, url.Values{"Action": {"AssumeRole"}, "DurationSeconds": {"60"}}),
+		"InvalidParameterValue", "An invalid or out-of-range value was supplied for the input parameter.", http.StatusBadRequest)
+	verifyError(c, assumeRole(s, url.Values{"Action": {"AssumeRole"}, "Policy": {`{"Statement": "all"}`}}),
+		"MalformedPolicyDocument", "The policy document is malformed.", http.StatusBadRequest)
+
+	// expired sessions are refused
+	authConfig, err := auth.LoadConfig()
+	c.Assert(err, IsNil)
+	authConfig.Users[session.accessKeyID].Session.Expiration = time.Now().UTC().Add(-time.Minute)
+	c.Assert(auth.SaveConfig(authConfig), IsNil)
+	request, err = session.newRequest("GET", testSignatureV4Server.URL+"/assumerole/object", 0, nil)
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "ExpiredToken", "The provided token has expired.", http.StatusBadRequest)
+}
+
+func (s *MyAPISignatureV4Suite) TestDisabledUsers(c *C) {
+	rpcServer := httptest.NewServer(getRPCHandler(nil, api.NewTracer()))
+	defer rpcServer.Close()
+	rpcURL := rpcServer.URL + "/rpc"
+	controller.SetCredentials(s.adminAccessKeyID, s.adminSecretAccessKey)
+	defer controller.SetCredentials("", "")
+
+	created, err := controller.CreateUser(rpcURL, "disabled", []string{"readwrite:disabledusers"})
+	c.Assert(err, IsNil)
+	var user rpc.UserReply
+	c.Assert(json.Unmarshal(created, &user), IsNil)
+	disabled := &MyAPISignatureV4Suite{accessKeyID: user.AccessKeyID, secretAccessKey: user.SecretAccessKey}
+
+	client := http.Client{}
+	request, err := disabled.newRequest("PUT", testSignatureV4Server.URL+"/disabledusers", 0, nil)
+	c.Assert(err, IsNil)
+	response, err := client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	body := url.Values{"Action": {"AssumeRole"}}.Encode()
+	request, err = disabled.newRequest("POST", testSignatureV4Server.URL+"/", int64(len(body)), strings.NewReader(body))
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	var assumed api.AssumeRoleResponse
+	c.Assert(xml.NewDecoder(response.Body).Decode(&assumed), IsNil)
+	credentials := assumed.AssumeRoleResult.Credentials
+	session := &MyAPISignatureV4Suite{
+		accessKeyID:     credentials.AccessKeyID,
+		secretAccessKey: credentials.SecretAccessKey,
+		sessionToken:    credentials.SessionToken,
+	}
+
+	// signatures of a disabled user and of its sessions are not even looked at
+	c.Assert(controller.DisableUser(rpcURL, "disabled"), IsNil)
+	for _, signer := range []*MyAPISignatureV4Suite{disabled, session} {
+		request, err = signer.newRequest("GET", testSignatureV4Server.URL+"/disabledusers", 0, nil)
+		c.Assert(err, IsNil)
+		_, err = api.InitSignatureV4(request)
+		c.Assert(err, Not(IsNil))
+		response, err = client.Do(request)
+		c.Assert(err, IsNil)
+		verifyError(c, response, "InvalidAccessKeyID", "The access key ID you provided does not exist in our records.", http.StatusForbidden)
+
+		request, err = signer.newPresignedRequest("GET", testSignatureV4Server.URL+"/disabledusers", time.Minute)
+		c.Assert(err, IsNil)
+		_, err = api.InitPresignedSignatureV4(request)
+		c.Assert(err, Not(IsNil))
+	}
+	request, err = disabled.newRequest("POST", testSignatureV4Server.URL+"/", int64(len(body)), strings.NewReader(body))
+	c.Assert(err, IsNil)
+	response, err = client.Do(request)
+	c.Assert(err, IsNil)
+	verifyError(c, response, "InvalidAccessKeyID", "The access key ID you provided does not exist in our records.", http.StatusForbidden)
+
+	// sessions are refused once their parent is gone
+	c.Assert(controller.EnableUser(rpcURL, "disabled"), IsNil)
+	authConfig, err := auth.LoadConfig()
+	c.Assert(err, IsNil)
+	authConfig.Users[session.accessKeyID].Session.Parent = "deleted"
+	c.Assert(auth.SaveConfig(authConfig), IsNil)
+	request, err = session.newRequest("GET", testSignatureV4Server.URL+"/disabledusers", 0, nil)
+	c.Assert(err, IsNil)
+	_, err = api.InitSignatureV4(request)
+	c.Assert(err, Not(IsNil))
+	request, err = disabled.newRequest("GET", testSignatureV4Server.URL+"/disabledusers", 0, nil)
+	c.Assert(err, IsNil)
+	_, err = api.InitSignatureV4(request)
+	c.Assert(err, IsNil)
+
+	c.Assert(controller.DeleteUser(rpcURL, "disabled"), IsNil)
+}
@@ -20,13 +20,19 @@ import (
 	"net/http"
 
 	router "github.com/gorilla/mux"
+	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/server/api"
 	"github.com/minio/minio/pkg/server/rpc"
 )
 
 // registerAPI - register all the object API handlers to their respective paths
 func registerAPI(mux *router.Router, a api.Minio) http.Handler {
+	// unsigned requests only, a signed GET /metrics lists the bucket named metrics
+	mux.HandleFunc("/metrics", a.MetricsHandler).MatcherFunc(func(req *http.Request, _ *router.RouteMatch) bool {
+		return api.IsMetricsRequest(req)
+	})
 	mux.HandleFunc("/", a.ListBucketsHandler).Methods("GET")
+	mux.HandleFunc("/", a.AssumeRoleHandler).Methods("POST")
 	mux.HandleFunc("/{bucket}", a.ListObjectsHandler).Methods("GET")
 	mux.HandleFunc("/{bucket}", a.PutBucketHandler).Methods("PUT")
 	mux.HandleFunc("/{bucket}", a.HeadBucketHandler).Methods("HEAD")
@@ -78,37 +84,63 @@ func registerChain(handlers ...handlerFunc) chain {
 }
 
 // registerCustomMiddleware register all available custom middleware
-func registerCustomMiddleware(mux http.Handler, conf api.Config) http.Handler {
+func registerCustomMiddleware(mux http.Handler, a api.Minio, conf api.Config) http.Handler {
 	ch := registerChain(
 		api.ValidContentTypeHandler,
 		api.TimeValidityHandler,
 		api.IgnoreResourcesHandler,
 		api.ValidateAuthHeaderHandler,
-		api.LoggingHandler,
+		a.AuditHandler,
+		a.LoggingHandler,
+		api.RequestIDHandler,
 		// Add new your new middleware here
 	)
 
 	mux = ch.final(mux)
-	return mux
+
+	// health checks are answered ahead of the middleware, a probe must not depend on the auth layer
+	health := router.NewRouter()
+	health.HandleFunc("/minio/health/live", a.LiveHandler).Methods("GET", "HEAD")
+	health.HandleFunc("/minio/health/ready", a.ReadyHandler).Methods("GET", "HEAD")
+	health.NotFoundHandler = mux
+	return health
 }
 
 // registerRPC - register rpc handlers
-func registerRPC(mux *router.Router, s *rpc.Server) http.Handler {
+func registerRPC(mux *router.Router, s http.Handler) http.Handler {
 	mux.Handle("/rpc", s)
 	return mux
 }
 
+// registerTrace - register the trace stream, it streams until the tracer detaches and like S3 reads
+// it is not audited. Traces show who calls what, tracers need admin credentials
+func registerTrace(mux *router.Router, tracer *api.Tracer) http.Handler {
+	mux.Handle("/trace", api.AdminHandler("admin:Trace", tracer)).Methods("GET")
+	return mux
+}
+
 // getAPIHandler api handler
-func getAPIHandler(conf api.Config) (http.Handler, api.Minio) {
+func getAPIHandler(conf api.Config) (http.Handler, api.Minio, error) {
 	mux := router.NewRouter()
 	minioAPI := api.New()
+	accessLog, err := api.NewAccessLog(conf)
+	if err != nil {
+		return nil, api.Minio{}, iodine.New(err, nil)
+	}
+	minioAPI.AccessLog = accessLog
+	auditLog, err := api.NewAuditLog(conf)
+	if err != nil {
+		return nil, api.Minio{}, iodine.New(err, nil)
+	}
+	minioAPI.AuditLog = auditLog
 	apiHandler := registerAPI(mux, minioAPI)
-	apiHandler = registerCustomMiddleware(apiHandler, conf)
-	return apiHandler, minioAPI
+	apiHandler = registerCustomMiddleware(apiHandler, minioAPI, conf)
+	return apiHandler, minioAPI, nil
 }
 
-// getRPCHandler rpc handler
-func getRPCHandler() http.Handler {
+// getRPCHandler rpc handler, calls are audited unless auditLog is nil. API calls traced by tracer are
+// streamed from /trace
+func getRPCHandler(auditLog *api.AuditLog, tracer *api.Tracer) http.Handler {
 	s := rpc.NewServer()
 	s.RegisterJSONCodec()
 	s.RegisterService(new(rpc.VersionService), "Version")
@@ -117,6 +149,12 @@ func getRPCHandler() http.Handler {
 	s.RegisterService(new(rpc.DiskInfoService), "DiskInfo")
 	s.RegisterService(new(rpc.DonutService), "Donut")
 	s.RegisterService(new(rpc.AuthService), "Auth")
+	s.RegisterService(new(rpc.UserService), "User")
+	s.RegisterService(new(rpc.GroupService), "Group")
+	s.RegisterService(new(rpc.PolicyService), "Policy")
 	// Add new RPC services here
-	return registerRPC(router.NewRouter(), s)
+	mux := router.NewRouter()
+	// calls refused for lack of admin credentials are audited too
+	registerRPC(mux, api.AuditRPCHandler(auditLog, api.AdminRPCHandler(s)))
+	return registerTrace(mux, tracer)
 }
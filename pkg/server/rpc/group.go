@@ -0,0 +1,113 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"net/http"
+
+	"github.com/minio/minio/pkg/auth"
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// GroupService group management service
+type GroupService struct{}
+
+// GroupArgs group to manage and the members or policies to add or remove
+type GroupArgs struct {
+	Name     string   `json:"name"`
+	Members  []string `json:"members"`
+	Policies []string `json:"policies"`
+}
+
+// GroupReply a group
+type GroupReply struct {
+	Name     string   `json:"name"`
+	Members  []string `json:"members"`
+	Policies []string `json:"policies"`
+}
+
+// ListGroupsReply all groups
+type ListGroupsReply struct {
+	Groups []GroupReply `json:"groups"`
+}
+
+// Create a group
+func (s *GroupService) Create(r *http.Request, args *GroupArgs, reply *Reply) error {
+	if err := auth.CreateGroup(args.Name, args.Policies); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
+
+// List all groups
+func (s *GroupService) List(r *http.Request, args *Args, reply *ListGroupsReply) error {
+	groups, err := auth.ListGroups()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Groups = []GroupReply{}
+	for _, group := range groups {
+		reply.Groups = append(reply.Groups, GroupReply{Name: group.Name, Members: group.Members, Policies: group.Policies})
+	}
+	return nil
+}
+
+// Delete a group
+func (s *GroupService) Delete(r *http.Request, args *GroupArgs, reply *Reply) error {
+	if err := auth.DeleteGroup(args.Name); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
+
+// AddMembers to a group
+func (s *GroupService) AddMembers(r *http.Request, args *GroupArgs, reply *Reply) error {
+	if err := auth.AddGroupMembers(args.Name, args.Members); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
+
+// RemoveMembers from a group
+func (s *GroupService) RemoveMembers(r *http.Request, args *GroupArgs, reply *Reply) error {
+	if err := auth.RemoveGroupMembers(args.Name, args.Members); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
+
+// AttachPolicies to a group
+func (s *GroupService) AttachPolicies(r *http.Request, args *GroupArgs, reply *Reply) error {
+	if err := auth.AttachGroupPolicies(args.Name, args.Policies); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
+
+// DetachPolicies from a group
+func (s *GroupService) DetachPolicies(r *http.Request, args *GroupArgs, reply *Reply) error {
+	if err := auth.DetachGroupPolicies(args.Name, args.Policies); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"net/http"
+
+	"github.com/minio/minio/pkg/auth"
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// PolicyService policy store service
+type PolicyService struct{}
+
+// PolicyArgs policy to manage, Document is in the IAM JSON grammar
+type PolicyArgs struct {
+	Name     string `json:"name"`
+	Document string `json:"document"`
+}
+
+// PolicyReply a policy
+type PolicyReply struct {
+	Name     string               `json:"name"`
+	Document *auth.PolicyDocument `json:"document"`
+}
+
+// ListPoliciesReply names of all stored policies
+type ListPoliciesReply struct {
+	Policies []string `json:"policies"`
+}
+
+// Put store a policy
+func (s *PolicyService) Put(r *http.Request, args *PolicyArgs, reply *Reply) error {
+	if err := auth.PutPolicy(args.Name, []byte(args.Document)); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
+
+// Get a built in or stored policy
+func (s *PolicyService) Get(r *http.Request, args *PolicyArgs, reply *PolicyReply) error {
+	policy, err := auth.GetPolicy(args.Name)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Name = args.Name
+	reply.Document = policy
+	return nil
+}
+
+// List stored policies
+func (s *PolicyService) List(r *http.Request, args *Args, reply *ListPoliciesReply) error {
+	policies, err := auth.ListPolicies()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Policies = policies
+	return nil
+}
+
+// Delete a stored policy
+func (s *PolicyService) Delete(r *http.Request, args *PolicyArgs, reply *Reply) error {
+	if err := auth.DeletePolicy(args.Name); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
@@ -23,6 +23,9 @@ import (
 	"github.com/gorilla/rpc/v2/json"
 )
 
+// MaxRequestSize - RPC requests with larger bodies are refused
+const MaxRequestSize = 1024 * 1024
+
 // Server rpc server container
 type Server struct {
 	RPCServer *rpc.Server
@@ -47,5 +50,8 @@ func NewServer() *Server {
 
 // ServeHTTP wrapper method for http.Handler interface
 func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, MaxRequestSize)
+	}
 	s.RPCServer.ServeHTTP(w, r)
 }
@@ -0,0 +1,137 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"net/http"
+
+	"github.com/minio/minio/pkg/auth"
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// UserService user management service
+type UserService struct{}
+
+// UserArgs user to manage and the policies to attach or detach
+type UserArgs struct {
+	Name     string   `json:"name"`
+	Policies []string `json:"policies"`
+}
+
+// UserReply a user, its secret access key is only ever replied when it is created or rotated
+type UserReply struct {
+	Name            string   `json:"name"`
+	AccessKeyID     string   `json:"accesskey"`
+	SecretAccessKey string   `json:"secretaccesskey,omitempty"`
+	Disabled        bool     `json:"disabled"`
+	Policies        []string `json:"policies"`
+}
+
+// ListUsersReply all users
+type ListUsersReply struct {
+	Users []UserReply `json:"users"`
+}
+
+func setUserReply(user *auth.User, withSecret bool, reply *UserReply) {
+	reply.Name = user.Name
+	reply.AccessKeyID = user.AccessKeyID
+	reply.Disabled = user.Disabled
+	reply.Policies = user.Policies
+	if withSecret {
+		reply.SecretAccessKey = user.SecretAccessKey
+	}
+}
+
+// Create a user with new keys
+func (s *UserService) Create(r *http.Request, args *UserArgs, reply *UserReply) error {
+	user, err := auth.CreateUser(args.Name, args.Policies)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	setUserReply(user, true, reply)
+	return nil
+}
+
+// List all users
+func (s *UserService) List(r *http.Request, args *Args, reply *ListUsersReply) error {
+	users, err := auth.ListUsers()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Users = []UserReply{}
+	for _, user := range users {
+		var userReply UserReply
+		setUserReply(user, false, &userReply)
+		reply.Users = append(reply.Users, userReply)
+	}
+	return nil
+}
+
+// Enable a disabled user
+func (s *UserService) Enable(r *http.Request, args *UserArgs, reply *Reply) error {
+	if err := auth.EnableUser(args.Name); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
+
+// Disable a user
+func (s *UserService) Disable(r *http.Request, args *UserArgs, reply *Reply) error {
+	if err := auth.DisableUser(args.Name); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
+
+// Delete a user
+func (s *UserService) Delete(r *http.Request, args *UserArgs, reply *Reply) error {
+	if err := auth.DeleteUser(args.Name); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
+
+// RotateKeys of a user
+func (s *UserService) RotateKeys(r *http.Request, args *UserArgs, reply *UserReply) error {
+	user, err := auth.RotateUserKeys(args.Name)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	setUserReply(user, true, reply)
+	return nil
+}
+
+// AttachPolicies to a user
+func (s *UserService) AttachPolicies(r *http.Request, args *UserArgs, reply *Reply) error {
+	if err := auth.AttachUserPolicies(args.Name, args.Policies); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
+
+// DetachPolicies from a user
+func (s *UserService) DetachPolicies(r *http.Request, args *UserArgs, reply *Reply) error {
+	if err := auth.DetachUserPolicies(args.Name, args.Policies); err != nil {
+		return iodine.New(err, nil)
+	}
+	reply.Message = "success"
+	return nil
+}
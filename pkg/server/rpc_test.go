@@ -17,30 +17,82 @@
 package server
 
 import (
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	jsonrpc "github.com/gorilla/rpc/v2/json"
 	. "github.com/minio/check"
 	"github.com/minio/minio/pkg/controller"
+	"github.com/minio/minio/pkg/server/api"
 	"github.com/minio/minio/pkg/server/rpc"
 )
 
 func TestRPC(t *testing.T) { TestingT(t) }
 
-type MyRPCSuite struct{}
+type MyRPCSuite struct {
+	root     string
+	auditLog *api.AuditLog
+}
 
 var _ = Suite(&MyRPCSuite{})
 
 var testRPCServer *httptest.Server
 
 func (s *MyRPCSuite) SetUpSuite(c *C) {
-	testRPCServer = httptest.NewServer(getRPCHandler())
+	root, err := ioutil.TempDir(os.TempDir(), "rpc-")
+	c.Assert(err, IsNil)
+	s.root = root
+	s.auditLog, err = api.NewAuditLog(api.Config{AuditLog: filepath.Join(root, "audit.log")})
+	c.Assert(err, IsNil)
+	testRPCServer = httptest.NewServer(getRPCHandler(s.auditLog, api.NewTracer()))
 }
 
 func (s *MyRPCSuite) TearDownSuite(c *C) {
 	testRPCServer.Close()
+	s.auditLog.Close()
+	os.RemoveAll(s.root)
+}
+
+func (s *MyRPCSuite) TestAuditedCalls(c *C) {
+	op := controller.RPCOps{
+		Method:  "Version.Get",
+		Request: rpc.Args{Request: ""},
+	}
+	req, err := controller.NewRequest(testRPCServer.URL+"/rpc", op, http.DefaultTransport)
+	c.Assert(err, IsNil)
+	resp, err := req.Do()
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+
+	auditLogPath := filepath.Join(s.root, "audit.log")
+	auditLog, err := ioutil.ReadFile(auditLogPath)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(auditLog), `"api":"Version.Get"`), Equals, true)
+	c.Assert(api.VerifyAuditLog(auditLogPath), IsNil)
+
+	// any change breaks the chain
+	tampered := filepath.Join(s.root, "tampered.log")
+	c.Assert(ioutil.WriteFile(tampered, []byte(strings.Replace(string(auditLog), "Version.Get", "Version.Got", 1)), 0600), IsNil)
+	c.Assert(api.VerifyAuditLog(tampered), Not(IsNil))
+	_, err = api.NewAuditLog(api.Config{AuditLog: tampered})
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *MyRPCSuite) TestOversizedCall(c *C) {
+	body := `{"method":"Version.Get","params":[{"request":"` + strings.Repeat("a", rpc.MaxRequestSize) + `"}],"id":1}`
+	resp, err := http.Post(testRPCServer.URL+"/rpc", "application/json", strings.NewReader(body))
+	c.Assert(err, IsNil)
+	var reply rpc.VersionReply
+	err = jsonrpc.DecodeClientResponse(resp.Body, &reply)
+	resp.Body.Close()
+	c.Assert(err, Not(IsNil))
+	c.Assert(reply, DeepEquals, rpc.VersionReply{})
 }
 
 func (s *MyRPCSuite) TestDiskInfo(c *C) {
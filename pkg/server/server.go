@@ -94,27 +94,33 @@ func getRPCServer(rpcHandler http.Handler) *http.Server {
 	return httpServer
 }
 
-// Start ticket master
-func startTM(a api.Minio) {
-	for {
-		for op := range a.OP {
-			op.ProceedCh <- struct{}{}
-		}
-	}
-}
-
 // StartServices starts basic services for a server
 func StartServices(conf api.Config) error {
-	apiHandler, minioAPI := getAPIHandler(conf)
+	apiHandler, minioAPI, err := getAPIHandler(conf)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
 	apiServer, err := getAPIServer(conf, apiHandler)
 	if err != nil {
 		return iodine.New(err, nil)
 	}
-	rpcServer := getRPCServer(getRPCHandler())
+	rpcServer := getRPCServer(getRPCHandler(minioAPI.AuditLog, minioAPI.Tracer))
 	// start ticket master
-	go startTM(minioAPI)
+	go startTM(minioAPI, conf)
 
-	if err := minhttp.ListenAndServeLimited(conf.RateLimit, apiServer, rpcServer); err != nil {
+	err = minhttp.ListenAndServeLimited(conf.RateLimit, apiServer, rpcServer)
+	// servers return once stopped on SIGTERM and their requests are served, acknowledged writes still held
+	// in memory reach the disks before exiting
+	if flushErr := minioAPI.Donut.Flush(); flushErr != nil && err == nil {
+		err = flushErr
+	}
+	if minioAPI.AccessLog != nil {
+		minioAPI.AccessLog.Close()
+	}
+	if minioAPI.AuditLog != nil {
+		minioAPI.AuditLog.Close()
+	}
+	if err != nil {
 		return iodine.New(err, nil)
 	}
 	return nil
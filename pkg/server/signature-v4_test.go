@@ -27,6 +27,7 @@ import (
 	"net/http"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -157,6 +158,9 @@ func (s *MyAPISignatureV4Suite) newRequest(method, urlStr string, contentLength
 	}
 
 	req.Header.Set("x-amz-date", t.Format(iso8601Format))
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
 	if method == "" {
 		method = "POST"
 	}
@@ -270,3 +274,52 @@ func (s *MyAPISignatureV4Suite) newRequest(method, urlStr string, contentLength
 
 	return req, nil
 }
+
+func (s *MyAPISignatureV4Suite) newPresignedRequest(method, urlStr string, expires time.Duration) (*http.Request, error) {
+	t := time.Now().UTC()
+	req, err := http.NewRequest(method, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := strings.Join([]string{
+		t.Format(yyyymmdd),
+		"milkyway",
+		"s3",
+		"aws4_request",
+	}, "/")
+
+	query := req.URL.Query()
+	query.Set("X-Amz-Algorithm", authHeader)
+	query.Set("X-Amz-Credential", s.accessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", t.Format(iso8601Format))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	req.URL.RawQuery = strings.Replace(query.Encode(), "+", "%20", -1)
+
+	encodedPath, _ := urlEncodeName(req.URL.Path)
+	// convert any space strings back to "+"
+	encodedPath = strings.Replace(encodedPath, "+", "%20", -1)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		encodedPath,
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	stringToSign := authHeader + "\n" + t.Format(iso8601Format) + "\n"
+	stringToSign = stringToSign + scope + "\n"
+	stringToSign = stringToSign + hex.EncodeToString(sum256([]byte(canonicalRequest)))
+
+	date := sumHMAC([]byte("AWS4"+s.secretAccessKey), []byte(t.Format(yyyymmdd)))
+	region := sumHMAC(date, []byte("milkyway"))
+	service := sumHMAC(region, []byte("s3"))
+	signingKey := sumHMAC(service, []byte("aws4_request"))
+
+	signature := hex.EncodeToString(sumHMAC(signingKey, []byte(stringToSign)))
+	req.URL.RawQuery = req.URL.RawQuery + "&X-Amz-Signature=" + signature
+	return req, nil
+}
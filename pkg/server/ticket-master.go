@@ -0,0 +1,245 @@
+/*
+ * Minimalist Object Storage, (C) 2014 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"time"
+
+	"github.com/minio/minio/pkg/server/api"
+)
+
+// operation types in the order their queues are served
+var operationTypes = []api.OperationType{
+	api.ReadOperation,
+	api.WriteOperation,
+	api.ListOperation,
+}
+
+// queuedOperation operation waiting to be admitted
+type queuedOperation struct {
+	op       api.Operation
+	deadline time.Time
+}
+
+// fairQueue operations queued per access key, access keys take turns
+type fairQueue struct {
+	keys    []string
+	pending map[string][]queuedOperation
+}
+
+func newFairQueue() *fairQueue {
+	return &fairQueue{pending: make(map[string][]queuedOperation)}
+}
+
+// push - queue an operation behind the previous ones of the same access key
+func (q *fairQueue) push(queued queuedOperation) {
+	key := queued.op.AccessKey
+	if _, ok := q.pending[key]; !ok {
+		q.keys = append(q.keys, key)
+	}
+	q.pending[key] = append(q.pending[key], queued)
+}
+
+// pop - next operation of the access key in turn, the access key then goes to the back of the line
+func (q *fairQueue) pop() (queuedOperation, bool) {
+	if len(q.keys) == 0 {
+		return queuedOperation{}, false
+	}
+	key := q.keys[0]
+	q.keys = q.keys[1:]
+	queued := q.pending[key]
+	if len(queued) == 1 {
+		delete(q.pending, key)
+	} else {
+		q.pending[key] = queued[1:]
+		q.keys = append(q.keys, key)
+	}
+	return queued[0], true
+}
+
+// expire - remove operations whose deadline has passed
+func (q *fairQueue) expire(now time.Time) []queuedOperation {
+	var expired []queuedOperation
+	var keys []string
+	for _, key := range q.keys {
+		queued := q.pending[key]
+		// operations of an access key are queued in order of their deadlines
+		i := 0
+		for i < len(queued) && !queued[i].deadline.IsZero() && !queued[i].deadline.After(now) {
+			i++
+		}
+		expired = append(expired, queued[:i]...)
+		if i == len(queued) {
+			delete(q.pending, key)
+			continue
+		}
+		q.pending[key] = queued[i:]
+		keys = append(keys, key)
+	}
+	q.keys = keys
+	return expired
+}
+
+// nextDeadline - earliest deadline of the queued operations
+func (q *fairQueue) nextDeadline() (deadline time.Time, ok bool) {
+	for _, key := range q.keys {
+		head := q.pending[key][0]
+		if head.deadline.IsZero() {
+			continue
+		}
+		if !ok || head.deadline.Before(deadline) {
+			deadline, ok = head.deadline, true
+		}
+	}
+	return deadline, ok
+}
+
+// len - total number of queued operations
+func (q *fairQueue) len() int {
+	var total int
+	for _, queued := range q.pending {
+		total += len(queued)
+	}
+	return total
+}
+
+// ticketMaster admits at most maxOperations at a time, reads, writes and listings are queued
+// separately and served in turn so that no kind of operation starves the others
+type ticketMaster struct {
+	maxOperations int
+	queueTimeout  time.Duration
+	running       int
+	queues        map[api.OperationType]*fairQueue
+	next          int
+}
+
+func newTicketMaster(conf api.Config) *ticketMaster {
+	tm := &ticketMaster{
+		maxOperations: conf.MaxOperations,
+		queueTimeout:  conf.QueueTimeout,
+		queues:        make(map[api.OperationType]*fairQueue),
+	}
+	for _, opType := range operationTypes {
+		tm.queues[opType] = newFairQueue()
+	}
+	return tm
+}
+
+// enqueue - queue an operation until it is admitted or it times out
+func (tm *ticketMaster) enqueue(op api.Operation, now time.Time) {
+	queued := queuedOperation{op: op}
+	if tm.queueTimeout > 0 {
+		queued.deadline = now.Add(tm.queueTimeout)
+	}
+	queue, ok := tm.queues[op.Type]
+	if !ok {
+		queue = tm.queues[api.ReadOperation]
+	}
+	queue.push(queued)
+}
+
+// done - an admitted operation is done
+func (tm *ticketMaster) done() {
+	if tm.running > 0 {
+		tm.running--
+	}
+}
+
+// dispatch - admit queued operations, taking turns between queues, as long as there is room
+func (tm *ticketMaster) dispatch() {
+	for tm.maxOperations <= 0 || tm.running < tm.maxOperations {
+		queued, ok := tm.pop()
+		if !ok {
+			return
+		}
+		tm.running++
+		queued.op.ProceedCh <- true
+	}
+}
+
+// pop - next operation from the queue in turn
+func (tm *ticketMaster) pop() (queuedOperation, bool) {
+	for i := range operationTypes {
+		index := (tm.next + i) % len(operationTypes)
+		if queued, ok := tm.queues[operationTypes[index]].pop(); ok {
+			tm.next = (index + 1) % len(operationTypes)
+			return queued, true
+		}
+	}
+	return queuedOperation{}, false
+}
+
+// expire - reject operations which timed out waiting in queue
+func (tm *ticketMaster) expire(now time.Time) {
+	for _, opType := range operationTypes {
+		for _, queued := range tm.queues[opType].expire(now) {
+			queued.op.ProceedCh <- false
+		}
+	}
+}
+
+// nextDeadline - earliest deadline of all queued operations
+func (tm *ticketMaster) nextDeadline() (deadline time.Time, ok bool) {
+	for _, opType := range operationTypes {
+		if d, found := tm.queues[opType].nextDeadline(); found && (!ok || d.Before(deadline)) {
+			deadline, ok = d, true
+		}
+	}
+	return deadline, ok
+}
+
+// queued - total number of operations waiting to be admitted
+func (tm *ticketMaster) queued() int {
+	var total int
+	for _, queue := range tm.queues {
+		total += queue.len()
+	}
+	return total
+}
+
+// Start ticket master
+func startTM(a api.Minio, conf api.Config) {
+	tm := newTicketMaster(conf)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		var timeoutCh <-chan time.Time
+		if deadline, ok := tm.nextDeadline(); ok {
+			timer.Reset(deadline.Sub(time.Now()))
+			timeoutCh = timer.C
+		}
+		select {
+		case op := <-a.OP:
+			tm.enqueue(op, time.Now())
+		case <-a.DoneOP:
+			tm.done()
+		case <-timeoutCh:
+			tm.expire(time.Now())
+		}
+		if !timer.Stop() {
+			// drain a timer which fired while another case was selected
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		tm.dispatch()
+		if a.Metrics != nil {
+			a.Metrics.SetQueued(tm.queued())
+		}
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ * Minimalist Object Storage, (C) 2014 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"time"
+
+	. "github.com/minio/check"
+	"github.com/minio/minio/pkg/server/api"
+)
+
+type MyTicketMasterSuite struct{}
+
+var _ = Suite(&MyTicketMasterSuite{})
+
+func newTestOperation(opType api.OperationType, accessKey string) api.Operation {
+	return api.Operation{
+		Type:      opType,
+		AccessKey: accessKey,
+		ProceedCh: make(chan bool, 1),
+	}
+}
+
+func (s *MyTicketMasterSuite) TestTicketMasterMaxOperations(c *C) {
+	tm := newTicketMaster(api.Config{MaxOperations: 1})
+	first := newTestOperation(api.ReadOperation, "")
+	second := newTestOperation(api.ReadOperation, "")
+	tm.enqueue(first, time.Now())
+	tm.enqueue(second, time.Now())
+	tm.dispatch()
+	c.Assert(<-first.ProceedCh, Equals, true)
+	c.Assert(len(second.ProceedCh), Equals, 0)
+	c.Assert(tm.queued(), Equals, 1)
+
+	tm.done()
+	tm.dispatch()
+	c.Assert(<-second.ProceedCh, Equals, true)
+	c.Assert(tm.queued(), Equals, 0)
+}
+
+func (s *MyTicketMasterSuite) TestTicketMasterFairQueuing(c *C) {
+	queue := newFairQueue()
+	for _, accessKey := range []string{"batch", "batch", "batch", "user"} {
+		queue.push(queuedOperation{op: newTestOperation(api.ReadOperation, accessKey)})
+	}
+	var order []string
+	for {
+		queued, ok := queue.pop()
+		if !ok {
+			break
+		}
+		order = append(order, queued.op.AccessKey)
+	}
+	c.Assert(order, DeepEquals, []string{"batch", "user", "batch", "batch"})
+}
+
+func (s *MyTicketMasterSuite) TestTicketMasterSeparateQueues(c *C) {
+	tm := newTicketMaster(api.Config{})
+	for i := 0; i < 2; i++ {
+		tm.enqueue(newTestOperation(api.ListOperation, ""), time.Now())
+	}
+	tm.enqueue(newTestOperation(api.ReadOperation, ""), time.Now())
+	tm.enqueue(newTestOperation(api.WriteOperation, ""), time.Now())
+
+	var order []api.OperationType
+	for {
+		queued, ok := tm.pop()
+		if !ok {
+			break
+		}
+		order = append(order, queued.op.Type)
+	}
+	c.Assert(order, DeepEquals, []api.OperationType{api.ReadOperation, api.WriteOperation, api.ListOperation, api.ListOperation})
+}
+
+func (s *MyTicketMasterSuite) TestTicketMasterQueueTimeout(c *C) {
+	tm := newTicketMaster(api.Config{MaxOperations: 1, QueueTimeout: time.Second})
+	now := time.Now()
+	running := newTestOperation(api.WriteOperation, "batch")
+	waiting := newTestOperation(api.ReadOperation, "user")
+	tm.enqueue(running, now)
+	tm.dispatch()
+	c.Assert(<-running.ProceedCh, Equals, true)
+
+	tm.enqueue(waiting, now)
+	deadline, ok := tm.nextDeadline()
+	c.Assert(ok, Equals, true)
+	c.Assert(deadline, Equals, now.Add(time.Second))
+
+	tm.expire(now.Add(500 * time.Millisecond))
+	c.Assert(tm.queued(), Equals, 1)
+	tm.expire(now.Add(2 * time.Second))
+	c.Assert(<-waiting.ProceedCh, Equals, false)
+	c.Assert(tm.queued(), Equals, 0)
+	_, ok = tm.nextDeadline()
+	c.Assert(ok, Equals, false)
+}
+
+func (s *MyTicketMasterSuite) TestTicketMasterSlowDown(c *C) {
+	minioAPI := api.Minio{
+		OP:     make(chan api.Operation),
+		DoneOP: make(chan api.Operation),
+	}
+	go startTM(minioAPI, api.Config{MaxOperations: 1, QueueTimeout: 10 * time.Millisecond})
+
+	running := newTestOperation(api.WriteOperation, "batch")
+	minioAPI.OP <- running
+	c.Assert(<-running.ProceedCh, Equals, true)
+
+	// no room is made, the next operation times out in queue
+	waiting := newTestOperation(api.ReadOperation, "user")
+	minioAPI.OP <- waiting
+	c.Assert(<-waiting.ProceedCh, Equals, false)
+
+	minioAPI.DoneOP <- running
+	next := newTestOperation(api.ReadOperation, "user")
+	minioAPI.OP <- next
+	c.Assert(<-next.ProceedCh, Equals, true)
+}
@@ -0,0 +1,68 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package bufpool provides size classed pools of byte buffers, shared by the data paths which
+// need large temporary buffers such as splitting, erasure encoding and decoding.
+//
+// Buffers are handed out from the smallest class they fit in and return to the largest class
+// their capacity covers, sizes beyond the largest class are not pooled.
+package bufpool
+
+import "sync"
+
+const (
+	// smallest size class, 4KiB
+	minClassShift = 12
+	// largest size class, 64MiB
+	maxClassShift = 26
+)
+
+var pools [maxClassShift - minClassShift + 1]sync.Pool
+
+// classSize - size of buffers in a class
+func classSize(class int) int {
+	return 1 << uint(class+minClassShift)
+}
+
+// Get - get a buffer of length size, its capacity is that of its size class
+func Get(size int) []byte {
+	if size < 0 {
+		size = 0
+	}
+	class := 0
+	for class < len(pools) && classSize(class) < size {
+		class++
+	}
+	if class == len(pools) {
+		return make([]byte, size)
+	}
+	if buf, ok := pools[class].Get().([]byte); ok {
+		return buf[:size]
+	}
+	return make([]byte, size, classSize(class))
+}
+
+// Put - return a buffer for reuse, the buffer must not be used afterwards
+func Put(buf []byte) {
+	if cap(buf) < classSize(0) {
+		return
+	}
+	class := len(pools) - 1
+	for classSize(class) > cap(buf) {
+		class--
+	}
+	pools[class].Put(buf[:0])
+}
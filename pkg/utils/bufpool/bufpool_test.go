@@ -0,0 +1,69 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bufpool
+
+import (
+	"testing"
+
+	. "github.com/minio/check"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MySuite struct{}
+
+var _ = Suite(&MySuite{})
+
+func (s *MySuite) TestGetSizeClasses(c *C) {
+	buf := Get(1)
+	c.Assert(len(buf), Equals, 1)
+	c.Assert(cap(buf), Equals, 4*1024)
+
+	buf = Get(10*1024*1024 + 1)
+	c.Assert(len(buf), Equals, 10*1024*1024+1)
+	c.Assert(cap(buf), Equals, 16*1024*1024)
+
+	// beyond the largest class
+	buf = Get(100 * 1024 * 1024)
+	c.Assert(len(buf), Equals, 100*1024*1024)
+	c.Assert(cap(buf), Equals, 100*1024*1024)
+}
+
+func (s *MySuite) TestPutReusesBuffers(c *C) {
+	// buffers go back to the largest class their capacity covers
+	Put(make([]byte, 5000))
+	buf := Get(4096)
+	c.Assert(len(buf), Equals, 4096)
+	c.Assert(cap(buf) >= 4096, Equals, true)
+
+	// buffers smaller than the smallest class are dropped
+	Put(make([]byte, 10))
+}
+
+func BenchmarkGetPut(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Put(Get(10 * 1024 * 1024))
+	}
+}
+
+func BenchmarkMake(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = make([]byte, 10*1024*1024)
+	}
+}